@@ -0,0 +1,66 @@
+package main
+
+import (
+	"flag"
+	"regexp"
+	"strings"
+
+	"github.com/SirChronus/dtxmania-dbdump/dtxdb"
+)
+
+// anonymizeRecords is set with -anonymize: records are then stripped of
+// what tells about the player before they are used, so a songs.db or a
+// dump can be shared to debug a problem.
+var anonymizeRecords bool
+
+func anonymizeFlag(flags *flag.FlagSet) {
+	flags.BoolVar(&anonymizeRecords, "anonymize", false, "blank the comments, the player names of the play history and the user folders of paths")
+}
+
+// userFolder matches the folder of a user in a path, whose name is often
+// that of the player.
+var userFolder = regexp.MustCompile(`(?i)([\\/](?:users|documents and settings|home)[\\/])[^\\/]+`)
+
+// playResult matches the results of a line of the play history, like
+// "Drums:Cleared".
+var playResult = regexp.MustCompile(`(?i)^(drums|guitar|bass):\S+$`)
+
+// anonymizeHistoryLine keeps the date and the results of a line of the
+// play history, anything else may be a name.
+func anonymizeHistoryLine(line string) string {
+	if _, ok := parsePlayEntry(line); !ok {
+		return ""
+	}
+	words := strings.Fields(line)
+	kept := words[:1]
+	for _, w := range words[1:] {
+		if playResult.MatchString(w) {
+			kept = append(kept, w)
+		}
+	}
+	return strings.Join(kept, " ")
+}
+
+func anonymizeScore(s *dtxdb.Score) error {
+	s.FileInformation.AbsoluteFilePath = userFolder.ReplaceAllString(s.FileInformation.AbsoluteFilePath, "${1}player")
+	s.FileInformation.AbsoluteFolderPath = userFolder.ReplaceAllString(s.FileInformation.AbsoluteFolderPath, "${1}player")
+	s.SongInformation.Comment = ""
+	h := &s.SongInformation.PerformanceHistory
+	for _, line := range []*string{&h.First, &h.Second, &h.Third, &h.Fourth, &h.Fifth} {
+		*line = anonymizeHistoryLine(*line)
+	}
+	return nil
+}
+
+func runAnonymize(args []string) {
+	flags := newFlagSet("anonymize")
+	input := inputDBFlag(flags)
+	output := outputDBFlag(flags)
+	readerFlags(flags)
+	flags.Parse(args)
+
+	anonymizeRecords = true
+	versionString, scores := readAllScores(*input)
+	writeSongsDB(*output, versionString, scores)
+	infof("%d records anonymized into %s\n", len(scores), *output)
+}
@@ -0,0 +1,383 @@
+package main
+
+import (
+	"encoding/binary"
+	"io"
+	"math"
+)
+
+// Arrow IPC files, also known as Feather version 2, have the flat schema
+// of the Parquet files: a non-nullable column for every field of the XML
+// dump. Record batches are uncompressed with every buffer 8 byte aligned,
+// so readers can memory-map them. The metadata are FlatBuffers, built
+// front to back by flatTable.
+
+// arrowBatchRows is the number of records of a record batch.
+const arrowBatchRows = 10000
+
+const arrowMagic = "ARROW1"
+
+// Arrow metadata version V5, and the unions of types and message headers.
+const (
+	arrowVersion     = 4
+	arrowTypeInt     = 2
+	arrowTypeFloat   = 3
+	arrowTypeUtf8    = 5
+	arrowTypeBool    = 6
+	arrowSchema      = 1
+	arrowRecordBatch = 3
+)
+
+// flatObject is an object of a FlatBuffer: a table, vector or string.
+// write appends it and returns where it starts, which offsets to it
+// point at.
+type flatObject interface {
+	write(b *[]byte) int
+}
+
+func flatPad(b *[]byte, align int) {
+	for len(*b)%align != 0 {
+		*b = append(*b, 0)
+	}
+}
+
+// flatField is a field of a table, either a little endian scalar or an
+// offset to an object written after the table. Fields left out are nil.
+type flatField struct {
+	scalar []byte
+	object flatObject
+}
+
+func flatInt16(v int16) *flatField {
+	return &flatField{scalar: []byte{byte(v), byte(v >> 8)}}
+}
+
+func flatInt32(v int32) *flatField {
+	return &flatField{scalar: appendLittleUint32(nil, uint32(v))}
+}
+
+func flatInt64(v int64) *flatField {
+	return &flatField{scalar: appendLittleUint64(nil, uint64(v))}
+}
+
+func flatUint8(v uint8) *flatField {
+	return &flatField{scalar: []byte{v}}
+}
+
+func flatRef(o flatObject) *flatField {
+	return &flatField{object: o}
+}
+
+// flatTable is a table, its fields by id. The vtable is written right
+// before it.
+type flatTable []*flatField
+
+func (t flatTable) write(b *[]byte) int {
+	// Fields are aligned to their size, the table to 8 bytes.
+	offsets := make([]int, len(t))
+	size := 4
+	for i, f := range t {
+		if f == nil {
+			continue
+		}
+		n := 4
+		if f.object == nil {
+			n = len(f.scalar)
+		}
+		for size%n != 0 {
+			size++
+		}
+		offsets[i] = size
+		size += n
+	}
+
+	flatPad(b, 2)
+	vtable := len(*b)
+	*b = append(*b, byte(4+2*len(t)), byte((4+2*len(t))>>8), byte(size), byte(size>>8))
+	for _, off := range offsets {
+		*b = append(*b, byte(off), byte(off>>8))
+	}
+	flatPad(b, 8)
+	start := len(*b)
+	*b = appendLittleUint32(*b, uint32(start-vtable))
+	for i, f := range t {
+		if f == nil {
+			continue
+		}
+		for len(*b) < start+offsets[i] {
+			*b = append(*b, 0)
+		}
+		if f.object == nil {
+			*b = append(*b, f.scalar...)
+		} else {
+			*b = appendLittleUint32(*b, 0)
+		}
+	}
+	for len(*b) < start+size {
+		*b = append(*b, 0)
+	}
+
+	for i, f := range t {
+		if f != nil && f.object != nil {
+			at := start + offsets[i]
+			pos := f.object.write(b)
+			binary.LittleEndian.PutUint32((*b)[at:], uint32(pos-at))
+		}
+	}
+	return start
+}
+
+// flatVector is a vector of objects.
+type flatVector []flatObject
+
+func (v flatVector) write(b *[]byte) int {
+	flatPad(b, 4)
+	start := len(*b)
+	*b = appendLittleUint32(*b, uint32(len(v)))
+	for range v {
+		*b = appendLittleUint32(*b, 0)
+	}
+	for i, o := range v {
+		at := start + 4 + 4*i
+		pos := o.write(b)
+		binary.LittleEndian.PutUint32((*b)[at:], uint32(pos-at))
+	}
+	return start
+}
+
+// flatStructs is a vector of n structs of 8 byte aligned fields.
+type flatStructs struct {
+	n    int
+	data []byte
+}
+
+func (v flatStructs) write(b *[]byte) int {
+	for len(*b)%8 != 4 {
+		*b = append(*b, 0)
+	}
+	start := len(*b)
+	*b = appendLittleUint32(*b, uint32(v.n))
+	*b = append(*b, v.data...)
+	return start
+}
+
+type flatString string
+
+func (s flatString) write(b *[]byte) int {
+	flatPad(b, 4)
+	start := len(*b)
+	*b = appendLittleUint32(*b, uint32(len(s)))
+	*b = append(*b, s...)
+	*b = append(*b, 0)
+	return start
+}
+
+// flatBuffer returns the FlatBuffer of the root table t.
+func flatBuffer(t flatTable) []byte {
+	b := make([]byte, 4, 256)
+	root := t.write(&b)
+	binary.LittleEndian.PutUint32(b, uint32(root))
+	flatPad(&b, 8)
+	return b
+}
+
+type arrowColumn struct {
+	name     string
+	physical int32
+	// data holds the values, offsets the offsets of the strings in data.
+	data    []byte
+	offsets []byte
+}
+
+// field returns the Field table of c.
+func (c *arrowColumn) field() flatTable {
+	var typeType uint8
+	var typ flatTable
+	switch c.physical {
+	case parquetBoolean:
+		typeType, typ = arrowTypeBool, flatTable{}
+	case parquetInt32:
+		typeType, typ = arrowTypeInt, flatTable{flatInt32(32), flatUint8(1)}
+	case parquetInt64:
+		typeType, typ = arrowTypeInt, flatTable{flatInt32(64), flatUint8(1)}
+	case parquetDouble:
+		typeType, typ = arrowTypeFloat, flatTable{flatInt16(2)}
+	default:
+		typeType, typ = arrowTypeUtf8, flatTable{}
+	}
+	return flatTable{
+		flatRef(flatString(c.name)),
+		flatUint8(0),
+		flatUint8(typeType),
+		flatRef(typ),
+		nil,
+		flatRef(flatVector{}),
+	}
+}
+
+// arrowBlock is where a record batch is in the file.
+type arrowBlock struct {
+	offset     int64
+	metaLength int32
+	bodyLength int64
+}
+
+// arrowWriter keeps the values of a record batch in memory and writes the
+// footer, which lists every record batch, on close.
+type arrowWriter struct {
+	w       *countingWriter
+	columns []arrowColumn
+	rows    int
+	blocks  []arrowBlock
+}
+
+func newArrowWriter(w io.Writer) (recordWriter, error) {
+	a := &arrowWriter{w: &countingWriter{w: w}}
+	for _, f := range scoreFields(&score{}) {
+		physical, _ := parquetColumnType(f.value)
+		c := arrowColumn{name: sqlColumnName(f.name), physical: physical}
+		if physical == parquetByteArray {
+			c.offsets = appendLittleUint32(nil, 0)
+		}
+		a.columns = append(a.columns, c)
+	}
+	if _, err := io.WriteString(a.w, arrowMagic+"\x00\x00"); err != nil {
+		return nil, err
+	}
+	_, err := a.writeMessage(arrowSchema, a.schema(), nil, 0)
+	return a, err
+}
+
+func (a *arrowWriter) schema() flatTable {
+	var fields flatVector
+	for i := range a.columns {
+		fields = append(fields, a.columns[i].field())
+	}
+	return flatTable{flatInt16(0), flatRef(fields)}
+}
+
+// writeMessage writes an encapsulated message: a continuation marker, the
+// size of the metadata and the metadata padded to 8 bytes, then the body
+// of bodyLength bytes in parts.
+func (a *arrowWriter) writeMessage(headerType uint8, header flatTable, body [][]byte, bodyLength int64) (arrowBlock, error) {
+	meta := flatBuffer(flatTable{
+		flatInt16(arrowVersion),
+		flatUint8(headerType),
+		flatRef(header),
+		flatInt64(bodyLength),
+	})
+	block := arrowBlock{offset: a.w.n, metaLength: int32(8 + len(meta)), bodyLength: bodyLength}
+	prefix := appendLittleUint32(appendLittleUint32(nil, 0xffffffff), uint32(len(meta)))
+	if _, err := a.w.Write(append(prefix, meta...)); err != nil {
+		return block, err
+	}
+	for _, part := range body {
+		if _, err := a.w.Write(part); err != nil {
+			return block, err
+		}
+	}
+	return block, nil
+}
+
+func (a *arrowWriter) write(s *score) error {
+	for i, f := range scoreFields(s) {
+		c := &a.columns[i]
+		switch c.physical {
+		case parquetBoolean:
+			// Booleans are bit packed, the first value in the lowest bit.
+			if a.rows%8 == 0 {
+				c.data = append(c.data, 0)
+			}
+			if f.value.Bool() {
+				c.data[len(c.data)-1] |= 1 << (a.rows % 8)
+			}
+		case parquetInt32:
+			c.data = appendLittleUint32(c.data, uint32(f.value.Int()))
+		case parquetInt64:
+			c.data = appendLittleUint64(c.data, uint64(f.value.Int()))
+		case parquetDouble:
+			c.data = appendLittleUint64(c.data, math.Float64bits(f.value.Float()))
+		default:
+			c.data = append(c.data, f.String()...)
+			c.offsets = appendLittleUint32(c.offsets, uint32(len(c.data)))
+		}
+	}
+	a.rows++
+	if a.rows == arrowBatchRows {
+		return a.flushBatch()
+	}
+	return nil
+}
+
+// flushBatch writes the values kept as a record batch. No column has a
+// validity bitmap, every buffer is padded to 8 bytes.
+func (a *arrowWriter) flushBatch() error {
+	var nodes, buffers []byte
+	var body [][]byte
+	var length int64
+	addBuffer := func(data []byte) {
+		buffers = appendLittleUint64(buffers, uint64(length))
+		buffers = appendLittleUint64(buffers, uint64(len(data)))
+		body = append(body, data, make([]byte, (8-len(data)%8)%8))
+		length += int64(len(data)+7) &^ 7
+	}
+	for i := range a.columns {
+		c := &a.columns[i]
+		nodes = appendLittleUint64(nodes, uint64(a.rows))
+		nodes = appendLittleUint64(nodes, 0)
+		addBuffer(nil)
+		if c.offsets != nil {
+			addBuffer(c.offsets)
+		}
+		addBuffer(c.data)
+	}
+
+	batch := flatTable{
+		flatInt64(int64(a.rows)),
+		flatRef(flatStructs{len(a.columns), nodes}),
+		flatRef(flatStructs{len(buffers) / 16, buffers}),
+	}
+	block, err := a.writeMessage(arrowRecordBatch, batch, body, length)
+	if err != nil {
+		return err
+	}
+	a.blocks = append(a.blocks, block)
+
+	for i := range a.columns {
+		c := &a.columns[i]
+		c.data = nil
+		if c.offsets != nil {
+			c.offsets = appendLittleUint32(nil, 0)
+		}
+	}
+	a.rows = 0
+	return nil
+}
+
+func (a *arrowWriter) close() error {
+	if a.rows > 0 {
+		if err := a.flushBatch(); err != nil {
+			return err
+		}
+	}
+	var blocks []byte
+	for _, b := range a.blocks {
+		blocks = appendLittleUint64(blocks, uint64(b.offset))
+		blocks = appendLittleUint64(blocks, uint64(b.metaLength))
+		blocks = appendLittleUint64(blocks, uint64(b.bodyLength))
+	}
+	footer := flatBuffer(flatTable{
+		flatInt16(arrowVersion),
+		flatRef(a.schema()),
+		flatRef(flatStructs{}),
+		flatRef(flatStructs{len(a.blocks), blocks}),
+	})
+
+	// The end of stream marker, then the footer and its size.
+	out := appendLittleUint32(appendLittleUint32(nil, 0xffffffff), 0)
+	out = append(out, footer...)
+	out = appendLittleUint32(out, uint32(len(footer)))
+	out = append(out, arrowMagic...)
+	_, err := a.w.Write(out)
+	return err
+}
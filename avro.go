@@ -0,0 +1,210 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"strings"
+)
+
+// Avro object container files, see
+// https://avro.apache.org/docs/current/specification/#object-container-files
+//
+// The schema is derived from the XML tags of score, so the records have
+// the same fields as dump.xml with dashes replaced by underscores. Dates and
+// song types are strings as in the dump.
+
+const avroBlockRecords = 1000
+
+var avroMagic = []byte{'O', 'b', 'j', 1}
+
+type avroField struct {
+	Name string      `json:"name"`
+	Type interface{} `json:"type"`
+}
+
+type avroRecord struct {
+	Type      string      `json:"type"`
+	Name      string      `json:"name"`
+	Namespace string      `json:"namespace,omitempty"`
+	Fields    []avroField `json:"fields"`
+}
+
+var stringerType = reflect.TypeOf((*fmt.Stringer)(nil)).Elem()
+
+// xmlFieldName returns the element name of a struct field, or "" for
+// fields that are not part of the dump.
+func xmlFieldName(f reflect.StructField) string {
+	tag := strings.Split(f.Tag.Get("xml"), ",")[0]
+	if f.Name == "XMLName" || tag == "-" {
+		return ""
+	}
+	return tag
+}
+
+func avroName(tag string) string {
+	return strings.ReplaceAll(tag, "-", "_")
+}
+
+// avroType returns the schema of values of type t. Records are only
+// defined the first time they are used, later uses refer to their name.
+func avroType(t reflect.Type, defined map[string]bool) interface{} {
+	if t.Implements(stringerType) {
+		return "string"
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int32:
+		return "int"
+	case reflect.Int64:
+		return "long"
+	case reflect.Float64:
+		return "double"
+	case reflect.Struct:
+		name := schemaTypeName(t)
+		if defined[name] {
+			return name
+		}
+		defined[name] = true
+		return avroRecord{Type: "record", Name: name, Fields: avroFields(t, defined)}
+	}
+	panic("avro: unsupported type " + t.String())
+}
+
+func avroFields(t reflect.Type, defined map[string]bool) []avroField {
+	var fields []avroField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Anonymous {
+			fields = append(fields, avroFields(f.Type, defined)...)
+			continue
+		}
+		if name := xmlFieldName(f); name != "" {
+			fields = append(fields, avroField{avroName(name), avroType(f.Type, defined)})
+		}
+	}
+	return fields
+}
+
+func avroSchema() ([]byte, error) {
+	return json.Marshal(avroRecord{
+		Type:      "record",
+		Name:      "song",
+		Namespace: "dtxmania",
+		Fields:    avroFields(reflect.TypeOf(score{}), map[string]bool{}),
+	})
+}
+
+// appendAvroLong appends v zig-zag encoded, which is what PutVarint does.
+func appendAvroLong(b []byte, v int64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	return append(b, buf[:binary.PutVarint(buf[:], v)]...)
+}
+
+func appendAvroString(b []byte, v string) []byte {
+	return append(appendAvroLong(b, int64(len(v))), v...)
+}
+
+func appendAvroValue(b []byte, v reflect.Value) []byte {
+	if v.Type().Implements(stringerType) {
+		return appendAvroString(b, v.Interface().(fmt.Stringer).String())
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return appendAvroString(b, v.String())
+	case reflect.Bool:
+		if v.Bool() {
+			return append(b, 1)
+		}
+		return append(b, 0)
+	case reflect.Int32, reflect.Int64:
+		return appendAvroLong(b, v.Int())
+	case reflect.Float64:
+		var buf [8]byte
+		binary.LittleEndian.PutUint64(buf[:], math.Float64bits(v.Float()))
+		return append(b, buf[:]...)
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if f.Anonymous || xmlFieldName(f) != "" {
+				b = appendAvroValue(b, v.Field(i))
+			}
+		}
+		return b
+	}
+	panic("avro: unsupported type " + v.Type().String())
+}
+
+// avroWriter writes records in blocks of avroBlockRecords, uncompressed.
+type avroWriter struct {
+	w     io.Writer
+	sync  [16]byte
+	block []byte
+	count int
+}
+
+func newAvroWriter(w io.Writer) (recordWriter, error) {
+	schema, err := avroSchema()
+	if err != nil {
+		return nil, err
+	}
+	a := &avroWriter{w: w}
+	if _, err := rand.Read(a.sync[:]); err != nil {
+		return nil, err
+	}
+
+	header := append([]byte{}, avroMagic...)
+	// File metadata, a map of bytes with a single block of entries.
+	header = appendAvroLong(header, 2)
+	header = appendAvroString(header, "avro.schema")
+	header = appendAvroString(header, string(schema))
+	header = appendAvroString(header, "avro.codec")
+	header = appendAvroString(header, "null")
+	header = appendAvroLong(header, 0)
+	header = append(header, a.sync[:]...)
+	_, err = w.Write(header)
+	return a, err
+}
+
+func (a *avroWriter) write(s *score) error {
+	a.block = appendAvroValue(a.block, reflect.ValueOf(s).Elem())
+	a.count++
+	if a.count == avroBlockRecords {
+		return a.flush()
+	}
+	return nil
+}
+
+// buffered returns the size of the block not written yet, with its counts
+// and sync marker.
+func (a *avroWriter) buffered() int {
+	return len(a.block) + 2*binary.MaxVarintLen64 + len(a.sync)
+}
+
+func (a *avroWriter) flush() error {
+	if a.count == 0 {
+		return nil
+	}
+	var buf bytes.Buffer
+	buf.Write(appendAvroLong(nil, int64(a.count)))
+	buf.Write(appendAvroLong(nil, int64(len(a.block))))
+	buf.Write(a.block)
+	buf.Write(a.sync[:])
+	a.block, a.count = a.block[:0], 0
+
+	_, err := a.w.Write(buf.Bytes())
+	return err
+}
+
+func (a *avroWriter) close() error {
+	return a.flush()
+}
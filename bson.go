@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"reflect"
+
+	"github.com/SirChronus/dtxmania-dbdump/dtxdb"
+)
+
+// BSON dumps are a document per record, one after the other like the
+// .bson files of mongodump, with the keys of the JSON dump. Dates are BSON
+// dates, which only keep milliseconds; song types are strings.
+
+// BSON element types.
+const (
+	bsonDouble   = 0x01
+	bsonString   = 0x02
+	bsonDocument = 0x03
+	bsonBoolean  = 0x08
+	bsonDate     = 0x09
+	bsonInt32    = 0x10
+	bsonInt64    = 0x12
+)
+
+type bsonWriter struct {
+	w   *bufio.Writer
+	buf []byte
+}
+
+func newBSONWriter(w io.Writer) (recordWriter, error) {
+	return &bsonWriter{w: bufio.NewWriter(w)}, nil
+}
+
+func (b *bsonWriter) write(s *score) error {
+	b.buf = appendBSONDocument(b.buf[:0], reflect.ValueOf(s).Elem())
+	_, err := b.w.Write(b.buf)
+	return err
+}
+
+func (b *bsonWriter) buffered() int {
+	return b.w.Buffered()
+}
+
+func (b *bsonWriter) close() error {
+	return b.w.Flush()
+}
+
+// appendBSONDocument appends the struct v as a document: its size, its
+// elements and a 0.
+func appendBSONDocument(b []byte, v reflect.Value) []byte {
+	start := len(b)
+	b = append(b, 0, 0, 0, 0)
+	names, values := elementFields(v)
+	for i, f := range values {
+		b = appendBSONElement(b, names[i], f)
+	}
+	b = append(b, 0)
+	size := uint32(len(b) - start)
+	b[start], b[start+1], b[start+2], b[start+3] = byte(size), byte(size>>8), byte(size>>16), byte(size>>24)
+	return b
+}
+
+func appendBSONElement(b []byte, name string, v reflect.Value) []byte {
+	elem := func(typ byte) []byte {
+		return append(append(append(b, typ), name...), 0)
+	}
+	if t, ok := v.Interface().(dtxdb.SongType); ok {
+		return appendBSONString(elem(bsonString), t.String())
+	}
+	if d, ok := v.Interface().(dtxdb.Date); ok {
+		ms := d.Unix()*1000 + int64(d.Nanosecond()/1e6)
+		return appendLittleUint64(elem(bsonDate), uint64(ms))
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return appendBSONString(elem(bsonString), v.String())
+	case reflect.Bool:
+		if v.Bool() {
+			return append(elem(bsonBoolean), 1)
+		}
+		return append(elem(bsonBoolean), 0)
+	case reflect.Int32:
+		return appendLittleUint32(elem(bsonInt32), uint32(v.Int()))
+	case reflect.Int64:
+		return appendLittleUint64(elem(bsonInt64), uint64(v.Int()))
+	case reflect.Float64:
+		return appendLittleUint64(elem(bsonDouble), math.Float64bits(v.Float()))
+	case reflect.Struct:
+		return appendBSONDocument(elem(bsonDocument), v)
+	}
+	panic("bson: unsupported type " + v.Type().String())
+}
+
+// appendBSONString appends the size of s with its terminating 0, s and
+// the 0.
+func appendBSONString(b []byte, s string) []byte {
+	b = appendLittleUint32(b, uint32(len(s)+1))
+	return append(append(b, s...), 0)
+}
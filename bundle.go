@@ -0,0 +1,217 @@
+package main
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// bundleManifestName is the manifest stored at the root of every bundle.
+const bundleManifestName = "manifest.xml"
+
+type bundleManifest struct {
+	XMLName xml.Name     `xml:"bundle"`
+	Name    string       `xml:"name,attr"`
+	Created string       `xml:"created,attr"`
+	Songs   []bundleSong `xml:"song"`
+}
+
+// bundleSong is a chart of a bundle. Folder is the slash separated folder
+// of the chart inside the bundle.
+type bundleSong struct {
+	Folder string `xml:"folder,attr"`
+	File   string `xml:"file,attr"`
+	Title  string `xml:"title"`
+	Artist string `xml:"artist"`
+	Genre  string `xml:"genre,omitempty"`
+}
+
+func chartFileName(s *score) string {
+	p := s.FileInformation.AbsoluteFilePath
+	return p[strings.LastIndexAny(p, `\/`)+1:]
+}
+
+// difficultyLabels maps the usual chart file names to the labels DTXMania
+// shows for them.
+var difficultyLabels = map[string]string{
+	"bsc": "BASIC", "bas": "BASIC", "basic": "BASIC",
+	"adv": "ADVANCED", "advanced": "ADVANCED",
+	"ext": "EXTREME", "extreme": "EXTREME",
+	"mas": "MASTER", "master": "MASTER",
+}
+
+// setDef generates a set.def grouping the charts of a song folder by title,
+// with the difficulties ordered from the easiest to the hardest drums level.
+func setDef(charts []*score) string {
+	byTitle := make(map[string][]*score)
+	var titles []string
+	for _, s := range charts {
+		t := s.SongInformation.Title
+		if _, ok := byTitle[t]; !ok {
+			titles = append(titles, t)
+		}
+		byTitle[t] = append(byTitle[t], s)
+	}
+
+	var b strings.Builder
+	for _, t := range titles {
+		levels := byTitle[t]
+		sort.SliceStable(levels, func(i, j int) bool {
+			return levels[i].SongInformation.Level.Drums < levels[j].SongInformation.Level.Drums
+		})
+		if len(levels) > 5 {
+			// DTXMania only knows five difficulties per song.
+			levels = levels[:5]
+		}
+
+		fmt.Fprintf(&b, "#TITLE %s\r\n", t)
+		for i, s := range levels {
+			name := chartFileName(s)
+			label, ok := difficultyLabels[strings.ToLower(strings.TrimSuffix(name, path.Ext(name)))]
+			if !ok {
+				label = fmt.Sprintf("LEVEL %d", i+1)
+			}
+			fmt.Fprintf(&b, "#L%dLABEL %s\r\n#L%dFILE %s\r\n", i+1, label, i+1, name)
+		}
+		b.WriteString("\r\n")
+	}
+
+	return b.String()
+}
+
+// addFolderToZip stores every file below dir in z under prefix, returning
+// whether the folder already contained a set.def.
+func addFolderToZip(z *zip.Writer, dir, prefix string) (bool, error) {
+	hasSetDef := false
+	err := filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil || !info.Mode().IsRegular() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		if strings.EqualFold(rel, "set.def") {
+			hasSetDef = true
+		}
+
+		header, err := zip.FileInfoHeader(info)
+		if err != nil {
+			return err
+		}
+		header.Name = prefix + filepath.ToSlash(rel)
+		header.Method = zip.Deflate
+		w, err := z.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(w, f)
+		return err
+	})
+
+	return hasSetDef, err
+}
+
+// createZipFile adds a compressed file generated by dbdump to z.
+func createZipFile(z *zip.Writer, name string) (io.Writer, error) {
+	return z.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: time.Now()})
+}
+
+func runBundle(args []string) {
+	flags := newFlagSet("bundle")
+	input := inputDBFlag(flags)
+	var filters filterList
+	flags.Var(&filters, "filter", filterUsage)
+	output := flags.String("o", "pack.zip", "write the bundle to `file`")
+	name := flags.String("name", "", "`name` of the pack stored in the manifest (default the bundle file name)")
+	readerFlags(flags)
+	flags.Parse(args)
+	if *name == "" {
+		*name = strings.TrimSuffix(filepath.Base(*output), filepath.Ext(*output))
+	}
+
+	_, scores := readAllScores(*input)
+
+	// Group the selected charts by song folder, keeping database order.
+	var folders []string
+	charts := make(map[string][]*score)
+	for i := range scores {
+		s := &scores[i]
+		if !filters.match(s) {
+			continue
+		}
+		folder := s.FileInformation.AbsoluteFolderPath
+		if _, ok := charts[folder]; !ok {
+			folders = append(folders, folder)
+		}
+		charts[folder] = append(charts[folder], s)
+	}
+	if len(folders) == 0 {
+		log.Fatalln("no songs match the filters")
+	}
+
+	f, err := os.Create(*output)
+	logFatalIfError(err)
+	defer f.Close()
+	buffered := bufio.NewWriter(f)
+	z := zip.NewWriter(buffered)
+
+	manifest := bundleManifest{Name: *name, Created: time.Now().UTC().Format(time.RFC3339)}
+	used := make(map[string]bool)
+	for _, folder := range folders {
+		songs := charts[folder]
+		base := strings.TrimRight(strings.ReplaceAll(folder, `\`, "/"), "/")
+		base = path.Base(base)
+		prefix := base
+		for n := 2; used[strings.ToLower(prefix)]; n++ {
+			prefix = fmt.Sprintf("%s-%d", base, n)
+		}
+		used[strings.ToLower(prefix)] = true
+
+		hasSetDef, err := addFolderToZip(z, songFilePath(songs[0], ""), prefix+"/")
+		logFatalIfError(err)
+		if !hasSetDef {
+			w, err := createZipFile(z, prefix+"/set.def")
+			logFatalIfError(err)
+			_, err = io.WriteString(w, setDef(songs))
+			logFatalIfError(err)
+		}
+
+		for _, s := range songs {
+			manifest.Songs = append(manifest.Songs, bundleSong{
+				Folder: prefix,
+				File:   chartFileName(s),
+				Title:  s.SongInformation.Title,
+				Artist: s.SongInformation.Artist,
+				Genre:  s.SongInformation.Genre,
+			})
+		}
+	}
+
+	w, err := createZipFile(z, bundleManifestName)
+	logFatalIfError(err)
+	_, err = io.WriteString(w, xml.Header)
+	logFatalIfError(err)
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	logFatalIfError(enc.Encode(manifest))
+
+	logFatalIfError(z.Close())
+	logFatalIfError(buffered.Flush())
+	infof("%d charts from %d folders bundled into %s\n", len(manifest.Songs), len(folders), *output)
+}
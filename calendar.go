@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// playEntry is a line of the performance history of a song. DTXMania
+// starts every line with the date of the play, "yy/MM/dd", followed by a
+// summary of the result.
+type playEntry struct {
+	date    time.Time
+	summary string
+}
+
+func parsePlayEntry(line string) (playEntry, bool) {
+	date, summary, _ := strings.Cut(strings.TrimSpace(line), " ")
+	for _, layout := range []string{"06/01/02", "2006/01/02"} {
+		if t, err := time.ParseInLocation(layout, date, time.Local); err == nil {
+			return playEntry{t, strings.TrimSpace(summary)}, true
+		}
+	}
+	return playEntry{}, false
+}
+
+// playSession is every play of a song on one day.
+type playSession struct {
+	song    *score
+	date    time.Time
+	results []string
+}
+
+func playSessions(scores []score) []playSession {
+	var sessions []playSession
+	for i := range scores {
+		s := &scores[i]
+		h := &s.SongInformation.PerformanceHistory
+		byDate := map[time.Time]*playSession{}
+		var dates []time.Time
+		for _, line := range []string{h.First, h.Second, h.Third, h.Fourth, h.Fifth} {
+			e, ok := parsePlayEntry(line)
+			if !ok {
+				continue
+			}
+			session, ok := byDate[e.date]
+			if !ok {
+				session = &playSession{song: s, date: e.date}
+				byDate[e.date] = session
+				dates = append(dates, e.date)
+			}
+			session.results = append(session.results, e.summary)
+		}
+		for _, d := range dates {
+			sessions = append(sessions, *byDate[d])
+		}
+	}
+
+	sort.SliceStable(sessions, func(i, j int) bool {
+		return sessions[i].date.Before(sessions[j].date)
+	})
+	return sessions
+}
+
+var icsEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`)
+
+// writeICSLine writes a content line, folded to lines of at most 75 bytes
+// as RFC 5545 requires without splitting UTF-8 sequences.
+func writeICSLine(w io.Writer, line string) {
+	limit := 75
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && line[cut]&0xC0 == 0x80 {
+			cut--
+		}
+		fmt.Fprintf(w, "%s\r\n", line[:cut])
+		line = " " + line[cut:]
+	}
+	fmt.Fprintf(w, "%s\r\n", line)
+}
+
+func writeCalendar(w io.Writer, sessions []playSession) {
+	stamp := time.Now().UTC().Format("20060102T150405Z")
+	writeICSLine(w, "BEGIN:VCALENDAR")
+	writeICSLine(w, "VERSION:2.0")
+	writeICSLine(w, "PRODID:-//dtxmania-dbdump//play history//EN")
+	writeICSLine(w, "X-WR-CALNAME:DTXMania play history")
+	for _, p := range sessions {
+		day := p.date.Format("20060102")
+		writeICSLine(w, "BEGIN:VEVENT")
+		writeICSLine(w, fmt.Sprintf("UID:%s-%s@dtxmania-dbdump", songID(p.song), day))
+		writeICSLine(w, "DTSTAMP:"+stamp)
+		writeICSLine(w, "DTSTART;VALUE=DATE:"+day)
+		writeICSLine(w, "DTEND;VALUE=DATE:"+p.date.AddDate(0, 0, 1).Format("20060102"))
+		writeICSLine(w, "SUMMARY:"+icsEscaper.Replace(songLabel(p.song)))
+		writeICSLine(w, "DESCRIPTION:"+icsEscaper.Replace(strings.Join(p.results, "\n")))
+		writeICSLine(w, "TRANSP:TRANSPARENT")
+		writeICSLine(w, "END:VEVENT")
+	}
+	writeICSLine(w, "END:VCALENDAR")
+}
+
+func runCalendar(args []string) {
+	flags := newFlagSet("calendar")
+	input := inputDBFlag(flags)
+	output := flags.String("o", "plays.ics", "write the calendar to `file`")
+	readerFlags(flags)
+	flags.Parse(args)
+
+	_, scores := readAllScores(*input)
+	sessions := playSessions(scores)
+
+	var err error
+	outFile, err = os.Create(*output)
+	logFatalIfError(err)
+	defer outFile.Close()
+	w := bufio.NewWriter(outFile)
+	writeCalendar(w, sessions)
+	logFatalIfError(w.Flush())
+
+	infof("%d play sessions written to %s\n", len(sessions), *output)
+}
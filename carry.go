@@ -0,0 +1,95 @@
+package main
+
+import (
+	"log"
+	"strings"
+
+	"github.com/SirChronus/dtxmania-dbdump/dtxdb"
+)
+
+// chartChanged reports whether the chart file of a record was modified
+// between two versions of the database.
+func chartChanged(old, cur *score) bool {
+	return old.FileInformation.FileSize != cur.FileInformation.FileSize ||
+		old.FileInformation.LastModified != cur.FileInformation.LastModified
+}
+
+func sameSong(a, b *score) bool {
+	return strings.EqualFold(strings.TrimSpace(a.SongInformation.Title), strings.TrimSpace(b.SongInformation.Title)) &&
+		strings.EqualFold(strings.TrimSpace(a.SongInformation.Artist), strings.TrimSpace(b.SongInformation.Artist))
+}
+
+// carryScores copies the best rank, high skill and full combo of every
+// instrument from old to cur where old has the better result. It returns
+// false if cur was already at least as good.
+func carryScores(old, cur *score) bool {
+	from, to := &old.SongInformation, &cur.SongInformation
+	carried := false
+	for _, i := range dtxdb.Instruments {
+		if from.BestRank.Get(i) < to.BestRank.Get(i) {
+			to.BestRank.Set(i, from.BestRank.Get(i))
+			carried = true
+		}
+		if from.HighSkill.Get(i) > to.HighSkill.Get(i) {
+			to.HighSkill.Set(i, from.HighSkill.Get(i))
+			carried = true
+		}
+		if from.FullCombo.Get(i) && !to.FullCombo.Get(i) {
+			to.FullCombo.Set(i, true)
+			carried = true
+		}
+		if from.ScoreExists.Get(i) && !to.ScoreExists.Get(i) {
+			to.ScoreExists.Set(i, true)
+			carried = true
+		}
+	}
+
+	return carried
+}
+
+func runCarry(args []string) {
+	flags := newFlagSet("carry")
+	input := inputDBFlag(flags)
+	from := flags.String("from", "", "songs.db `file` saved before DTXMania re-enumerated the songs")
+	output := outputDBFlag(flags)
+	dryRun := flags.Bool("n", false, "only report the updated charts")
+	readerFlags(flags)
+	flags.Parse(args)
+	if *from == "" {
+		log.Fatalln("carry needs the previous database, see -from")
+	}
+
+	dbs := readDatabases(*from, *input)
+	oldScores, versionString, scores := dbs[0].scores, dbs[1].version, dbs[1].scores
+
+	byPath := make(map[string]*score, len(oldScores))
+	for i := range oldScores {
+		byPath[oldScores[i].FileInformation.AbsoluteFilePath] = &oldScores[i]
+	}
+
+	carried := 0
+	for i := range scores {
+		cur := &scores[i]
+		old, ok := byPath[cur.FileInformation.AbsoluteFilePath]
+		if !ok || !chartChanged(old, cur) {
+			continue
+		}
+		if !sameSong(old, cur) {
+			infof("replaced: %s is no longer %q\n", cur.FileInformation.AbsoluteFilePath, old.SongInformation.Title)
+			continue
+		}
+
+		if carryScores(old, cur) {
+			infof("updated: %s, scores carried forward\n", cur.FileInformation.AbsoluteFilePath)
+			carried++
+		}
+	}
+
+	infof("scores of %d updated charts carried forward\n", carried)
+	if *dryRun {
+		return
+	}
+
+	writeSongsDB(*output, versionString, scores)
+	infof("written %s\n", *output)
+}
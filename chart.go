@@ -0,0 +1,357 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/unicode"
+
+	"github.com/SirChronus/dtxmania-dbdump/dtxdb"
+)
+
+// chartTypes maps the extensions of the chart files DTXMania enumerates to
+// their song type.
+var chartTypes = map[string]dtxdb.SongType{
+	".dtx": dtxdb.DTX,
+	".gda": dtxdb.GDA,
+	".g2d": dtxdb.G2D,
+	".bms": dtxdb.BMS,
+	".bme": dtxdb.BME,
+	".mid": dtxdb.SMF,
+}
+
+// decodeChartText converts the contents of a chart file to UTF-8. Charts
+// are usually Shift_JIS encoded, newer ones are UTF-8 or UTF-16 with a
+// byte order mark.
+func decodeChartText(data []byte) string {
+	switch {
+	case bytes.HasPrefix(data, []byte{0xef, 0xbb, 0xbf}):
+		return string(data[3:])
+	case bytes.HasPrefix(data, []byte{0xff, 0xfe}), bytes.HasPrefix(data, []byte{0xfe, 0xff}):
+		decoded, err := unicode.UTF16(unicode.LittleEndian, unicode.UseBOM).NewDecoder().Bytes(data)
+		if err == nil {
+			return string(decoded)
+		}
+	case utf8.Valid(data):
+		return string(data)
+	}
+
+	decoded, err := japanese.ShiftJIS.NewDecoder().Bytes(data)
+	if err != nil {
+		return string(data)
+	}
+	return string(decoded)
+}
+
+// chartCommand splits a line like "#TITLE: My song" into its upper cased
+// command and its value. ok is false for lines that are no command.
+func chartCommand(line string) (command, value string, ok bool) {
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, "#") {
+		return "", "", false
+	}
+
+	line = line[1:]
+	end := strings.IndexAny(line, ": \t")
+	if end < 0 {
+		return strings.ToUpper(line), "", true
+	}
+	value = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line[end:]), ":"))
+	if comment := strings.Index(value, ";"); comment >= 0 {
+		value = strings.TrimSpace(value[:comment])
+	}
+	return strings.ToUpper(line[:end]), value, true
+}
+
+// parseChartLevel reads a level the way DTXMania does: "85" is level 8.5,
+// newer charts may also give it as "8.53".
+func parseChartLevel(value string, level, dec *int32) {
+	if strings.Contains(value, ".") {
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return
+		}
+		hundredths := int32(f*100 + 0.5)
+		*level, *dec = hundredths/10, hundredths%10
+		return
+	}
+
+	if n, err := strconv.Atoi(value); err == nil {
+		*level = int32(n)
+	}
+}
+
+func parseChartLevelDec(value string, dec *int32) {
+	if n, err := strconv.Atoi(value); err == nil {
+		*dec = int32(n)
+	}
+}
+
+// parseChartHeader fills in the song information found in the header
+// commands of a chart. Commands DTXMania ignores for the song list are
+// skipped, and so are the note data lines.
+func parseChartHeader(text string, info *dtxdb.SongInformation) {
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		command, value, ok := chartCommand(scanner.Text())
+		if !ok {
+			continue
+		}
+
+		switch command {
+		case "TITLE":
+			info.Title = value
+		case "ARTIST":
+			info.Artist = value
+		case "COMMENT":
+			info.Comment = value
+		case "GENRE":
+			info.Genre = value
+		case "PREIMAGE":
+			info.PreImage = value
+		case "PREMOVIE":
+			info.PreMovie = value
+		case "PREVIEW":
+			info.PreSound = value
+		case "BACKGROUND", "WALL":
+			info.Background = value
+		case "DLEVEL", "PLAYLEVEL":
+			parseChartLevel(value, &info.Level.Drums, &info.LevelDec.Drums)
+		case "GLEVEL":
+			parseChartLevel(value, &info.Level.Guitar, &info.LevelDec.Guitar)
+		case "BLEVEL":
+			parseChartLevel(value, &info.Level.Bass, &info.LevelDec.Bass)
+		case "DLVDEC":
+			parseChartLevelDec(value, &info.LevelDec.Drums)
+		case "GLVDEC":
+			parseChartLevelDec(value, &info.LevelDec.Guitar)
+		case "BLVDEC":
+			parseChartLevelDec(value, &info.LevelDec.Bass)
+		case "HIDDENLEVEL":
+			info.HiddenLevel = strings.EqualFold(value, "ON")
+		case "BPM":
+			if bpm, err := strconv.ParseFloat(value, 64); err == nil {
+				info.Bpm = bpm
+			}
+		}
+	}
+}
+
+// newScoreFromChart builds the record DTXMania would enumerate for the
+// chart file at path: the header of the chart, its file information and
+// no play data yet. The duration is left at 0 since computing it requires
+// playing the chart back.
+func newScoreFromChart(path string) (score, error) {
+	var s score
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return s, err
+	}
+	info, err := os.Stat(abs)
+	if err != nil {
+		return s, err
+	}
+
+	s.FileInformation.AbsoluteFilePath = abs
+	s.FileInformation.AbsoluteFolderPath = filepath.Dir(abs) + string(filepath.Separator)
+	s.FileInformation.LastModified = fileDate(info.ModTime())
+	s.FileInformation.FileSize = info.Size()
+	s.SongIniInformation.LastModified = dtxdb.DateFromTicks(0)
+	s.SongInformation.SongType = chartTypes[strings.ToLower(filepath.Ext(abs))]
+	s.SongInformation.BestRank = dtxdb.DGBInt32{Drums: dtxdb.NoRank, Guitar: dtxdb.NoRank, Bass: dtxdb.NoRank}
+
+	// DTXMania keeps the scores of a chart next to it in <chart>.score.ini.
+	if ini, err := os.Stat(abs + ".score.ini"); err == nil {
+		s.SongIniInformation.LastModified = fileDate(ini.ModTime())
+		s.SongIniInformation.FileSize = ini.Size()
+	}
+
+	if s.SongInformation.SongType != dtxdb.SMF {
+		data, err := os.ReadFile(abs)
+		if err != nil {
+			return s, err
+		}
+		parseChartHeader(decodeChartText(data), &s.SongInformation)
+	}
+	if s.SongInformation.Title == "" {
+		s.SongInformation.Title = strings.TrimSuffix(filepath.Base(abs), filepath.Ext(abs))
+	}
+
+	return s, nil
+}
+
+// chartNote is a note of a chart, at seconds from the start of the chart.
+type chartNote struct {
+	part dtxdb.Instrument
+	at   float64
+}
+
+// noteChannelPart returns the instrument playing the notes of a DTX
+// channel. Channels of other objects, like sounds played in the background
+// or wailing, have none.
+func noteChannelPart(channel int64) (dtxdb.Instrument, bool) {
+	switch {
+	case channel >= 0x11 && channel <= 0x1c:
+		return dtxdb.Drums, true
+	case channel >= 0x20 && channel <= 0x27:
+		return dtxdb.Guitar, true
+	case channel >= 0xa0 && channel <= 0xa7:
+		return dtxdb.Bass, true
+	}
+	return 0, false
+}
+
+// chartMeasure parses the measure number of a note data line. Measures
+// from 100 on start with a letter, A0 is 100.
+func chartMeasure(s string) (int, bool) {
+	high, err := strconv.ParseInt(s[:1], 36, 64)
+	if err != nil {
+		return 0, false
+	}
+	low, err := strconv.Atoi(s[1:])
+	if err != nil || low < 0 {
+		return 0, false
+	}
+	return int(high)*100 + low, true
+}
+
+// chartEvent is an object of the note data at pos, the fraction of its
+// measure before it. It is a note of part or a change to bpm, either given
+// directly or as the reference to a #BPMzz definition.
+type chartEvent struct {
+	measure int
+	pos     float64
+	note    bool
+	part    dtxdb.Instrument
+	bpm     float64
+	bpmRef  string
+}
+
+// parseChartNotes reads the notes of a DTX chart and times them, following
+// the BPM changes and the measure lengths of the chart. Notes are returned
+// in the order they are played.
+func parseChartNotes(text string) []chartNote {
+	bpm, baseBPM := 120.0, 0.0
+	bpmDefs := map[string]float64{}
+	lengths := map[int]float64{}
+	var events []chartEvent
+
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		command, value, ok := chartCommand(scanner.Text())
+		if !ok {
+			continue
+		}
+
+		switch {
+		case command == "BPM":
+			if f, err := strconv.ParseFloat(value, 64); err == nil && f > 0 {
+				bpm = f
+			}
+			continue
+		case command == "BASEBPM":
+			if f, err := strconv.ParseFloat(value, 64); err == nil {
+				baseBPM = f
+			}
+			continue
+		case len(command) == 5 && strings.HasPrefix(command, "BPM"):
+			if f, err := strconv.ParseFloat(value, 64); err == nil {
+				bpmDefs[command[3:]] = f
+			}
+			continue
+		case len(command) != 5:
+			continue
+		}
+
+		measure, ok := chartMeasure(command[:3])
+		if !ok {
+			continue
+		}
+		channel, err := strconv.ParseInt(command[3:], 16, 64)
+		if err != nil {
+			continue
+		}
+		if channel == 0x02 {
+			if f, err := strconv.ParseFloat(value, 64); err == nil && f > 0 {
+				lengths[measure] = f
+			}
+			continue
+		}
+		part, isNote := noteChannelPart(channel)
+		if !isNote && channel != 0x03 && channel != 0x08 {
+			continue
+		}
+
+		data := strings.ReplaceAll(value, "_", "")
+		n := len(data) / 2
+		for i := 0; i < n; i++ {
+			object := strings.ToUpper(data[2*i : 2*i+2])
+			if object == "00" {
+				continue
+			}
+			e := chartEvent{measure: measure, pos: float64(i) / float64(n), note: isNote, part: part}
+			switch channel {
+			case 0x03:
+				v, err := strconv.ParseInt(object, 16, 64)
+				if err != nil {
+					continue
+				}
+				e.bpm = float64(v)
+			case 0x08:
+				e.bpmRef = object
+			}
+			events = append(events, e)
+		}
+	}
+
+	// BPM changes come before the notes at the same position.
+	sort.SliceStable(events, func(i, j int) bool {
+		a, b := &events[i], &events[j]
+		if a.measure != b.measure {
+			return a.measure < b.measure
+		}
+		if a.pos != b.pos {
+			return a.pos < b.pos
+		}
+		return !a.note && b.note
+	})
+
+	secondsPerMeasure := func(measure int) float64 {
+		length, ok := lengths[measure]
+		if !ok {
+			length = 1
+		}
+		return 4 * length * 60 / (bpm + baseBPM)
+	}
+	var notes []chartNote
+	at, measure, pos := 0.0, 0, 0.0
+	for _, e := range events {
+		for ; measure < e.measure; measure++ {
+			at += (1 - pos) * secondsPerMeasure(measure)
+			pos = 0
+		}
+		at += (e.pos - pos) * secondsPerMeasure(measure)
+		pos = e.pos
+
+		switch {
+		case e.note:
+			notes = append(notes, chartNote{e.part, at})
+		case e.bpmRef != "":
+			if f, ok := bpmDefs[e.bpmRef]; ok && f+baseBPM > 0 {
+				bpm = f
+			}
+		case e.bpm+baseBPM > 0:
+			bpm = e.bpm
+		}
+	}
+	return notes
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/SirChronus/dtxmania-dbdump/dtxdb"
+)
+
+// readHooks are run by the reader of openSongsDB on every record.
+var readHooks []dtxdb.Hook
+
+// problems collects everything a check finds wrong with a record.
+type problems []string
+
+func (p *problems) addf(format string, a ...interface{}) {
+	*p = append(*p, fmt.Sprintf(format, a...))
+}
+
+func (p problems) err() error {
+	if len(p) == 0 {
+		return nil
+	}
+	return errors.New(strings.Join(p, "; "))
+}
+
+// checkRecord reports values DTXMania never writes, which are left behind
+// by corruption or by tools editing songs.db.
+func checkRecord(s *dtxdb.Score) error {
+	var p problems
+	info := &s.SongInformation
+
+	if !strings.HasPrefix(strings.ToLower(s.FileInformation.AbsoluteFilePath), strings.ToLower(s.FileInformation.AbsoluteFolderPath)) {
+		p.addf("chart is not in folder %s", s.FileInformation.AbsoluteFolderPath)
+	}
+	if s.FileInformation.FileSize < 0 {
+		p.addf("negative file size %d", s.FileInformation.FileSize)
+	}
+	if info.SongType < dtxdb.DTX || info.SongType > dtxdb.SMF {
+		p.addf("unknown song type %d", int32(info.SongType))
+	}
+	for _, i := range dtxdb.Instruments {
+		if l := info.Level.Get(i); l < 0 || l > 100 {
+			p.addf("%s level %d out of range", i, l)
+		}
+		if d := info.LevelDec.Get(i); d < 0 || d > 99 {
+			p.addf("%s level decimals %d out of range", i, d)
+		}
+		if r := info.BestRank.Get(i); (r < 0 || int(r) >= len(dtxdb.RankNames)) && r != dtxdb.NoRank {
+			p.addf("%s rank %d out of range", i, r)
+		}
+		if sk := info.HighSkill.Get(i); sk < 0 || sk > 100 {
+			p.addf("%s skill %.2f out of range", i, sk)
+		}
+		if n := info.NbPerformance.Get(i); n < 0 {
+			p.addf("negative %s play count %d", i, n)
+		}
+	}
+
+	return p.err()
+}
+
+// songRootHook rejects records whose chart is not below one of roots.
+func songRootHook(roots []string) dtxdb.Hook {
+	return func(s *dtxdb.Score) error {
+		path := strings.ToLower(strings.ReplaceAll(s.FileInformation.AbsoluteFilePath, "/", `\`))
+		for _, root := range roots {
+			root = strings.TrimSuffix(strings.ToLower(strings.ReplaceAll(root, "/", `\`)), `\`) + `\`
+			if strings.HasPrefix(path, root) {
+				return nil
+			}
+		}
+		return fmt.Errorf("chart is outside %s", strings.Join(roots, ", "))
+	}
+}
+
+func runCheck(args []string) {
+	flags := newFlagSet("check")
+	input := inputDBFlag(flags)
+	var roots stringList
+	flags.Var(&roots, "root", "report charts outside of the song `folder`, may be repeated")
+	quarantine := flags.String("quarantine", "", "move the records with problems to the songs.db `file`")
+	output := flags.String("o", "", "write the database without the records with problems to `file`")
+	aliasFlag(flags, "output", "o")
+	mmapFlag(flags)
+	readerFlags(flags)
+	overridesFlag(flags)
+	flags.Parse(args)
+
+	readHooks = append(readHooks, checkRecord)
+	if len(roots) > 0 {
+		readHooks = append(readHooks, songRootHook(roots))
+	}
+
+	openSongsDB(*input)
+	defer file.Close()
+
+	var kept, quarantined []score
+	records, bad := 0, 0
+	// stopped is where reading stopped before the end of the database.
+	stopped := int64(-1)
+	for {
+		offset := dbReader.Offset()
+		next, err := dbReader.Next()
+		if err == io.EOF {
+			break
+		}
+		// Neither can be read past.
+		var truncated *dtxdb.ErrTruncatedRecord
+		var invalid *dtxdb.ErrInvalidString
+		if errors.As(err, &truncated) || errors.As(err, &invalid) {
+			fmt.Println(err)
+			records++
+			bad++
+			stopped = offset
+			break
+		}
+		var recordErr *dtxdb.RecordError
+		if !errors.As(err, &recordErr) {
+			logFatalIfError(err)
+		}
+		records++
+		if recordErr != nil {
+			fmt.Printf("%s: %v\n", recordErr.Score.FileInformation.AbsoluteFilePath, recordErr.Err)
+			bad++
+			quarantined = append(quarantined, score{Score: *recordErr.Score})
+			continue
+		}
+		if *output != "" {
+			kept = append(kept, score{Score: *next})
+		}
+	}
+
+	log.Printf("%d of %d records have problems\n", bad, records)
+	if *quarantine != "" {
+		writeSongsDB(*quarantine, dbReader.Version(), quarantined)
+		infof("%d records quarantined in %s\n", len(quarantined), *quarantine)
+	}
+	if *output != "" && stopped >= 0 {
+		// The database written would silently miss whatever follows.
+		log.Printf("not writing %s, the database cannot be read past offset %d\n", *output, stopped)
+	} else if *output != "" {
+		writeSongsDB(*output, dbReader.Version(), kept)
+		infof("written %s without them\n", *output)
+	}
+	if bad > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// compression is a compression dump writes its output through.
+type compression struct {
+	ext       string
+	newWriter func(w io.Writer) io.WriteCloser
+}
+
+// compressions are the compressions of dump -compress.
+var compressions = map[string]compression{
+	"gzip": {".gz", func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) }},
+	"zstd": {".zst", newZstdWriter},
+}
+
+func newZstdWriter(w io.Writer) io.WriteCloser {
+	z, err := zstd.NewWriter(w)
+	// Only invalid options make NewWriter fail.
+	logFatalIfError(err)
+	return z
+}
+
+// lookupCompression returns the compression named name, or without a name
+// the one output is named after. It is nil for no compression.
+func lookupCompression(name, output string) (*compression, error) {
+	if name == "" {
+		for _, c := range compressions {
+			if strings.HasSuffix(output, c.ext) {
+				c := c
+				return &c, nil
+			}
+		}
+		return nil, nil
+	}
+	c, ok := compressions[name]
+	if !ok {
+		var names []string
+		for n := range compressions {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("unknown compression %q, use one of %s", name, strings.Join(names, ", "))
+	}
+	return &c, nil
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// songsDBPath is the database read by the commands unless told otherwise,
+// songs.db in the working directory or the one of the config file.
+var songsDBPath = "songs.db"
+
+// Defaults of dump that the config file may change.
+var (
+	defaultDumpFormat = "xml"
+	defaultDumpOutput = ""
+)
+
+// config is the config file written by setup. Empty fields keep the
+// built-in defaults, flags given on the command line still win.
+type config struct {
+	// Database is the songs.db of the DTXMania install.
+	Database string `json:"database,omitempty"`
+	// Format and Output are the defaults of dump -format and -o.
+	Format string `json:"format,omitempty"`
+	Output string `json:"output,omitempty"`
+	// Encoding is the default of -encoding, for installs whose songs.db
+	// holds Shift JIS strings.
+	Encoding string `json:"encoding,omitempty"`
+	// Filters are named lists of filters, given as -filter @name.
+	Filters map[string][]string `json:"filters,omitempty"`
+}
+
+// configPath returns where the config file is kept: dbdump/config.json in
+// the user config folder, %AppData% on Windows.
+func configPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "dbdump", "config.json"), nil
+}
+
+// loadConfig applies the config file if there is one.
+func loadConfig() error {
+	path, err := configPath()
+	if err != nil {
+		// Without a config folder there is no config either.
+		return nil
+	}
+	c, err := readConfig(path)
+	if err != nil {
+		return err
+	}
+	applyConfig(c)
+	return nil
+}
+
+// readConfig reads the config file at path, a missing file is an empty
+// config.
+func readConfig(path string) (config, error) {
+	var c config
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return c, err
+	}
+	if err := json.Unmarshal(data, &c); err != nil {
+		return c, fmt.Errorf("%s: %v", path, err)
+	}
+	return c, nil
+}
+
+func applyConfig(c config) {
+	if c.Database != "" {
+		songsDBPath = c.Database
+	}
+	if c.Format != "" {
+		defaultDumpFormat = c.Format
+	}
+	if c.Output != "" {
+		defaultDumpOutput = c.Output
+	}
+	if c.Encoding != "" {
+		dbEncoding = c.Encoding
+	}
+	filterPresets = c.Filters
+}
+
+func saveConfig(path string, c config) error {
+	// Filters like level.drums>=50 are kept readable instead of escaping
+	// the > for HTML.
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(c); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+		return err
+	}
+	return os.WriteFile(path, buf.Bytes(), 0666)
+}
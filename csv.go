@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// csvColumns are the fields written by the csv and markdown formats, set
+// with dump -columns. Empty means every field, or markdownColumns.
+var csvColumns columnList
+
+// columnList is a comma separated list of field names.
+type columnList []string
+
+func (l *columnList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *columnList) Set(v string) error {
+	for _, name := range strings.Split(v, ",") {
+		name = strings.TrimSpace(name)
+		if _, ok := lookupField(&score{}, name); !ok {
+			return fmt.Errorf("unknown field %q", name)
+		}
+		*l = append(*l, name)
+	}
+	return nil
+}
+
+// csvWriter writes a header row with the names of the columns and a row of
+// the flattened fields of every record.
+type csvWriter struct {
+	w       *csv.Writer
+	columns []int
+}
+
+// columnIndices returns the positions of the named fields in the fields of
+// scoreFields.
+func columnIndices(columns []string) []int {
+	names := fieldNames()
+	indices := make([]int, 0, len(columns))
+	for _, name := range columns {
+		if alias, ok := fieldAliases[name]; ok {
+			name = alias
+		}
+		for i, n := range names {
+			if n == name {
+				indices = append(indices, i)
+			}
+		}
+	}
+	return indices
+}
+
+func newCSVWriter(w io.Writer) (recordWriter, error) {
+	header := []string(csvColumns)
+	if len(header) == 0 {
+		header = fieldNames()
+	}
+
+	c := &csvWriter{csv.NewWriter(w), columnIndices(header)}
+	if flattenFields {
+		flat := make([]string, len(header))
+		for i, name := range header {
+			flat[i] = sqlColumnName(name)
+		}
+		header = flat
+	}
+	if err := c.w.Write(header); err != nil {
+		return nil, err
+	}
+	c.w.Flush()
+	return c, c.w.Error()
+}
+
+func (c *csvWriter) write(s *score) error {
+	fields := scoreFields(s)
+	row := make([]string, len(c.columns))
+	for i, column := range c.columns {
+		row[i] = fields[column].String()
+	}
+	if err := c.w.Write(row); err != nil {
+		return err
+	}
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// buffered is always 0, every row is flushed.
+func (c *csvWriter) buffered() int {
+	return 0
+}
+
+func (c *csvWriter) close() error {
+	return nil
+}
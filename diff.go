@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+)
+
+// fieldChanges lists the fields whose value differs between two versions of
+// a record, as "field: old -> new".
+func fieldChanges(old, cur *score) []string {
+	var changes []string
+	curFields := scoreFields(cur)
+	for i, f := range scoreFields(old) {
+		if !reflect.DeepEqual(f.value.Interface(), curFields[i].value.Interface()) {
+			changes = append(changes, fmt.Sprintf("%s: %s -> %s", f.name, f, curFields[i]))
+		}
+	}
+	return changes
+}
+
+// printDiff lists the records only in cur, those only in old and the fields
+// that changed in the others. Records are matched by identity.
+func printDiff(w io.Writer, old, cur []score, identity string) (added, removed, changed int) {
+	byKey := recordsByIdentity(identity, old)
+	oldKeys := recordKeys(identity, old)
+
+	for i, key := range recordKeys(identity, cur) {
+		s := &cur[i]
+		o, ok := byKey[key]
+		if !ok {
+			fmt.Fprintf(w, "+ %s\n", s.FileInformation.AbsoluteFilePath)
+			added++
+			continue
+		}
+		delete(byKey, key)
+
+		changes := fieldChanges(o, s)
+		if len(changes) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "~ %s\n", s.FileInformation.AbsoluteFilePath)
+		for _, c := range changes {
+			fmt.Fprintf(w, "    %s\n", c)
+		}
+		changed++
+	}
+
+	for i, key := range oldKeys {
+		if o, ok := byKey[key]; ok && o == &old[i] {
+			fmt.Fprintf(w, "- %s\n", old[i].FileInformation.AbsoluteFilePath)
+			removed++
+		}
+	}
+
+	return added, removed, changed
+}
+
+func runDiff(args []string) {
+	flags := newFlagSet("diff")
+	identity := identityFlag(flags)
+	readerFlags(flags)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: %s diff [flags] old.db new.db\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	logFatalIfError(checkIdentity(*identity))
+	if flags.NArg() != 2 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	dbs := readDatabases(flags.Arg(0), flags.Arg(1))
+	w := bufio.NewWriter(os.Stdout)
+	added, removed, changed := printDiff(w, dbs[0].scores, dbs[1].scores, *identity)
+	logFatalIfError(w.Flush())
+	infof("%d records added, %d removed, %d changed\n", added, removed, changed)
+}
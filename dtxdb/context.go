@@ -0,0 +1,52 @@
+package dtxdb
+
+import (
+	"context"
+	"io"
+)
+
+// DecodeAll reads the version and every record of the database read from r.
+// It stops with the error of ctx once ctx is done, checked before every
+// record, so servers and GUIs can cancel the read of a huge library. The
+// records read before an error are returned with it.
+func DecodeAll(ctx context.Context, r io.Reader, opts ...Option) (string, []*Score, error) {
+	dr, err := NewReader(r, opts...)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var scores []*Score
+	for {
+		if err := ctx.Err(); err != nil {
+			return dr.Version(), scores, err
+		}
+		s, err := dr.Next()
+		if err == io.EOF {
+			return dr.Version(), scores, nil
+		}
+		if err != nil {
+			return dr.Version(), scores, err
+		}
+		scores = append(scores, s)
+	}
+}
+
+// EncodeAll writes a database of the given version and scores to w. Like
+// DecodeAll, it stops with the error of ctx once ctx is done, leaving w
+// with the records written so far.
+func EncodeAll(ctx context.Context, w io.Writer, version string, scores []*Score) error {
+	dw, err := NewWriter(w, version)
+	if err != nil {
+		return err
+	}
+	for _, s := range scores {
+		if err := ctx.Err(); err != nil {
+			dw.Flush()
+			return err
+		}
+		if err := dw.Write(s); err != nil {
+			return err
+		}
+	}
+	return dw.Flush()
+}
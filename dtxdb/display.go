@@ -0,0 +1,37 @@
+package dtxdb
+
+import "fmt"
+
+// RankNames are the ranks DTXMania displays, indexed by the stored rank.
+var RankNames = []string{"SS", "S", "A", "B", "C", "D", "E"}
+
+// RankName returns the rank DTXMania displays for a stored rank, or "-" for
+// NoRank and values it never writes.
+func RankName(rank int32) string {
+	if rank >= 0 && int(rank) < len(RankNames) {
+		return RankNames[rank]
+	}
+	return "-"
+}
+
+// CombinedLevel returns the level of the chart for the given instrument the
+// way DTXMania displays it (e.g. 8.53), or 0 if the chart has no such part.
+func (s *SongInformation) CombinedLevel(i Instrument) float64 {
+	return float64(s.Level.Get(i))/10 + float64(s.LevelDec.Get(i))/100
+}
+
+// BestRankLetter returns the best rank reached on the given instrument as
+// DTXMania displays it, e.g. "SS", or "-" if the chart was never cleared.
+func (s *SongInformation) BestRankLetter(i Instrument) string {
+	return RankName(s.BestRank.Get(i))
+}
+
+// DurationString returns the duration of the song, stored in milliseconds,
+// as minutes and seconds, e.g. "2:05", or "-" if it is unknown.
+func (s *SongInformation) DurationString() string {
+	if s.Duration <= 0 {
+		return "-"
+	}
+	seconds := s.Duration / 1000
+	return fmt.Sprintf("%d:%02d", seconds/60, seconds%60)
+}
@@ -0,0 +1,47 @@
+package dtxdb
+
+import "io"
+
+// Encoder generates a songs.db the way Reader reads one: WriteHeader writes
+// the version, then WriteScore every record in the layout of the version.
+// It is a Writer whose version is given once the Encoder exists.
+type Encoder struct {
+	w  io.Writer
+	dw *Writer
+}
+
+// NewEncoder returns an Encoder writing to w.
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: w}
+}
+
+// WriteHeader writes the version header of the database, e.g. "SongsDB5".
+// It returns an *ErrUnsupportedVersion for a version without a Layout.
+func (e *Encoder) WriteHeader(version string) error {
+	if e.dw != nil {
+		return ErrHeaderOrder
+	}
+	dw, err := NewWriter(e.w, version)
+	if err != nil {
+		return err
+	}
+	e.dw = dw
+	return nil
+}
+
+// WriteScore encodes s. Errors are sticky like those of Writer.Write.
+func (e *Encoder) WriteScore(s *Score) error {
+	if e.dw == nil {
+		return ErrHeaderOrder
+	}
+	return e.dw.Write(s)
+}
+
+// Flush writes the buffered data to the underlying io.Writer, it must be
+// called once every record was written.
+func (e *Encoder) Flush() error {
+	if e.dw == nil {
+		return ErrHeaderOrder
+	}
+	return e.dw.Flush()
+}
@@ -0,0 +1,63 @@
+package dtxdb
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestEncoderRoundTrip(t *testing.T) {
+	scores := []Score{testScore("one"), testScore("two"), testScore("three")}
+
+	var buf bytes.Buffer
+	e := NewEncoder(&buf)
+	if err := e.WriteHeader("SongsDB5"); err != nil {
+		t.Fatal(err)
+	}
+	for i := range scores {
+		if err := e.WriteScore(&scores[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := e.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := encode(t, "SongsDB5", scores); !bytes.Equal(buf.Bytes(), want) {
+		t.Errorf("Encoder wrote\n%x\nwant what Writer writes\n%x", buf.Bytes(), want)
+	}
+	version, decoded := decode(t, buf.Bytes())
+	if version != "SongsDB5" {
+		t.Errorf("version %q, want SongsDB5", version)
+	}
+	if !reflect.DeepEqual(decoded, scores) {
+		t.Errorf("read back\n%+v\nwant\n%+v", decoded, scores)
+	}
+}
+
+func TestEncoderErrors(t *testing.T) {
+	s := testScore("one")
+	tests := []struct {
+		name  string
+		write func(e *Encoder) error
+		want  error
+	}{
+		{"score before header", func(e *Encoder) error { return e.WriteScore(&s) }, ErrHeaderOrder},
+		{"flush before header", func(e *Encoder) error { return e.Flush() }, ErrHeaderOrder},
+		{"header twice", func(e *Encoder) error {
+			if err := e.WriteHeader("SongsDB5"); err != nil {
+				return err
+			}
+			return e.WriteHeader("SongsDB5")
+		}, ErrHeaderOrder},
+		{"unknown version", func(e *Encoder) error { return e.WriteHeader("SongsDB9") }, ErrBadVersion},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.write(NewEncoder(&bytes.Buffer{})); !errors.Is(err, tt.want) {
+				t.Errorf("got %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package dtxdb
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrBadVersion is what errors.Is finds in the *ErrUnsupportedVersion of
+// NewReader and NewWriter.
+var ErrBadVersion = errors.New("dtxdb: unexpected database version")
+
+// ErrUnsupportedVersion is returned by NewReader when the database does not
+// have the version required with WithVersion, or no Layout is registered
+// for its version, and by NewWriter for a version without a Layout.
+type ErrUnsupportedVersion struct {
+	Version string // the version of the database
+	Want    string // the version given with WithVersion, empty without it
+}
+
+func (e *ErrUnsupportedVersion) Error() string {
+	if e.Want == "" {
+		return fmt.Sprintf("%v %q, no layout is known for it", ErrBadVersion, e.Version)
+	}
+	return fmt.Sprintf("%v %q, want %q", ErrBadVersion, e.Version, e.Want)
+}
+
+// Is makes errors.Is(err, ErrBadVersion) hold.
+func (e *ErrUnsupportedVersion) Is(target error) bool {
+	return target == ErrBadVersion
+}
+
+// ErrHeaderOrder is returned by the Encoder methods called before
+// WriteHeader, and by WriteHeader called again.
+var ErrHeaderOrder = errors.New("dtxdb: WriteHeader must be called once, before the records")
+
+// ErrStringTooLong is wrapped in an *ErrInvalidString for strings longer
+// than the limit set with WithMaxStringLen.
+var ErrStringTooLong = errors.New("string too long")
+
+// ErrInvalidUTF8 is wrapped in an *ErrInvalidString for strings that are
+// no UTF-8 when reading with WithStrict.
+var ErrInvalidUTF8 = errors.New("not valid UTF-8")
+
+// ErrStringPastEnd is wrapped in an *ErrInvalidString for strings longer
+// than the rest of a database whose size is known.
+var ErrStringPastEnd = errors.New("string longer than the rest of the database")
+
+// ErrInvalidString is returned for strings that cannot be read: longer
+// than WithMaxStringLen allows or than the rest of the database, not in the
+// encoding of WithEncoding or no UTF-8 with WithStrict. The rest of the
+// database cannot be read after it since the length of the string is
+// likely corrupt.
+type ErrInvalidString struct {
+	Offset int64  // where the string starts
+	Field  string // the field of the string, e.g. "title"
+	Err    error  // ErrStringTooLong, ErrStringPastEnd, ErrInvalidUTF8 or the error of the decoder
+}
+
+func (e *ErrInvalidString) Error() string {
+	return fmt.Sprintf("dtxdb: offset %d: invalid %s: %v", e.Offset, e.Field, e.Err)
+}
+
+func (e *ErrInvalidString) Unwrap() error {
+	return e.Err
+}
+
+// ErrTruncatedRecord is returned when the database ends in the middle of a
+// record, typically because DTXMania was closed while writing it. The
+// records read before are intact.
+type ErrTruncatedRecord struct {
+	Offset int64  // where the database ends
+	Field  string // the field that was being read, e.g. "title"
+}
+
+func (e *ErrTruncatedRecord) Error() string {
+	return fmt.Sprintf("dtxdb: offset %d: record truncated reading %s", e.Offset, e.Field)
+}
+
+// Unwrap makes errors.Is(err, io.ErrUnexpectedEOF) hold.
+func (e *ErrTruncatedRecord) Unwrap() error {
+	return io.ErrUnexpectedEOF
+}
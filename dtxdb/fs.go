@@ -0,0 +1,27 @@
+package dtxdb
+
+import (
+	"context"
+	"io/fs"
+)
+
+// DecodeFS is DecodeAll on the file name of fsys, which may be an embed.FS,
+// a *zip.Reader, an fstest.MapFS or os.DirFS.
+func DecodeFS(ctx context.Context, fsys fs.FS, name string, opts ...Option) (string, []*Score, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return "", nil, err
+	}
+	defer f.Close()
+	return DecodeAll(ctx, f, opts...)
+}
+
+// WalkFS is Walk on the file name of fsys.
+func WalkFS(fsys fs.FS, name string, fn func(*Score) error, opts ...Option) error {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return Walk(f, fn, opts...)
+}
@@ -0,0 +1,38 @@
+package dtxdb
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Hook validates or transforms a record as it is read. It may modify s in
+// place, return ErrSkipRecord to drop the record, or return any other
+// error to reject it.
+type Hook func(s *Score) error
+
+// ErrSkipRecord is returned by hooks to drop a record without an error.
+var ErrSkipRecord = errors.New("dtxdb: skip record")
+
+// RecordError is returned by Next when a hook rejected a record.
+type RecordError struct {
+	Offset int64  // where the record starts
+	Score  *Score // the record as decoded
+	Err    error  // the error returned by the hook
+}
+
+func (e *RecordError) Error() string {
+	return fmt.Sprintf("dtxdb: offset %d: %s: %v", e.Offset, e.Score.FileInformation.AbsoluteFilePath, e.Err)
+}
+
+func (e *RecordError) Unwrap() error {
+	return e.Err
+}
+
+func (r *Reader) runHooks(s *Score) error {
+	for _, h := range r.hooks {
+		if err := h(s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package dtxdb
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Instrument selects one of the values of a DGB.
+type Instrument int
+
+const (
+	Drums Instrument = iota
+	Guitar
+	Bass
+)
+
+// Instruments lists every instrument in the order songs.db stores them.
+var Instruments = []Instrument{Drums, Guitar, Bass}
+
+func (i Instrument) String() string {
+	return [...]string{"drums", "guitar", "bass"}[i]
+}
+
+// ParseInstrument returns the instrument with the given name, ignoring case.
+func ParseInstrument(name string) (Instrument, error) {
+	for _, i := range Instruments {
+		if strings.EqualFold(name, i.String()) {
+			return i, nil
+		}
+	}
+
+	return 0, fmt.Errorf("unknown instrument %q", name)
+}
+
+// Get returns the value of instrument i.
+func (d DGB[T]) Get(i Instrument) T {
+	return [...]T{d.Drums, d.Guitar, d.Bass}[i]
+}
+
+// Set sets the value of instrument i.
+func (d *DGB[T]) Set(i Instrument, v T) {
+	*[...]*T{&d.Drums, &d.Guitar, &d.Bass}[i] = v
+}
+
+// NoRank is the best rank DTXMania stores for charts that were never
+// cleared. Lower ranks are better, 0 being SS.
+const NoRank = 99
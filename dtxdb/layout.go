@@ -0,0 +1,92 @@
+package dtxdb
+
+// Layout describes the records of a database version: the fields they
+// store, in the order they are stored. Reader and Writer decode and encode
+// records through the layout of the version of the database.
+type Layout struct {
+	Version string
+	Fields  []LayoutField
+}
+
+// LayoutField is a field of a record. Value returns where the field is in
+// s, one of *string, *int32, *int64, *float64, *bool, *Date or *SongType,
+// which also tells how it is stored. Fields of Score a layout has none for
+// keep their zero value when read.
+type LayoutField struct {
+	Name  string // e.g. "title" or "level.drums", as in errors
+	Value func(s *Score) interface{}
+}
+
+var layouts = map[string]*Layout{}
+
+// RegisterLayout makes Reader and Writer use l for databases of version
+// l.Version, which is added to SupportedVersions if it was not there.
+func RegisterLayout(l *Layout) {
+	if _, ok := layouts[l.Version]; !ok {
+		SupportedVersions = append(SupportedVersions, l.Version)
+	}
+	layouts[l.Version] = l
+}
+
+// LookupLayout returns the layout registered for version.
+func LookupLayout(version string) (*Layout, bool) {
+	l, ok := layouts[version]
+	return l, ok
+}
+
+func dgbFields[T any](name string, value func(s *Score) *DGB[T]) []LayoutField {
+	return []LayoutField{
+		{name + ".drums", func(s *Score) interface{} { return &value(s).Drums }},
+		{name + ".guitar", func(s *Score) interface{} { return &value(s).Guitar }},
+		{name + ".bass", func(s *Score) interface{} { return &value(s).Bass }},
+	}
+}
+
+// songsDB5Fields is the layout of SongsDB5, the version of DTXMania
+// releases since 2010.
+func songsDB5Fields() []LayoutField {
+	info := func(s *Score) *SongInformation { return &s.SongInformation }
+	history := func(s *Score) *PerformanceHistory { return &s.SongInformation.PerformanceHistory }
+
+	fields := []LayoutField{
+		{"file-info.absolute-file-path", func(s *Score) interface{} { return &s.FileInformation.AbsoluteFilePath }},
+		{"file-info.absolute-folder-path", func(s *Score) interface{} { return &s.FileInformation.AbsoluteFolderPath }},
+		{"file-info.last-modified", func(s *Score) interface{} { return &s.FileInformation.LastModified }},
+		{"file-info.file-size", func(s *Score) interface{} { return &s.FileInformation.FileSize }},
+		{"song-ini-info.last-modified", func(s *Score) interface{} { return &s.SongIniInformation.LastModified }},
+		{"song-ini-info.file-size", func(s *Score) interface{} { return &s.SongIniInformation.FileSize }},
+		{"title", func(s *Score) interface{} { return &info(s).Title }},
+		{"artist", func(s *Score) interface{} { return &info(s).Artist }},
+		{"comment", func(s *Score) interface{} { return &info(s).Comment }},
+		{"genre", func(s *Score) interface{} { return &info(s).Genre }},
+		{"pre-image", func(s *Score) interface{} { return &info(s).PreImage }},
+		{"pre-movie", func(s *Score) interface{} { return &info(s).PreMovie }},
+		{"pre-sound", func(s *Score) interface{} { return &info(s).PreSound }},
+		{"background", func(s *Score) interface{} { return &info(s).Background }},
+	}
+	fields = append(fields, dgbFields("level", func(s *Score) *DGBInt32 { return &info(s).Level })...)
+	fields = append(fields, dgbFields("level-dec", func(s *Score) *DGBInt32 { return &info(s).LevelDec })...)
+	fields = append(fields, dgbFields("best-rank", func(s *Score) *DGBInt32 { return &info(s).BestRank })...)
+	fields = append(fields, dgbFields("high-skill", func(s *Score) *DGBDouble { return &info(s).HighSkill })...)
+	fields = append(fields, dgbFields("full-combo", func(s *Score) *DGBBoolean { return &info(s).FullCombo })...)
+	fields = append(fields, dgbFields("nb-performance", func(s *Score) *DGBInt32 { return &info(s).NbPerformance })...)
+	fields = append(fields,
+		LayoutField{"performance-history.first", func(s *Score) interface{} { return &history(s).First }},
+		LayoutField{"performance-history.second", func(s *Score) interface{} { return &history(s).Second }},
+		LayoutField{"performance-history.third", func(s *Score) interface{} { return &history(s).Third }},
+		LayoutField{"performance-history.fourth", func(s *Score) interface{} { return &history(s).Fourth }},
+		LayoutField{"performance-history.fifth", func(s *Score) interface{} { return &history(s).Fifth }},
+		LayoutField{"hidden-level", func(s *Score) interface{} { return &info(s).HiddenLevel }},
+	)
+	fields = append(fields, dgbFields("classic", func(s *Score) *DGBBoolean { return &info(s).Classic })...)
+	fields = append(fields, dgbFields("score-exists", func(s *Score) *DGBBoolean { return &info(s).ScoreExists })...)
+	return append(fields,
+		LayoutField{"song-type", func(s *Score) interface{} { return &info(s).SongType }},
+		LayoutField{"bpm", func(s *Score) interface{} { return &info(s).Bpm }},
+		LayoutField{"duration", func(s *Score) interface{} { return &info(s).Duration }},
+	)
+}
+
+func init() {
+	layouts["SongsDB5"] = &Layout{"SongsDB5", songsDB5Fields()}
+}
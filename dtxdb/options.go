@@ -0,0 +1,63 @@
+package dtxdb
+
+import "golang.org/x/text/encoding"
+
+// Option configures a Reader created with NewReader.
+type Option func(*options)
+
+type options struct {
+	encoding     encoding.Encoding
+	version      string
+	fallback     string
+	maxStringLen int
+	strict       bool
+	timing       *Timing
+	hooks        []Hook
+}
+
+// WithEncoding decodes the strings of the database from e. DTXMania writes
+// UTF-8, which is the default, but some modified builds use the code page
+// of the system instead.
+func WithEncoding(e encoding.Encoding) Option {
+	return func(o *options) { o.encoding = e }
+}
+
+// WithVersion makes NewReader fail unless the database has the version
+// string v, e.g. "SongsDB5".
+func WithVersion(v string) Option {
+	return func(o *options) { o.version = v }
+}
+
+// WithFallbackLayout reads databases of versions no Layout is registered
+// for with the layout of version v, instead of NewReader failing with an
+// *ErrUnsupportedVersion. Their records are garbled unless the database
+// stores the same fields as v.
+func WithFallbackLayout(v string) Option {
+	return func(o *options) { o.fallback = v }
+}
+
+// WithMaxStringLen makes reading fail on strings longer than n bytes,
+// which are found in corrupt databases. Zero is no limit, the default.
+func WithMaxStringLen(n int) Option {
+	return func(o *options) { o.maxStringLen = n }
+}
+
+// WithStrict makes reading fail on values DTXMania never writes, which it
+// reads anyway: strings that are no UTF-8 unless WithEncoding is given,
+// booleans stored as another byte than 0 or 1 and unknown song types.
+func WithStrict(strict bool) Option {
+	return func(o *options) { o.strict = strict }
+}
+
+// WithTiming adds the time spent reading records to t. I/O is only told
+// apart from decoding for readers that get buffered, in-memory readers
+// count as decoding.
+func WithTiming(t *Timing) Option {
+	return func(o *options) { o.timing = t }
+}
+
+// WithHook runs h on every record Next decodes. Hooks run in the order they
+// were given, each seeing the changes of the ones before.
+func WithHook(h Hook) Option {
+	return func(o *options) { o.hooks = append(o.hooks, h) }
+}
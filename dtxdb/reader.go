@@ -0,0 +1,361 @@
+package dtxdb
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/fs"
+	"math"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+)
+
+// source is what a Reader reads from. Readers that do not buffer are
+// wrapped in a bufio.Reader, in-memory ones like bytes.Reader are used as
+// they are.
+type source interface {
+	io.Reader
+	io.ByteScanner
+}
+
+// SupportedVersions lists the database versions a Layout is registered
+// for. NewReader fails on databases of other versions unless
+// WithFallbackLayout is given.
+var SupportedVersions = []string{"SongsDB5"}
+
+// Reader decodes the records of a songs.db.
+type Reader struct {
+	r       source
+	offset  int64
+	size    int64 // the bytes in r when known, else -1
+	version string
+	layout  *Layout
+	err     error
+	timing  *Timing
+	decoder *encoding.Decoder
+	maxLen  int
+	strict  bool
+	hooks   []Hook
+}
+
+// NewReader reads the version header of the database read from r. The
+// records are then read with Next.
+func NewReader(r io.Reader, opts ...Option) (*Reader, error) {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	size := sizeOf(r)
+	s, ok := r.(source)
+	if !ok {
+		if o.timing != nil {
+			r = timedReader{r, o.timing}
+		}
+		s = bufio.NewReader(r)
+	}
+
+	dr := &Reader{r: s, size: size, timing: o.timing, maxLen: o.maxStringLen, strict: o.strict, hooks: o.hooks}
+	if o.encoding != nil {
+		dr.decoder = o.encoding.NewDecoder()
+	}
+	dr.version = dr.readString("version")
+	if dr.err != nil {
+		return nil, dr.err
+	}
+	if o.version != "" && dr.version != o.version {
+		return nil, &ErrUnsupportedVersion{dr.version, o.version}
+	}
+	l, ok := LookupLayout(dr.version)
+	if !ok && o.fallback != "" {
+		l, ok = LookupLayout(o.fallback)
+	}
+	if !ok {
+		return nil, &ErrUnsupportedVersion{Version: dr.version}
+	}
+	dr.layout = l
+	return dr, nil
+}
+
+// sizeOf returns the number of bytes left in r if r is in memory or a
+// regular file, or -1 if it cannot tell.
+func sizeOf(r io.Reader) int64 {
+	switch r := r.(type) {
+	case interface{ Len() int }:
+		return int64(r.Len())
+	case interface {
+		io.Seeker
+		Stat() (fs.FileInfo, error)
+	}:
+		info, err := r.Stat()
+		if err != nil || !info.Mode().IsRegular() {
+			return -1
+		}
+		pos, err := r.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return -1
+		}
+		return info.Size() - pos
+	}
+	return -1
+}
+
+// Version returns the version string stored at the start of the database.
+func (r *Reader) Version() string {
+	return r.version
+}
+
+// Layout returns the layout the records are read with, that of
+// WithFallbackLayout for databases of a version without one.
+func (r *Reader) Layout() *Layout {
+	return r.layout
+}
+
+// Offset returns the number of bytes read so far, which is the offset of
+// the next record after a call to Next.
+func (r *Reader) Offset() int64 {
+	return r.offset
+}
+
+// Next decodes the next record and runs the hooks given with WithHook on
+// it. It returns io.EOF once every record has been read and an
+// *ErrTruncatedRecord if the database ends within a record. These errors
+// are sticky, once Next failed it keeps failing. A *RecordError from a hook
+// only concerns its record, the next call continues with the following one.
+func (r *Reader) Next() (*Score, error) {
+	for {
+		offset := r.offset
+		s, err := r.next()
+		if err != nil {
+			return nil, err
+		}
+
+		err = r.runHooks(s)
+		if err == ErrSkipRecord {
+			continue
+		}
+		if err != nil {
+			return nil, &RecordError{offset, s, err}
+		}
+		return s, nil
+	}
+}
+
+func (r *Reader) next() (*Score, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	if _, err := r.r.ReadByte(); err != nil {
+		if err != io.EOF {
+			r.fail("record", err)
+		} else {
+			r.err = io.EOF
+		}
+		return nil, r.err
+	}
+	if err := r.r.UnreadByte(); err != nil {
+		return nil, err
+	}
+
+	s := &Score{}
+	sw := r.startTiming()
+	r.readScore(s)
+	if r.timing != nil {
+		r.timing.Records += r.elapsed(sw)
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	return s, nil
+}
+
+// UnmarshalBinary decodes a single record stored as MarshalBinary returns
+// it, in the layout of the first of SupportedVersions. Unlike Next, it
+// fails on bytes left after the record.
+func (s *Score) UnmarshalBinary(data []byte) error {
+	r := &Reader{r: bytes.NewReader(data), size: int64(len(data)), layout: layouts[SupportedVersions[0]]}
+	var decoded Score
+	r.readScore(&decoded)
+	if r.err != nil {
+		return r.err
+	}
+	if r.offset != int64(len(data)) {
+		return fmt.Errorf("dtxdb: %d bytes after the record", int64(len(data))-r.offset)
+	}
+	*s = decoded
+	return nil
+}
+
+// fail records the first error encountered while reading field, later
+// reads are no-ops.
+func (r *Reader) fail(field string, err error) {
+	if r.err != nil {
+		return
+	}
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		r.err = &ErrTruncatedRecord{r.offset, field}
+		return
+	}
+	r.err = fmt.Errorf("dtxdb: offset %d: reading %s: %w", r.offset, field, err)
+}
+
+func (r *Reader) readFull(field string, b []byte) bool {
+	if r.err != nil {
+		return false
+	}
+
+	n, err := io.ReadFull(r.r, b)
+	r.offset += int64(n)
+	if err != nil {
+		r.fail(field, err)
+		return false
+	}
+	return true
+}
+
+// maxPreallocatedString is the largest string length trusted enough to
+// allocate its buffer upfront. Longer strings are read in chunks, so a
+// corrupt length fails with a short read instead of exhausting memory.
+const maxPreallocatedString = 64 * 1024
+
+func (r *Reader) readString(field string) string {
+	if r.err != nil {
+		return ""
+	}
+	if r.timing != nil {
+		sw := r.startTiming()
+		defer func() { r.timing.Strings += r.elapsed(sw) }()
+	}
+
+	start := r.offset
+	counter := &byteCounter{r: r.r}
+	length, err := binary.ReadUvarint(counter)
+	r.offset += counter.n
+	if err != nil {
+		r.fail(field, err)
+		return ""
+	}
+
+	if r.maxLen > 0 && length > uint64(r.maxLen) {
+		r.err = &ErrInvalidString{start, field, fmt.Errorf("%w: %d bytes, the limit is %d", ErrStringTooLong, length, r.maxLen)}
+		return ""
+	}
+	if r.size >= 0 && length > uint64(r.size-r.offset) {
+		r.err = &ErrInvalidString{start, field, fmt.Errorf("%w: %d bytes, %d are left", ErrStringPastEnd, length, r.size-r.offset)}
+		return ""
+	}
+
+	var v []byte
+	if length <= maxPreallocatedString {
+		v = make([]byte, length)
+		if !r.readFull(field, v) {
+			return ""
+		}
+	} else {
+		var buf bytes.Buffer
+		n, err := io.CopyN(&buf, r.r, int64(length&math.MaxInt64))
+		r.offset += n
+		if err != nil {
+			r.fail(field, err)
+			return ""
+		}
+		v = buf.Bytes()
+	}
+
+	if r.decoder != nil {
+		decoded, err := r.decoder.Bytes(v)
+		if err != nil {
+			r.err = &ErrInvalidString{start, field, err}
+			return ""
+		}
+		v = decoded
+	} else if r.strict && !utf8.Valid(v) {
+		r.err = &ErrInvalidString{start, field, ErrInvalidUTF8}
+		return ""
+	}
+	return string(v)
+}
+
+func (r *Reader) readSignedInt64(field string) int64 {
+	valueAsBytes := make([]byte, 8)
+	r.readFull(field, valueAsBytes)
+
+	return int64(binary.LittleEndian.Uint64(valueAsBytes))
+}
+
+func (r *Reader) readSignedInt32(field string) int32 {
+	valueAsBytes := make([]byte, 4)
+	r.readFull(field, valueAsBytes)
+
+	return int32(binary.LittleEndian.Uint32(valueAsBytes))
+}
+
+func (r *Reader) readDouble(field string) float64 {
+	valueAsBytes := make([]byte, 8)
+	r.readFull(field, valueAsBytes)
+
+	return math.Float64frombits(binary.LittleEndian.Uint64(valueAsBytes))
+}
+
+func (r *Reader) readBool(field string) bool {
+	valueAsBytes := make([]byte, 1)
+	r.readFull(field, valueAsBytes)
+
+	if r.strict && valueAsBytes[0] > 1 {
+		r.fail(field, fmt.Errorf("invalid boolean %d", valueAsBytes[0]))
+	}
+	return valueAsBytes[0] != 0
+}
+
+func (r *Reader) readDate(field string) Date {
+	return DateFromTicks(r.readSignedInt64(field))
+}
+
+// readField reads the field of a layout, whose Value tells how it is
+// stored.
+func (r *Reader) readField(f LayoutField, s *Score) {
+	switch v := f.Value(s).(type) {
+	case *string:
+		*v = r.readString(f.Name)
+	case *int32:
+		*v = r.readSignedInt32(f.Name)
+	case *int64:
+		*v = r.readSignedInt64(f.Name)
+	case *float64:
+		*v = r.readDouble(f.Name)
+	case *bool:
+		*v = r.readBool(f.Name)
+	case *Date:
+		*v = r.readDate(f.Name)
+	case *SongType:
+		*v = SongType(r.readSignedInt32(f.Name))
+		if r.strict && r.err == nil && !v.known() {
+			r.fail(f.Name, fmt.Errorf("unknown song type %d", int32(*v)))
+		}
+	default:
+		panic(fmt.Sprintf("dtxdb: field %s of layout has type %T", f.Name, v))
+	}
+}
+
+func (r *Reader) readScore(s *Score) {
+	for _, f := range r.layout.Fields {
+		r.readField(f, s)
+	}
+}
+
+// byteCounter counts the bytes binary.ReadUvarint consumes.
+type byteCounter struct {
+	r source
+	n int64
+}
+
+func (c *byteCounter) ReadByte() (byte, error) {
+	b, err := c.r.ReadByte()
+	if err == nil {
+		c.n++
+	}
+	return b, err
+}
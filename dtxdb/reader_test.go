@@ -0,0 +1,271 @@
+package dtxdb
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// FuzzReader decodes the input as a database and encodes every record to
+// XML the way the dump does; any panic is a bug. The seeds are in
+// testdata/fuzz/FuzzReader, minimised crashers belong there too once fixed.
+func FuzzReader(f *testing.F) {
+	f.Fuzz(func(t *testing.T, data []byte) {
+		r, err := NewReader(bytes.NewReader(data))
+		if err != nil {
+			return
+		}
+		for {
+			s, err := r.Next()
+			if err != nil {
+				return
+			}
+			if _, err := xml.Marshal(s); err != nil {
+				t.Fatalf("marshaling record at offset %d: %v", r.Offset(), err)
+			}
+		}
+	})
+}
+
+// testScore returns a record with every field set.
+func testScore(title string) Score {
+	var s Score
+	s.FileInformation = FileInformation{`C:\DTXFiles\` + title + `\bas.dtx`, `C:\DTXFiles\` + title + `\`, DateFromTicks(637800000000000000), 12345}
+	s.SongIniInformation = SongIniInformation{DateFromTicks(637800000010000000), 678}
+	info := &s.SongInformation
+	info.Title, info.Artist, info.Comment, info.Genre = title, "Artist", "コメント", "Anime"
+	info.PreImage, info.PreMovie, info.PreSound, info.Background = "pre.png", "pre.avi", "pre.ogg", "bg.png"
+	info.Level = DGBInt32{85, 70, 60}
+	info.LevelDec = DGBInt32{5, 0, 3}
+	info.BestRank = DGBInt32{1, 99, 99}
+	info.HighSkill = DGBDouble{142.5, 0, -1}
+	info.FullCombo = DGBBoolean{true, false, false}
+	info.NbPerformance = DGBInt32{12, 0, 1}
+	info.PerformanceHistory = PerformanceHistory{"22/01/02 Drums:Cleared", "", "", "", ""}
+	info.HiddenLevel = true
+	info.Classic = DGBBoolean{false, true, false}
+	info.ScoreExists = DGBBoolean{true, false, true}
+	info.SongType = BMS
+	info.Bpm = 180.5
+	info.Duration = 123456
+	return s
+}
+
+// encode writes a database of the given version and records.
+func encode(t *testing.T, version string, scores []Score) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, version)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range scores {
+		if err := w.Write(&scores[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// decode reads every record of data.
+func decode(t *testing.T, data []byte, opts ...Option) (string, []Score) {
+	t.Helper()
+	r, err := NewReader(bytes.NewReader(data), opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var scores []Score
+	for {
+		s, err := r.Next()
+		if err == io.EOF {
+			return r.Version(), scores
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		scores = append(scores, *s)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	long := testScore("long")
+	long.SongInformation.Comment = strings.Repeat("x", maxPreallocatedString+1)
+	invalid := testScore("invalid")
+	invalid.SongInformation.Title = "\xed\xa0\x80 \x01"
+	empty := Score{}
+	empty.FileInformation.LastModified = DateFromTicks(0)
+	empty.SongIniInformation.LastModified = DateFromTicks(0)
+
+	tests := []struct {
+		name   string
+		scores []Score
+	}{
+		{"no records", nil},
+		{"one record", []Score{testScore("one")}},
+		{"several records", []Score{testScore("one"), testScore("two"), testScore("three")}},
+		{"empty record", []Score{empty}},
+		{"long string", []Score{long}},
+		{"invalid UTF-8", []Score{invalid}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := encode(t, "SongsDB5", tt.scores)
+			version, scores := decode(t, data)
+			if version != "SongsDB5" {
+				t.Errorf("version %q, want SongsDB5", version)
+			}
+			if len(scores) != len(tt.scores) {
+				t.Fatalf("read %d records, want %d", len(scores), len(tt.scores))
+			}
+			for i := range scores {
+				if !reflect.DeepEqual(scores[i], tt.scores[i]) {
+					t.Errorf("record %d is\n%+v\nwant\n%+v", i, scores[i], tt.scores[i])
+				}
+			}
+			if again := encode(t, version, scores); !bytes.Equal(again, data) {
+				t.Errorf("written again the database differs:\n%x\nwant\n%x", again, data)
+			}
+		})
+	}
+}
+
+func TestMarshalBinary(t *testing.T) {
+	s := testScore("one")
+	data, err := s.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if db := encode(t, "SongsDB5", []Score{s}); !bytes.HasSuffix(db, data) || len(db)-len(data) != len("SongsDB5")+1 {
+		t.Errorf("MarshalBinary is not the record as stored in a database")
+	}
+
+	var decoded Score
+	if err := decoded.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, s) {
+		t.Errorf("UnmarshalBinary gives\n%+v\nwant\n%+v", decoded, s)
+	}
+	if err := decoded.UnmarshalBinary(append(data, 0)); err == nil {
+		t.Errorf("UnmarshalBinary accepts a byte after the record")
+	}
+}
+
+func TestWriterVersion(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, "SongsDB9")
+	var e *ErrUnsupportedVersion
+	if w != nil || !errors.As(err, &e) || e.Version != "SongsDB9" || buf.Len() != 0 {
+		t.Errorf("NewWriter of SongsDB9 gives %v with %q written, want an *ErrUnsupportedVersion and nothing", err, buf.Bytes())
+	}
+
+	s := testScore("one")
+	w = NewLayoutWriter(&buf, "SongsDB9", layouts["SongsDB5"])
+	if err := w.Write(&s); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	version, scores := decode(t, buf.Bytes(), WithFallbackLayout("SongsDB5"))
+	if version != "SongsDB9" || len(scores) != 1 || !reflect.DeepEqual(scores[0], s) {
+		t.Errorf("NewLayoutWriter wrote %q with %d records, want SongsDB9 with the record", version, len(scores))
+	}
+}
+
+// onlyReader hides the type of the reader, as for a pipe whose size is
+// unknown.
+type onlyReader struct {
+	io.Reader
+}
+
+func TestErrors(t *testing.T) {
+	db := encode(t, "SongsDB5", []Score{testScore("one"), testScore("two")})
+	records := bytes.TrimPrefix(db, []byte("\x08SongsDB5"))
+	// A title longer than the rest of the database: the length of the
+	// first string of the record is followed by the path.
+	corrupt := append([]byte("\x08SongsDB5\xff\x7f"), records[1:]...)
+	unknown := append([]byte("\x08SongsDB9"), records...)
+
+	tests := []struct {
+		name  string
+		r     io.Reader
+		opts  []Option
+		check func(t *testing.T, err error)
+	}{
+		{"truncated", bytes.NewReader(db[:len(db)-3]), nil, func(t *testing.T, err error) {
+			var e *ErrTruncatedRecord
+			if !errors.As(err, &e) || e.Field != "duration" || e.Offset != int64(len(db)-3) {
+				t.Errorf("got %v, want an *ErrTruncatedRecord reading duration at %d", err, len(db)-3)
+			}
+			if !errors.Is(err, io.ErrUnexpectedEOF) {
+				t.Errorf("errors.Is(%v, io.ErrUnexpectedEOF) is false", err)
+			}
+		}},
+		{"other version", bytes.NewReader(db), []Option{WithVersion("SongsDB4")}, func(t *testing.T, err error) {
+			var e *ErrUnsupportedVersion
+			if !errors.As(err, &e) || e.Version != "SongsDB5" || e.Want != "SongsDB4" {
+				t.Errorf("got %v, want an *ErrUnsupportedVersion of SongsDB5 wanting SongsDB4", err)
+			}
+			if !errors.Is(err, ErrBadVersion) {
+				t.Errorf("errors.Is(%v, ErrBadVersion) is false", err)
+			}
+		}},
+		{"unknown version", bytes.NewReader(unknown), nil, func(t *testing.T, err error) {
+			var e *ErrUnsupportedVersion
+			if !errors.As(err, &e) || e.Version != "SongsDB9" || e.Want != "" {
+				t.Errorf("got %v, want an *ErrUnsupportedVersion of SongsDB9", err)
+			}
+		}},
+		{"fallback layout", bytes.NewReader(unknown), []Option{WithFallbackLayout("SongsDB5")}, func(t *testing.T, err error) {
+			if err != nil {
+				t.Errorf("got %v, want the records read as SongsDB5", err)
+			}
+		}},
+		{"string too long", bytes.NewReader(db), []Option{WithMaxStringLen(10)}, func(t *testing.T, err error) {
+			var e *ErrInvalidString
+			if !errors.As(err, &e) || e.Field != "file-info.absolute-file-path" || e.Offset != 9 {
+				t.Errorf("got %v, want an *ErrInvalidString of the path at 9", err)
+			}
+			if !errors.Is(err, ErrStringTooLong) {
+				t.Errorf("errors.Is(%v, ErrStringTooLong) is false", err)
+			}
+		}},
+		{"string past the end", bytes.NewReader(corrupt), nil, func(t *testing.T, err error) {
+			if !errors.Is(err, ErrStringPastEnd) {
+				t.Errorf("got %v, want ErrStringPastEnd", err)
+			}
+		}},
+		{"string past the end of a stream", onlyReader{bytes.NewReader(corrupt)}, nil, func(t *testing.T, err error) {
+			var e *ErrTruncatedRecord
+			if !errors.As(err, &e) {
+				t.Errorf("got %v, want an *ErrTruncatedRecord", err)
+			}
+		}},
+		{"invalid UTF-8", bytes.NewReader(encode(t, "SongsDB5", []Score{{FileInformation: FileInformation{AbsoluteFilePath: "\xff"}}})), []Option{WithStrict(true)}, func(t *testing.T, err error) {
+			var e *ErrInvalidString
+			if !errors.As(err, &e) || !errors.Is(err, ErrInvalidUTF8) {
+				t.Errorf("got %v, want an *ErrInvalidString wrapping ErrInvalidUTF8", err)
+			}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := NewReader(tt.r, tt.opts...)
+			for err == nil {
+				_, err = r.Next()
+			}
+			if err == io.EOF {
+				err = nil
+			}
+			tt.check(t, err)
+		})
+	}
+}
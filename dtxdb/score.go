@@ -0,0 +1,254 @@
+// Package dtxdb reads and writes the songs.db cache DTXMania keeps of the
+// songs it enumerated.
+package dtxdb
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SongType is the format of the chart file of a song.
+type SongType int32
+
+const (
+	DTX SongType = iota
+	GDA
+	G2D
+	BMS
+	BME
+	SMF
+)
+
+var songTypeNames = [...]string{"DTX", "GDA", "G2D", "BMS", "BME", "SMF"}
+
+// known tells whether e is one of the song types DTXMania writes.
+func (e SongType) known() bool {
+	return e >= 0 && int(e) < len(songTypeNames)
+}
+
+func (e SongType) String() string {
+	if !e.known() {
+		// Only seen in corrupt databases.
+		return fmt.Sprintf("SongType(%d)", int32(e))
+	}
+	return songTypeNames[e]
+}
+
+func (e SongType) MarshalXML(enc *xml.Encoder, start xml.StartElement) error {
+	return enc.EncodeElement(e.String(), start)
+}
+
+func (e SongType) MarshalJSON() ([]byte, error) {
+	return json.Marshal(e.String())
+}
+
+// UnmarshalJSON reads song types back from JSON dumps.
+func (e *SongType) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err != nil {
+		return err
+	}
+	t, err := ParseSongType(name)
+	if err != nil {
+		return err
+	}
+	*e = t
+	return nil
+}
+
+// UnmarshalXML reads song types back from dumps.
+func (e *SongType) UnmarshalXML(dec *xml.Decoder, start xml.StartElement) error {
+	var name string
+	if err := dec.DecodeElement(&name, &start); err != nil {
+		return err
+	}
+	t, err := ParseSongType(name)
+	if err != nil {
+		return err
+	}
+	*e = t
+	return nil
+}
+
+// ParseSongType returns the song type with the given name, ignoring case,
+// or written the way String writes unknown types.
+func ParseSongType(name string) (SongType, error) {
+	for i, n := range songTypeNames {
+		if strings.EqualFold(n, name) {
+			return SongType(i), nil
+		}
+	}
+	var t SongType
+	if _, err := fmt.Sscanf(name, "SongType(%d)", (*int32)(&t)); err != nil {
+		return 0, fmt.Errorf("dtxdb: unknown song type %q", name)
+	}
+	return t, nil
+}
+
+// Date is a timestamp stored in songs.db. DTXMania stores the local wall
+// clock time, which is held as UTC so it shows as DTXMania wrote it. Dumps
+// hold it formatted as RFC 3339.
+type Date struct {
+	time.Time
+}
+
+// TicksPerSecond is the number of C# DateTime ticks, 100ns each, in a
+// second.
+const TicksPerSecond = 10000000
+
+var baseTime = time.Date(1, 1, 1, 0, 0, 0, 0, time.UTC).Unix()
+
+// DateFromTicks converts a C# DateTime tick count as stored in songs.db.
+func DateFromTicks(dateTime int64) Date {
+	// Convert from C# tick time to proper UTC timestamp, a tick is 100ns
+	return Date{time.Unix(dateTime/TicksPerSecond+baseTime, dateTime%TicksPerSecond*100).UTC()}
+}
+
+// DateFromTime returns the date of t, in UTC so dates compare equal with ==
+// whenever they are the same instant.
+func DateFromTime(t time.Time) Date {
+	return Date{t.UTC()}
+}
+
+// gregorianCycle is the number of years after which the Gregorian calendar
+// repeats, leap days included.
+const gregorianCycle = 400
+
+// ParseDate parses a date formatted as String does, including the years
+// outside of 0 to 9999 that time.Parse rejects.
+func ParseDate(s string) (Date, error) {
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err == nil {
+		return DateFromTime(t), nil
+	}
+
+	// Move other years into range by whole cycles, which leaves the month
+	// and day as they are, and move them back after parsing.
+	sign := 0
+	if strings.HasPrefix(s, "-") {
+		sign = 1
+	}
+	i := strings.IndexByte(s[sign:], '-') + sign
+	if i <= sign {
+		return Date{}, err
+	}
+	year, yerr := strconv.Atoi(s[:i])
+	if yerr != nil {
+		return Date{}, err
+	}
+	shift := (year - 2000) / gregorianCycle * gregorianCycle
+	t, yerr = time.Parse(time.RFC3339Nano, fmt.Sprintf("%04d", year-shift)+s[i:])
+	if yerr != nil {
+		return Date{}, err
+	}
+	return DateFromTime(t.AddDate(shift, 0, 0)), nil
+}
+
+// Ticks converts d back to a C# DateTime tick count.
+func (d Date) Ticks() int64 {
+	// Convert back from the UTC timestamp to C# tick time
+	return (d.Unix()-baseTime)*TicksPerSecond + int64(d.Nanosecond()/100)
+}
+
+// String formats d as RFC 3339 with as many fractional digits as needed.
+func (d Date) String() string {
+	return d.Format(time.RFC3339Nano)
+}
+
+// MarshalText formats d like String. Unlike time.Time, it accepts the
+// years outside of 0 to 9999 ticks of corrupt databases give.
+func (d Date) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+func (d *Date) UnmarshalText(b []byte) error {
+	v, err := ParseDate(string(b))
+	if err != nil {
+		return err
+	}
+	*d = v
+	return nil
+}
+
+func (d Date) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func (d *Date) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	return d.UnmarshalText([]byte(s))
+}
+
+type FileInformation struct {
+	AbsoluteFilePath   string `xml:"absolute-file-path" json:"absolute-file-path"`
+	AbsoluteFolderPath string `xml:"absolute-folder-path" json:"absolute-folder-path"`
+	LastModified       Date   `xml:"last-modified" json:"last-modified"`
+	FileSize           int64  `xml:"file-size" json:"file-size"`
+}
+
+type SongIniInformation struct {
+	LastModified Date  `xml:"last-modified" json:"last-modified"`
+	FileSize     int64 `xml:"file-size" json:"file-size"`
+}
+
+// DGB holds a value of a chart for each instrument, drums, guitar and bass.
+// Get and Set index it by Instrument.
+type DGB[T any] struct {
+	Drums  T `xml:"drums" json:"drums"`
+	Guitar T `xml:"guitar" json:"guitar"`
+	Bass   T `xml:"bass" json:"bass"`
+}
+
+type (
+	DGBInt32   = DGB[int32]
+	DGBDouble  = DGB[float64]
+	DGBBoolean = DGB[bool]
+)
+
+type PerformanceHistory struct {
+	First  string `xml:"first" json:"first"`
+	Second string `xml:"second" json:"second"`
+	Third  string `xml:"third" json:"third"`
+	Fourth string `xml:"fourth" json:"fourth"`
+	Fifth  string `xml:"fifth" json:"fifth"`
+}
+
+type SongInformation struct {
+	Title              string             `xml:"title" json:"title"`
+	Artist             string             `xml:"artist" json:"artist"`
+	Comment            string             `xml:"comment" json:"comment"`
+	Genre              string             `xml:"genre" json:"genre"`
+	PreImage           string             `xml:"pre-image" json:"pre-image"`
+	PreMovie           string             `xml:"pre-movie" json:"pre-movie"`
+	PreSound           string             `xml:"pre-sound" json:"pre-sound"`
+	Background         string             `xml:"background" json:"background"`
+	Level              DGBInt32           `xml:"level" json:"level"`
+	LevelDec           DGBInt32           `xml:"level-dec" json:"level-dec"`
+	BestRank           DGBInt32           `xml:"best-rank" json:"best-rank"`
+	HighSkill          DGBDouble          `xml:"high-skill" json:"high-skill"`
+	FullCombo          DGBBoolean         `xml:"full-combo" json:"full-combo"`
+	NbPerformance      DGBInt32           `xml:"nb-performance" json:"nb-performance"`
+	PerformanceHistory PerformanceHistory `xml:"performance-history" json:"performance-history"`
+	HiddenLevel        bool               `xml:"hidden-level" json:"hidden-level"`
+	Classic            DGBBoolean         `xml:"classic" json:"classic"`
+	ScoreExists        DGBBoolean         `xml:"score-exists" json:"score-exists"`
+	SongType           SongType           `xml:"song-type" json:"song-type"`
+	Bpm                float64            `xml:"bpm" json:"bpm"`
+	Duration           int32              `xml:"duration" json:"duration"`
+}
+
+// Score is a record of songs.db: one chart file along with the play data of
+// the player.
+type Score struct {
+	XMLName            xml.Name           `xml:"song" json:"-"`
+	FileInformation    FileInformation    `xml:"file-info" json:"file-info"`
+	SongIniInformation SongIniInformation `xml:"song-ini-info" json:"song-ini-info"`
+	SongInformation    SongInformation    `xml:"song-info" json:"song-info"`
+}
@@ -0,0 +1,76 @@
+package dtxdb
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestParseDateRoundTrip(t *testing.T) {
+	tests := []struct {
+		ticks int64
+		text  string
+	}{
+		{0, "0001-01-01T00:00:00Z"},
+		{638000000000000000, "2022-09-28T22:13:20Z"},
+		{math.MaxInt64, "29228-09-14T02:48:05.4775807Z"},
+		{math.MinInt64, "-29227-04-19T21:11:54.5224192Z"},
+		{-1, "0000-12-31T23:59:59.9999999Z"},
+	}
+	for _, tt := range tests {
+		d := DateFromTicks(tt.ticks)
+		text, err := d.MarshalText()
+		if err != nil {
+			t.Fatalf("MarshalText of %d: %v", tt.ticks, err)
+		}
+		if string(text) != tt.text {
+			t.Errorf("MarshalText of %d = %s, want %s", tt.ticks, text, tt.text)
+		}
+
+		parsed, err := ParseDate(string(text))
+		if err != nil {
+			t.Errorf("ParseDate(%q): %v", text, err)
+			continue
+		}
+		if parsed != d {
+			t.Errorf("ParseDate(%q) = %v, want %v", text, parsed, d)
+		}
+		if got := parsed.Ticks(); got != tt.ticks {
+			t.Errorf("ParseDate(%q).Ticks() = %d, want %d", text, got, tt.ticks)
+		}
+	}
+}
+
+func TestParseDateInvalid(t *testing.T) {
+	for _, s := range []string{"", "-", "2022", "x2022-09-01T05:46:40Z", "12345-13-01T00:00:00Z"} {
+		if d, err := ParseDate(s); err == nil {
+			t.Errorf("ParseDate(%q) = %v, want an error", s, d)
+		}
+	}
+}
+
+func TestDateTicks(t *testing.T) {
+	tests := []struct {
+		ticks int64
+		want  time.Time
+	}{
+		{0, time.Date(1, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{1, time.Date(1, 1, 1, 0, 0, 0, 100, time.UTC)},
+		{TicksPerSecond, time.Date(1, 1, 1, 0, 0, 1, 0, time.UTC)},
+		{621355968000000000, time.Unix(0, 0).UTC()},
+		{637800000012345678, time.Date(2022, 2, 9, 10, 40, 1, 234567800, time.UTC)},
+		{-1, time.Date(0, 12, 31, 23, 59, 59, 999999900, time.UTC)},
+	}
+	for _, tt := range tests {
+		d := DateFromTicks(tt.ticks)
+		if !d.Equal(tt.want) || d.Location() != time.UTC {
+			t.Errorf("DateFromTicks(%d) = %v, want %v", tt.ticks, d, tt.want)
+		}
+		if got := d.Ticks(); got != tt.ticks {
+			t.Errorf("DateFromTicks(%d).Ticks() = %d", tt.ticks, got)
+		}
+		if got := DateFromTime(tt.want.In(time.FixedZone("JST", 9*60*60))); got != d {
+			t.Errorf("DateFromTime(%v) = %v, want %v", tt.want, got, d)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package dtxdb
+
+import (
+	"io"
+	"time"
+)
+
+// Timing is the time a Reader created with WithTiming spent in each part
+// of reading records. Decoding times do not include I/O.
+type Timing struct {
+	IO      time.Duration // reading from the underlying io.Reader
+	Records time.Duration // decoding records, strings included
+	Strings time.Duration // decoding strings
+}
+
+// timedReader adds the time spent reading from r to IO.
+type timedReader struct {
+	r io.Reader
+	t *Timing
+}
+
+func (r timedReader) Read(p []byte) (int, error) {
+	start := time.Now()
+	n, err := r.r.Read(p)
+	r.t.IO += time.Since(start)
+	return n, err
+}
+
+// stopwatch measures a part of decoding, without the I/O done meanwhile.
+type stopwatch struct {
+	start time.Time
+	io    time.Duration
+}
+
+func (r *Reader) startTiming() stopwatch {
+	if r.timing == nil {
+		return stopwatch{}
+	}
+	return stopwatch{time.Now(), r.timing.IO}
+}
+
+func (r *Reader) elapsed(s stopwatch) time.Duration {
+	return time.Since(s.start) - (r.timing.IO - s.io)
+}
@@ -0,0 +1,26 @@
+package dtxdb
+
+import "io"
+
+// Walk calls fn on every record of the database read from r, in database
+// order, keeping only the record being visited in memory. It stops at the
+// first error, returning it, whether it comes from reading or from fn; a
+// database read to its end gives nil.
+func Walk(r io.Reader, fn func(*Score) error, opts ...Option) error {
+	dr, err := NewReader(r, opts...)
+	if err != nil {
+		return err
+	}
+	for {
+		s, err := dr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := fn(s); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package dtxdb
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+)
+
+// Writer encodes records in the layout Reader decodes, so a database read
+// and written again comes out byte for byte the same. Read without
+// WithStrict, the booleans stored as another byte than 0 or 1 are the
+// exception: they are read as true and written back as 1.
+type Writer struct {
+	w      *bufio.Writer
+	layout *Layout
+	err    error
+}
+
+// NewWriter writes the version header of a database, e.g. "SongsDB5", to
+// w. The records are then written with Write in the layout of the version,
+// and Flush must be called once they all were. It returns an
+// *ErrUnsupportedVersion, writing nothing, for a version without a Layout.
+func NewWriter(w io.Writer, version string) (*Writer, error) {
+	l, ok := LookupLayout(version)
+	if !ok {
+		return nil, &ErrUnsupportedVersion{Version: version}
+	}
+	return NewLayoutWriter(w, version, l), nil
+}
+
+// NewLayoutWriter is NewWriter writing the records in layout l whatever
+// version says, as Reader does with WithFallbackLayout.
+func NewLayoutWriter(w io.Writer, version string, l *Layout) *Writer {
+	dw := &Writer{w: bufio.NewWriter(w), layout: l}
+	dw.writeString("version", version)
+	return dw
+}
+
+// Write encodes s. Errors are sticky, once Write failed it keeps failing
+// and so does Flush.
+func (w *Writer) Write(s *Score) error {
+	w.writeScore(s)
+	return w.err
+}
+
+// Flush writes the buffered data to the underlying io.Writer.
+func (w *Writer) Flush() error {
+	if w.err != nil {
+		return w.err
+	}
+	if err := w.w.Flush(); err != nil {
+		w.err = err
+	}
+	return w.err
+}
+
+// MarshalBinary returns the bytes s is stored as in a database of the first
+// of SupportedVersions, without the version header.
+func (s *Score) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	w := &Writer{w: bufio.NewWriter(&buf), layout: layouts[SupportedVersions[0]]}
+	if err := w.Write(s); err != nil {
+		return nil, err
+	}
+	if err := w.Flush(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// fail records the first error encountered while writing field, later
+// writes are no-ops.
+func (w *Writer) fail(field string, err error) {
+	if w.err == nil {
+		w.err = fmt.Errorf("dtxdb: writing %s: %w", field, err)
+	}
+}
+
+func (w *Writer) write(field string, b []byte) {
+	if w.err != nil {
+		return
+	}
+	if _, err := w.w.Write(b); err != nil {
+		w.fail(field, err)
+	}
+}
+
+func (w *Writer) writeString(field string, v string) {
+	lengthAsBytes := make([]byte, binary.MaxVarintLen64)
+	n := binary.PutUvarint(lengthAsBytes, uint64(len(v)))
+	w.write(field, lengthAsBytes[:n])
+	w.write(field, []byte(v))
+}
+
+func (w *Writer) writeSignedInt64(field string, v int64) {
+	valueAsBytes := make([]byte, 8)
+	binary.LittleEndian.PutUint64(valueAsBytes, uint64(v))
+	w.write(field, valueAsBytes)
+}
+
+func (w *Writer) writeSignedInt32(field string, v int32) {
+	valueAsBytes := make([]byte, 4)
+	binary.LittleEndian.PutUint32(valueAsBytes, uint32(v))
+	w.write(field, valueAsBytes)
+}
+
+func (w *Writer) writeDouble(field string, v float64) {
+	valueAsBytes := make([]byte, 8)
+	binary.LittleEndian.PutUint64(valueAsBytes, math.Float64bits(v))
+	w.write(field, valueAsBytes)
+}
+
+func (w *Writer) writeBool(field string, v bool) {
+	var b byte
+	if v {
+		b = 1
+	}
+	w.write(field, []byte{b})
+}
+
+func (w *Writer) writeDate(field string, d Date) {
+	w.writeSignedInt64(field, d.Ticks())
+}
+
+// writeField writes the field of a layout as readField reads it.
+func (w *Writer) writeField(f LayoutField, s *Score) {
+	switch v := f.Value(s).(type) {
+	case *string:
+		w.writeString(f.Name, *v)
+	case *int32:
+		w.writeSignedInt32(f.Name, *v)
+	case *int64:
+		w.writeSignedInt64(f.Name, *v)
+	case *float64:
+		w.writeDouble(f.Name, *v)
+	case *bool:
+		w.writeBool(f.Name, *v)
+	case *Date:
+		w.writeDate(f.Name, *v)
+	case *SongType:
+		w.writeSignedInt32(f.Name, int32(*v))
+	default:
+		panic(fmt.Sprintf("dtxdb: field %s of layout has type %T", f.Name, v))
+	}
+}
+
+func (w *Writer) writeScore(s *Score) {
+	for _, f := range w.layout.Fields {
+		w.writeField(f, s)
+	}
+}
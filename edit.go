@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+// assignment is a "field=value" given with edit -set.
+type assignment struct {
+	field string
+	value string
+}
+
+// assignmentList is the -set flag of edit, applied in order.
+type assignmentList []assignment
+
+func (l *assignmentList) String() string {
+	var exprs []string
+	for _, a := range *l {
+		exprs = append(exprs, a.field+"="+a.value)
+	}
+	return strings.Join(exprs, ",")
+}
+
+// Set checks that the field is stored in songs.db and that value parses
+// for it before any record is read.
+func (l *assignmentList) Set(expr string) error {
+	at := strings.Index(expr, "=")
+	if at <= 0 {
+		return fmt.Errorf("invalid assignment %q, expected field=value", expr)
+	}
+	a := assignment{strings.TrimSpace(expr[:at]), expr[at+1:]}
+
+	var s score
+	f, ok := lookupField(&s, a.field)
+	if !ok {
+		return fmt.Errorf("invalid assignment %q, unknown field %q", expr, a.field)
+	}
+	stored := false
+	for _, d := range dbFields(&s) {
+		stored = stored || d.name == f.name
+	}
+	if !stored {
+		return fmt.Errorf("invalid assignment %q, %s is not stored in songs.db", expr, f.name)
+	}
+	if err := f.set(a.value); err != nil {
+		return fmt.Errorf("invalid assignment %q: %v", expr, err)
+	}
+
+	*l = append(*l, a)
+	return nil
+}
+
+func runEdit(args []string) {
+	flags := newFlagSet("edit")
+	input := inputDBFlag(flags)
+	output := outputDBFlag(flags)
+	var filters filterList
+	flags.Var(&filters, "filter", "only edit songs matching `field=value` or the preset @name, may be repeated; operators are = != ~ !~ ^= < <= > >=")
+	var sets assignmentList
+	flags.Var(&sets, "set", "set `field=value` in the songs edited, may be repeated")
+	dryRun := flags.Bool("n", false, "only report the values that would change")
+	readerFlags(flags)
+	flags.Parse(args)
+
+	if len(sets) == 0 {
+		log.Fatalln("nothing to change, give at least one -set")
+	}
+	// Editing every record at once is rarely meant, it takes an explicit
+	// filter matching all of them like -filter 'path~'.
+	if len(filters) == 0 {
+		log.Fatalln("no songs selected, give at least one -filter")
+	}
+
+	versionString, scores := readAllScores(*input)
+	edited := 0
+	for i := range scores {
+		s := &scores[i]
+		if !filters.match(s) {
+			continue
+		}
+		changed := false
+		for _, a := range sets {
+			f, _ := lookupField(s, a.field)
+			old := f.String()
+			logFatalIfError(f.set(a.value))
+			if now := f.String(); now != old {
+				infof("%s: %s %q -> %q\n", s.FileInformation.AbsoluteFilePath, f.name, old, now)
+				changed = true
+			}
+		}
+		if changed {
+			edited++
+		}
+	}
+
+	infof("%d records changed\n", edited)
+	if *dryRun {
+		return
+	}
+
+	writeSongsDB(*output, versionString, scores)
+	infof("written %s\n", *output)
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// encode turns a dump, possibly edited by hand or by a script, back into a
+// songs.db DTXMania can load. The dump is checked first: a missing field
+// would otherwise silently become a zero in the database.
+
+func readXMLDump(data []byte, report func(path, problem string)) ([]score, error) {
+	if _, err := validateDump(bytes.NewReader(data), report); err != nil {
+		return nil, err
+	}
+	var dump struct {
+		Songs []score `xml:"song"`
+	}
+	if err := newDumpDecoder(bytes.NewReader(data)).Decode(&dump); err != nil {
+		return nil, err
+	}
+	return dump.Songs, nil
+}
+
+// readJSONDump reads the array of songs of a JSON dump.
+func readJSONDump(data []byte, report func(path, problem string)) ([]score, error) {
+	dec := json.NewDecoder(newDumpReader(bytes.NewReader(data)))
+	if tok, err := dec.Token(); err != nil {
+		return nil, err
+	} else if tok != json.Delim('[') {
+		return nil, fmt.Errorf("the dump is no array of songs")
+	}
+	var scores []score
+	for dec.More() {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return nil, err
+		}
+		scores = append(scores, decodeJSONRecord(raw, fmt.Sprintf("song %d", len(scores)+1), report))
+	}
+	if _, err := dec.Token(); err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, fmt.Errorf("content after the array of songs")
+	}
+	return scores, nil
+}
+
+// readNDJSONDump reads the songs of an ndjson dump, one per line.
+func readNDJSONDump(data []byte, report func(path, problem string)) ([]score, error) {
+	dec := json.NewDecoder(newDumpReader(bytes.NewReader(data)))
+	var scores []score
+	for {
+		var raw json.RawMessage
+		err := dec.Decode(&raw)
+		if err == io.EOF {
+			return scores, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		scores = append(scores, decodeJSONRecord(raw, fmt.Sprintf("song %d", len(scores)+1), report))
+	}
+}
+
+// decodeJSONRecord returns the song of a record of a JSON dump, reporting
+// what is wrong with it.
+func decodeJSONRecord(raw json.RawMessage, path string, report func(path, problem string)) score {
+	var s score
+	problems := 0
+	checkJSONObject(raw, reflect.TypeOf(s), path, func(path, problem string) {
+		report(path, problem)
+		problems++
+	})
+	if problems == 0 {
+		if err := json.Unmarshal(raw, &s); err != nil {
+			report(path, err.Error())
+		}
+	}
+	return s
+}
+
+// checkJSONObject checks the keys of an object holding the struct type t
+// like the validator checks the elements of dump.xml.
+func checkJSONObject(raw json.RawMessage, t reflect.Type, path string, report func(path, problem string)) {
+	var keys map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &keys); err != nil || keys == nil {
+		report(path, fmt.Sprintf("%s is not an object", raw))
+		return
+	}
+	for _, f := range schemaFields(t) {
+		name := jsonFieldName(f)
+		value, ok := keys[name]
+		if !ok {
+			if !optionalElement(f) {
+				report(path, fmt.Sprintf("missing key %s", name))
+			}
+			continue
+		}
+		delete(keys, name)
+
+		child := path + "/" + name
+		if nestedType(f.Type) {
+			checkJSONObject(value, f.Type, child, report)
+		} else if problem := checkJSONValue(value, f.Type); problem != "" {
+			report(child, problem)
+		}
+	}
+	var unexpected []string
+	for name := range keys {
+		unexpected = append(unexpected, name)
+	}
+	sort.Strings(unexpected)
+	for _, name := range unexpected {
+		report(path, fmt.Sprintf("unexpected key %s", name))
+	}
+}
+
+// checkJSONValue returns what is wrong with raw as a value of type t, or
+// "". Song types and dates are strings like in the XML dump.
+func checkJSONValue(raw json.RawMessage, t reflect.Type) string {
+	if string(raw) == "null" {
+		return "null is no value"
+	}
+	if t == songTypeType || t == dateType || t.Kind() == reflect.String {
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return fmt.Sprintf("%s is not a string", raw)
+		}
+		return checkSimpleValue(s, t)
+	}
+	if err := json.Unmarshal(raw, reflect.New(t).Interface()); err == nil {
+		return ""
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return fmt.Sprintf("%s is not true or false", raw)
+	case reflect.Int32, reflect.Int64:
+		return fmt.Sprintf("%s is not a %d bit integer", raw, t.Bits())
+	}
+	return fmt.Sprintf("%s is not a number", raw)
+}
+
+func runEncode(args []string) {
+	flags := newFlagSet("encode")
+	input := flags.String("i", "dump.xml", "read the dump from `file`")
+	aliasFlag(flags, "input", "i")
+	format := flags.String("format", "", "read the dump as `format`, one of "+strings.Join(readableFormats(), ", ")+"; guessed from the extension of -i by default")
+	output := outputDBFlag(flags)
+	version := versionFlag(flags)
+	unescape := flags.Bool("unescape", false, "read back the strings of a dump written with -invalid-chars escape")
+	flags.Parse(args)
+	logFatalIfError(checkVersion(*version))
+
+	if *format == "" {
+		*format = formatOfOutput(*input)
+	}
+	read := dumpFormats[*format].read
+	if read == nil {
+		log.Fatalf("cannot encode %s dumps, use one of %s\n", *input, strings.Join(readableFormats(), ", "))
+	}
+
+	data, err := os.ReadFile(*input)
+	logFatalIfError(err)
+	bad := 0
+	scores, err := read(data, func(path, problem string) {
+		fmt.Printf("%s: %s: %s\n", *input, path, problem)
+		bad++
+	})
+	if err != nil {
+		log.Fatalf("%s: %v\n", *input, err)
+	}
+	if bad > 0 {
+		log.Fatalf("%s: %d problems, nothing written\n", *input, bad)
+	}
+
+	if *unescape {
+		for i := range scores {
+			for _, f := range scoreFields(&scores[i]) {
+				if f.value.Kind() == reflect.String {
+					f.value.SetString(unescapeString(f.value.String()))
+				}
+			}
+		}
+	}
+
+	writeSongsDB(*output, *version, scores)
+	infof("%d songs written to %s\n", len(scores), *output)
+}
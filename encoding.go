@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+)
+
+// outputEncoding is an encoding of the text formats chosen with dump
+// -output-encoding, for tools that expect a byte order mark or UTF-16.
+type outputEncoding struct {
+	// xmlName is the encoding named by the XML declaration.
+	xmlName string
+	enc     encoding.Encoding
+}
+
+var outputEncodings = map[string]outputEncoding{
+	"utf-8":     {"", nil},
+	"utf-8-bom": {"UTF-8", unicode.UTF8BOM},
+	"utf-16":    {"UTF-16", unicode.UTF16(unicode.LittleEndian, unicode.UseBOM)},
+	"utf-16be":  {"UTF-16", unicode.UTF16(unicode.BigEndian, unicode.UseBOM)},
+}
+
+// xmlDeclaration is the encoding the XML dump declares, if any.
+var xmlDeclaration string
+
+func outputEncodingNames() string {
+	var names []string
+	for name := range outputEncodings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// encodedDumpFormat returns format writing in the encoding named name.
+func encodedDumpFormat(format dumpFormat, name string) (dumpFormat, error) {
+	e, ok := outputEncodings[strings.ToLower(name)]
+	if !ok {
+		return format, fmt.Errorf("unknown output encoding %q, use one of %s", name, outputEncodingNames())
+	}
+	if e.enc == nil {
+		return format, nil
+	}
+	if format.binary {
+		return format, fmt.Errorf("%s dumps are no text, they cannot be written as %s", format.ext, name)
+	}
+	xmlDeclaration = e.xmlName
+
+	encoded := func(newWriter func(io.Writer) (recordWriter, error)) func(io.Writer) (recordWriter, error) {
+		return func(w io.Writer) (recordWriter, error) {
+			tw := transform.NewWriter(w, e.enc.NewEncoder())
+			out, err := newWriter(tw)
+			if err != nil {
+				return nil, err
+			}
+			return &encodedWriter{out, tw}, nil
+		}
+	}
+	format.newWriter = encoded(format.newWriter)
+	if format.newPart != nil {
+		format.newPart = encoded(format.newPart)
+	}
+	return format, nil
+}
+
+// encodedWriter writes the output of a recordWriter through an encoder.
+type encodedWriter struct {
+	recordWriter
+	tw *transform.Writer
+}
+
+// buffered takes every byte still held for 2, as they are in UTF-16.
+func (e *encodedWriter) buffered() int {
+	if w, ok := e.recordWriter.(sizedWriter); ok {
+		return 2 * w.buffered()
+	}
+	return 0
+}
+
+func (e *encodedWriter) close() error {
+	if err := e.recordWriter.close(); err != nil {
+		return err
+	}
+	return e.tw.Close()
+}
+
+// newDumpReader returns the text of a dump read from r in any of the
+// output encodings as UTF-8, telling them apart by the byte order mark.
+func newDumpReader(r io.Reader) io.Reader {
+	return transform.NewReader(r, unicode.BOMOverride(unicode.UTF8.NewDecoder()))
+}
+
+// newDumpDecoder returns a decoder of the XML dump read from r in any of
+// the output encodings. The decoder reads UTF-8 whatever the declaration
+// says.
+func newDumpDecoder(r io.Reader) *xml.Decoder {
+	dec := xml.NewDecoder(newDumpReader(r))
+	dec.CharsetReader = func(label string, input io.Reader) (io.Reader, error) {
+		switch strings.ToLower(label) {
+		case "utf-8", "utf-16":
+			return input, nil
+		}
+		return nil, fmt.Errorf("unsupported encoding %q", label)
+	}
+	return dec
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/SirChronus/dtxmania-dbdump/dtxdb"
+)
+
+// scoreField is a single value of a record flattened into a dotted name,
+// e.g. "level.drums" or "file-info.file-size".
+type scoreField struct {
+	name  string
+	value reflect.Value
+}
+
+// String formats the value the way it is written in the XML dump.
+func (f scoreField) String() string {
+	return fmt.Sprint(f.value.Interface())
+}
+
+// set parses value the way String formats it and stores it in the field.
+func (f scoreField) set(value string) error {
+	if t, ok := f.value.Addr().Interface().(*dtxdb.SongType); ok {
+		v, err := dtxdb.ParseSongType(value)
+		if err != nil {
+			return err
+		}
+		*t = v
+		return nil
+	}
+	if d, ok := f.value.Addr().Interface().(*dtxdb.Date); ok {
+		v, err := dtxdb.ParseDate(value)
+		if err != nil {
+			return fmt.Errorf("%s: %q is not an RFC 3339 date", f.name, value)
+		}
+		*d = v
+		return nil
+	}
+
+	switch f.value.Kind() {
+	case reflect.String:
+		f.value.SetString(value)
+	case reflect.Bool:
+		v, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("%s: %q is not true or false", f.name, value)
+		}
+		f.value.SetBool(v)
+	case reflect.Int32, reflect.Int64:
+		v, err := strconv.ParseInt(value, 10, f.value.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("%s: %q is not an integer", f.name, value)
+		}
+		f.value.SetInt(v)
+	case reflect.Float64:
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("%s: %q is not a number", f.name, value)
+		}
+		f.value.SetFloat(v)
+	default:
+		return fmt.Errorf("%s cannot be set", f.name)
+	}
+	return nil
+}
+
+// fieldAliases are shorter names accepted wherever fields are named.
+var fieldAliases = map[string]string{
+	"path":   "file-info.absolute-file-path",
+	"folder": "file-info.absolute-folder-path",
+	"type":   "song-type",
+}
+
+// scoreFields flattens s into its fields, named after the XML elements
+// leading to them. The song-info element is left out of the names since
+// almost every field lives below it.
+func scoreFields(s *score) []scoreField {
+	var fields []scoreField
+	appendFields(&fields, "", reflect.ValueOf(s).Elem())
+	return fields
+}
+
+func appendFields(fields *[]scoreField, prefix string, v reflect.Value) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Anonymous {
+			// The dtxdb.Score embedded in score.
+			appendFields(fields, prefix, v.Field(i))
+			continue
+		}
+		tag := strings.Split(f.Tag.Get("xml"), ",")[0]
+		if f.Name == "XMLName" || tag == "" || tag == "-" {
+			continue
+		}
+
+		name := prefix + tag
+		if tag == "song-info" && prefix == "" {
+			name = ""
+		}
+		if nestedType(f.Type) {
+			if name != "" {
+				name += "."
+			}
+			appendFields(fields, name, v.Field(i))
+			continue
+		}
+		*fields = append(*fields, scoreField{name, v.Field(i)})
+	}
+}
+
+// lookupField returns the field with the given name or alias.
+func lookupField(s *score, name string) (scoreField, bool) {
+	if alias, ok := fieldAliases[name]; ok {
+		name = alias
+	}
+	for _, f := range scoreFields(s) {
+		if f.name == name {
+			return f, true
+		}
+	}
+
+	return scoreField{}, false
+}
+
+// fieldNames lists the names of every flattened field in dump order.
+func fieldNames() []string {
+	var names []string
+	for _, f := range scoreFields(&score{}) {
+		names = append(names, f.name)
+	}
+	return names
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// filterOperators in the order they are looked for, so that ">=" is not
+// mistaken for ">".
+var filterOperators = []string{"!=", "!~", "^=", ">=", "<=", "=", "~", ">", "<"}
+
+// filter is a single "field op value" condition on a record, e.g.
+// "artist=Aery", "title~love", "folder^=D:\DTX\Old" or "level.drums>=70".
+type filter struct {
+	field string
+	op    string
+	value string
+}
+
+func parseFilter(expr string) (filter, error) {
+	at, op := -1, ""
+	for _, o := range filterOperators {
+		if i := strings.Index(expr, o); i > 0 && (at < 0 || i < at) {
+			at, op = i, o
+		}
+	}
+	if at < 0 {
+		return filter{}, fmt.Errorf("invalid filter %q, expected field, operator and value", expr)
+	}
+
+	f := filter{strings.TrimSpace(expr[:at]), op, strings.TrimSpace(expr[at+len(op):])}
+	if _, ok := lookupField(&score{}, f.field); !ok {
+		return filter{}, fmt.Errorf("invalid filter %q, unknown field %q", expr, f.field)
+	}
+	return f, nil
+}
+
+// match reports whether s satisfies the condition. Values that both parse
+// as numbers are compared numerically, anything else is compared as text,
+// ignoring case.
+func (f filter) match(s *score) bool {
+	field, _ := lookupField(s, f.field)
+	actual := field.String()
+
+	cmp := 0
+	a, errA := strconv.ParseFloat(actual, 64)
+	b, errB := strconv.ParseFloat(f.value, 64)
+	if errA == nil && errB == nil {
+		switch {
+		case a < b:
+			cmp = -1
+		case a > b:
+			cmp = 1
+		}
+	} else {
+		cmp = strings.Compare(strings.ToLower(actual), strings.ToLower(f.value))
+	}
+
+	switch f.op {
+	case "=":
+		return cmp == 0
+	case "!=":
+		return cmp != 0
+	case "~":
+		return strings.Contains(strings.ToLower(actual), strings.ToLower(f.value))
+	case "!~":
+		return !strings.Contains(strings.ToLower(actual), strings.ToLower(f.value))
+	case "^=":
+		return strings.HasPrefix(strings.ToLower(actual), strings.ToLower(f.value))
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	case "<":
+		return cmp < 0
+	}
+	return cmp <= 0
+}
+
+// filterList is the -filter flag: several filters may be given and a record
+// has to match all of them.
+type filterList []filter
+
+func (l *filterList) String() string {
+	var exprs []string
+	for _, f := range *l {
+		exprs = append(exprs, f.field+f.op+f.value)
+	}
+	return strings.Join(exprs, ",")
+}
+
+func (l *filterList) Set(expr string) error {
+	if strings.HasPrefix(expr, "@") {
+		return l.setPreset(expr[1:])
+	}
+	f, err := parseFilter(expr)
+	if err != nil {
+		return err
+	}
+
+	*l = append(*l, f)
+	return nil
+}
+
+// filterPresets are the named lists of filters of the config file.
+var filterPresets map[string][]string
+
+// setPreset adds the filters of the preset name.
+func (l *filterList) setPreset(name string) error {
+	exprs, ok := filterPresets[name]
+	if !ok && len(filterPresets) == 0 {
+		return fmt.Errorf("unknown filter preset %q, the config file has no filters", name)
+	}
+	if !ok {
+		return fmt.Errorf("unknown filter preset %q, the config file has %s", name, strings.Join(sortedKeys(filterPresets), ", "))
+	}
+	for _, expr := range exprs {
+		f, err := parseFilter(expr)
+		if err != nil {
+			return fmt.Errorf("filter preset %s: %v", name, err)
+		}
+		*l = append(*l, f)
+	}
+	return nil
+}
+
+func (l filterList) match(s *score) bool {
+	for _, f := range l {
+		if !f.match(s) {
+			return false
+		}
+	}
+	return true
+}
+
+const filterUsage = "only include songs matching `field=value` or the preset @name, may be repeated; operators are = != ~ !~ ^= < <= > >="
@@ -0,0 +1,82 @@
+package main
+
+import "testing"
+
+func TestParseFilter(t *testing.T) {
+	tests := []struct {
+		expr string
+		want filter
+	}{
+		{"artist=Aery", filter{"artist", "=", "Aery"}},
+		{" title ~ love ", filter{"title", "~", "love"}},
+		{"level.drums>=70", filter{"level.drums", ">=", "70"}},
+		{"level.drums<=70", filter{"level.drums", "<=", "70"}},
+		{"genre!=Anime", filter{"genre", "!=", "Anime"}},
+		{"genre!~ani", filter{"genre", "!~", "ani"}},
+		{`file-info.absolute-folder-path^=D:\DTX\Old`, filter{"file-info.absolute-folder-path", "^=", `D:\DTX\Old`}},
+		{"title=a=b", filter{"title", "=", "a=b"}},
+		{"title=", filter{"title", "=", ""}},
+	}
+	for _, tt := range tests {
+		got, err := parseFilter(tt.expr)
+		if err != nil {
+			t.Errorf("parseFilter(%q): %v", tt.expr, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseFilter(%q) = %+v, want %+v", tt.expr, got, tt.want)
+		}
+	}
+
+	for _, expr := range []string{"", "artist", "=Aery", "unknown=1"} {
+		if f, err := parseFilter(expr); err == nil {
+			t.Errorf("parseFilter(%q) = %+v, want an error", expr, f)
+		}
+	}
+}
+
+func TestFilterMatch(t *testing.T) {
+	s := testScores()[0]
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{"artist=artist", true},
+		{"artist=Other", false},
+		{"artist!=Other", true},
+		{"title~on", true},
+		{"title!~on", false},
+		{`file-info.absolute-folder-path^=c:\dtxfiles`, true},
+		{"level.drums>=85", true},
+		{"level.drums>85", false},
+		{"level.drums<100", true},
+		{"level.drums<=9", false},
+		{"bpm=180.50", true},
+	}
+	for _, tt := range tests {
+		f, err := parseFilter(tt.expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := f.match(&s); got != tt.want {
+			t.Errorf("%s matches %v, want %v", tt.expr, got, tt.want)
+		}
+	}
+
+	var l filterList
+	for _, expr := range []string{"artist=Artist", "level.drums>80"} {
+		if err := l.Set(expr); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !l.match(&s) {
+		t.Errorf("%s does not match", l.String())
+	}
+	l.Set("genre=Rock")
+	if l.match(&s) {
+		t.Errorf("%s matches", l.String())
+	}
+	if err := l.Set("@none"); err == nil {
+		t.Errorf("unknown preset accepted")
+	}
+}
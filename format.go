@@ -0,0 +1,197 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// recordWriter writes the records of a dump in one file format.
+type recordWriter interface {
+	write(s *score) error
+	// close writes whatever follows the last record. The underlying writer
+	// is left open.
+	close() error
+}
+
+// sizedWriter is a recordWriter that can tell how many of the bytes it
+// produced it still holds, so dump -max-output-size knows the size of a
+// part before it is closed.
+type sizedWriter interface {
+	recordWriter
+	buffered() int
+}
+
+// dumpFormat is a file format of dumps. Everything a command does with a
+// format goes through its dumpFormat, so a format is added by adding it to
+// dumpFormats: dump writes it with -format, encode and verify -via read it
+// back if it has read and schema prints its schema if it has one.
+type dumpFormat struct {
+	ext       string
+	newWriter func(w io.Writer) (recordWriter, error)
+	// newPart writes the parts after the first of a dump split with
+	// -max-output-size. It is nil for formats that cannot be split.
+	newPart func(w io.Writer) (recordWriter, error)
+	// binary is set for the formats that are no text, which cannot be
+	// written in another -output-encoding.
+	binary bool
+	// read returns the songs of a dump of the format, calling report for
+	// every problem found. The songs are only complete when there is none.
+	// It is nil for formats that cannot be read back.
+	read func(data []byte, report func(path, problem string)) ([]score, error)
+	// schema returns the schema describing the records of the format, nil
+	// for formats having none.
+	schema func() ([]byte, error)
+}
+
+// dumpFormats are the formats dump writes with -format.
+var dumpFormats = map[string]dumpFormat{
+	"xml":      {ext: "xml", newWriter: newXMLWriter, newPart: newXMLWriter, read: readXMLDump, schema: xsdSchema},
+	"avro":     {ext: "avro", newWriter: newAvroWriter, newPart: newAvroWriter, binary: true, schema: avroSchema},
+	"xlsx":     {ext: "xlsx", newWriter: newXLSXWriter, binary: true},
+	"sqlite":   {ext: "sqlite", newWriter: newSQLiteWriter, binary: true},
+	"toml":     {ext: "toml", newWriter: newTOMLWriter, newPart: newTOMLWriter},
+	"mysql":    {ext: "sql", newWriter: newMySQLWriter, newPart: newMySQLInsertWriter},
+	"parquet":  {ext: "parquet", newWriter: newParquetWriter, binary: true},
+	"arrow":    {ext: "arrow", newWriter: newArrowWriter, binary: true},
+	"bson":     {ext: "bson", newWriter: newBSONWriter, newPart: newBSONWriter, binary: true},
+	"csv":      {ext: "csv", newWriter: newCSVWriter, newPart: newCSVWriter},
+	"html":     {ext: "html", newWriter: newHTMLWriter, newPart: newHTMLWriter},
+	"json":     {ext: "json", newWriter: newJSONWriter, newPart: newJSONWriter, read: readJSONDump, schema: jsonSchema},
+	"markdown": {ext: "md", newWriter: newMarkdownWriter, newPart: newMarkdownWriter},
+	"msgpack":  {ext: "msgpack", newWriter: newMsgpackWriter, newPart: newMsgpackWriter, binary: true},
+	"ndjson":   {ext: "ndjson", newWriter: newNDJSONWriter, newPart: newNDJSONWriter, read: readNDJSONDump},
+	"protobuf": {ext: "pb", newWriter: newProtobufWriter, newPart: newProtobufWriter, binary: true, schema: func() ([]byte, error) {
+		return []byte(protoSchema()), nil
+	}},
+}
+
+// formatNamesWhere returns the names of the formats keep is true for, in
+// order.
+func formatNamesWhere(keep func(f dumpFormat) bool) []string {
+	var names []string
+	for name, f := range dumpFormats {
+		if keep(f) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func formatNames() string {
+	return strings.Join(formatNamesWhere(func(dumpFormat) bool { return true }), ", ")
+}
+
+// readableFormats are the formats encode and verify -via read back.
+func readableFormats() []string {
+	return formatNamesWhere(func(f dumpFormat) bool { return f.read != nil })
+}
+
+// formatOfOutput returns the name of the format whose extension file has,
+// after any compression suffix, or "" if there is none.
+func formatOfOutput(file string) string {
+	for _, c := range compressions {
+		file = strings.TrimSuffix(file, c.ext)
+	}
+	ext := strings.TrimPrefix(filepath.Ext(file), ".")
+	for name, f := range dumpFormats {
+		if ext != "" && strings.EqualFold(f.ext, ext) {
+			return name
+		}
+	}
+	return ""
+}
+
+func lookupDumpFormat(name string) (dumpFormat, error) {
+	f, ok := dumpFormats[name]
+	if !ok {
+		return dumpFormat{}, fmt.Errorf("unknown format %q, use one of %s", name, formatNames())
+	}
+	return f, nil
+}
+
+// The indentation of the XML dump: songs are indented by xmlPrefix, every
+// level below them by xmlIndent more. dump -xml-indent sets both to the
+// same, -compact writes no white space at all.
+var (
+	xmlPrefix     = "  "
+	xmlIndent     = "    "
+	compactOutput bool
+)
+
+// xmlIndentFlag is the value of dump -xml-indent: a number of spaces or
+// tab.
+type xmlIndentFlag struct{}
+
+func (xmlIndentFlag) String() string {
+	return ""
+}
+
+func (xmlIndentFlag) Set(v string) error {
+	if v == "tab" {
+		xmlPrefix, xmlIndent = "\t", "\t"
+		return nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 || n > 16 {
+		return fmt.Errorf("%q is not a number of spaces from 0 to 16 or tab", v)
+	}
+	xmlPrefix, xmlIndent = strings.Repeat(" ", n), strings.Repeat(" ", n)
+	return nil
+}
+
+// xmlNewline is the line break between the elements around the songs.
+func xmlNewline() string {
+	if compactOutput {
+		return ""
+	}
+	return "\n"
+}
+
+// xmlWriter writes the dump.xml format: every record as a song element
+// below a songs element.
+type xmlWriter struct {
+	w   io.Writer
+	enc *xml.Encoder
+}
+
+func newXMLWriter(w io.Writer) (recordWriter, error) {
+	if xmlDeclaration != "" {
+		if _, err := fmt.Fprintf(w, `<?xml version="1.0" encoding="%s"?>%s`, xmlDeclaration, xmlNewline()); err != nil {
+			return nil, err
+		}
+	}
+	if err := writeXMLStylesheetPI(w); err != nil {
+		return nil, err
+	}
+	if _, err := io.WriteString(w, "<songs>"+xmlNewline()); err != nil {
+		return nil, err
+	}
+	enc := xml.NewEncoder(w)
+	if !compactOutput {
+		enc.Indent(xmlPrefix, xmlIndent)
+	}
+	return &xmlWriter{w, enc}, nil
+}
+
+func (x *xmlWriter) write(s *score) error {
+	return x.enc.Encode(s)
+}
+
+// buffered is always 0, Encode flushes.
+func (x *xmlWriter) buffered() int {
+	return 0
+}
+
+func (x *xmlWriter) close() error {
+	if err := x.enc.Flush(); err != nil {
+		return err
+	}
+	_, err := io.WriteString(x.w, xmlNewline()+"</songs>")
+	return err
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/SirChronus/dtxmania-dbdump/dtxdb"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files of testdata/golden")
+
+// testScores returns records with every field set, one of them with the
+// characters the formats escape.
+func testScores() []score {
+	var scores []score
+	for _, title := range []string{"One", `Two <&> "|,'` + "\n"} {
+		var s score
+		s.FileInformation = dtxdb.FileInformation{
+			AbsoluteFilePath:   `C:\DTXFiles\` + title + `\ext.dtx`,
+			AbsoluteFolderPath: `C:\DTXFiles\` + title + `\`,
+			LastModified:       dtxdb.DateFromTicks(637800000000000000),
+			FileSize:           12345,
+		}
+		s.SongIniInformation = dtxdb.SongIniInformation{LastModified: dtxdb.DateFromTicks(637800000010000000), FileSize: 678}
+		info := &s.SongInformation
+		info.Title, info.Artist, info.Comment, info.Genre = title, "Artist", "コメント", "Anime"
+		info.PreImage, info.PreMovie, info.PreSound, info.Background = "pre.png", "pre.avi", "pre.ogg", "bg.png"
+		info.Level = dtxdb.DGBInt32{Drums: 85, Guitar: 70, Bass: 60}
+		info.LevelDec = dtxdb.DGBInt32{Drums: 5, Guitar: 0, Bass: 3}
+		info.BestRank = dtxdb.DGBInt32{Drums: 1, Guitar: dtxdb.NoRank, Bass: dtxdb.NoRank}
+		info.HighSkill = dtxdb.DGBDouble{Drums: 142.5, Guitar: 0, Bass: 0}
+		info.FullCombo = dtxdb.DGBBoolean{Drums: true, Guitar: false, Bass: false}
+		info.NbPerformance = dtxdb.DGBInt32{Drums: 12, Guitar: 0, Bass: 1}
+		info.PerformanceHistory = dtxdb.PerformanceHistory{First: "22/01/02 Drums:Cleared"}
+		info.HiddenLevel = true
+		info.Classic = dtxdb.DGBBoolean{Drums: false, Guitar: true, Bass: false}
+		info.ScoreExists = dtxdb.DGBBoolean{Drums: true, Guitar: false, Bass: true}
+		info.SongType = dtxdb.DTX
+		info.Bpm = 180.5
+		info.Duration = 123456
+		scores = append(scores, s)
+	}
+	return scores
+}
+
+// writeDump writes scores as a dump in format.
+func writeDump(t *testing.T, format string, scores []score) []byte {
+	t.Helper()
+	f, err := lookupDumpFormat(format)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	out, err := f.newWriter(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range scores {
+		if err := out.write(&scores[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := out.close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// TestGolden compares the dump of every text format with the one in
+// testdata/golden, go test -update writes them again.
+func TestGolden(t *testing.T) {
+	for _, format := range formatNamesWhere(func(f dumpFormat) bool { return !f.binary }) {
+		t.Run(format, func(t *testing.T) {
+			got := writeDump(t, format, testScores())
+			path := filepath.Join("testdata", "golden", "dump."+dumpFormats[format].ext)
+			if *update {
+				if err := os.WriteFile(path, got, 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("dump differs from %s:\n%s", path, got)
+			}
+		})
+	}
+}
+
+// TestEncodeVerify writes the records as a dump in every format encode
+// reads, reads them back and encodes them, which must give the bytes the
+// records were stored as, as verify -via checks.
+func TestEncodeVerify(t *testing.T) {
+	scores := testScores()
+	for _, format := range readableFormats() {
+		t.Run(format, func(t *testing.T) {
+			read := dumpFormats[format].read
+			back, err := read(writeDump(t, format, scores), func(path, problem string) {
+				t.Errorf("%s: %s", path, problem)
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(back) != len(scores) {
+				t.Fatalf("read %d records back, want %d", len(back), len(scores))
+			}
+			for i := range back {
+				want, err := scores[i].MarshalBinary()
+				if err != nil {
+					t.Fatal(err)
+				}
+				got, err := back[i].MarshalBinary()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(got, want) {
+					t.Errorf("record %d encodes to\n%x\nwant\n%x", i, got, want)
+				}
+				if !reflect.DeepEqual(back[i].Score, scores[i].Score) {
+					t.Errorf("record %d read back as\n%+v\nwant\n%+v", i, back[i].Score, scores[i].Score)
+				}
+			}
+		})
+	}
+}
@@ -1,3 +1,9 @@
 module github.com/SirChronus/dtxmania-dbdump
 
-go 1.15
+go 1.26.0
+
+require (
+	github.com/klauspost/compress v1.20.1
+	golang.org/x/sys v0.48.0
+	golang.org/x/text v0.21.0
+)
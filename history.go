@@ -0,0 +1,275 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/SirChronus/dtxmania-dbdump/dtxdb"
+)
+
+// historyDir is where snapshot keeps its gzipped dumps, one file per
+// snapshot named after the time it was taken.
+var historyDir = filepath.Join(".dbdump", "history")
+
+const (
+	snapshotLayout = "20060102T150405Z"
+	snapshotExt    = ".xml.gz"
+)
+
+type snapshot struct {
+	path  string
+	taken time.Time
+}
+
+// listSnapshots returns the snapshots in historyDir, oldest first.
+func listSnapshots() []snapshot {
+	entries, err := os.ReadDir(historyDir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	logFatalIfError(err)
+
+	var snapshots []snapshot
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasSuffix(name, snapshotExt) {
+			continue
+		}
+		taken, err := time.Parse(snapshotLayout, strings.TrimSuffix(name, snapshotExt))
+		if err != nil {
+			continue
+		}
+		snapshots = append(snapshots, snapshot{filepath.Join(historyDir, name), taken})
+	}
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].taken.Before(snapshots[j].taken)
+	})
+
+	return snapshots
+}
+
+// writeSnapshot writes scores as a gzipped dump in the format of dump.xml.
+func writeSnapshot(path string, scores []score) {
+	var err error
+	outFile, err = os.Create(path)
+	logFatalIfError(err)
+	defer outFile.Close()
+	zw := gzip.NewWriter(outFile)
+	w := bufio.NewWriter(zw)
+
+	out, err := newXMLWriter(w)
+	logFatalIfError(err)
+	for i := range scores {
+		logFatalIfError(out.write(&scores[i]))
+	}
+	logFatalIfError(out.close())
+
+	logFatalIfError(w.Flush())
+	logFatalIfError(zw.Close())
+}
+
+func readSnapshot(path string) []score {
+	f, err := os.Open(path)
+	logFatalIfError(err)
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	logFatalIfError(err)
+
+	var dump struct {
+		Songs []score `xml:"song"`
+	}
+	if err := xml.NewDecoder(zr).Decode(&dump); err != nil {
+		log.Fatalf("%s: %v\n", path, err)
+	}
+	return dump.Songs
+}
+
+// pruneSnapshots removes all but the newest keep snapshots as well as those
+// older than maxAge. A zero keep or maxAge is no limit. The newest snapshot
+// is never removed.
+func pruneSnapshots(keep int, maxAge time.Duration) {
+	snapshots := listSnapshots()
+	now := time.Now()
+	for i, s := range snapshots[:len(snapshots)-1] {
+		tooMany := keep > 0 && len(snapshots)-i > keep
+		tooOld := maxAge > 0 && now.Sub(s.taken) > maxAge
+		if !tooMany && !tooOld {
+			continue
+		}
+		logFatalIfError(os.Remove(s.path))
+		infof("removed %s\n", s.path)
+	}
+}
+
+func runSnapshot(args []string) {
+	flags := newFlagSet("snapshot")
+	input := inputDBFlag(flags)
+	keep := flags.Int("keep", 30, "keep only the `n` newest snapshots, 0 keeps all")
+	days := flags.Int("days", 0, "remove snapshots older than `n` days, 0 keeps all")
+	mmapFlag(flags)
+	readerFlags(flags)
+	overridesFlag(flags)
+	flags.Parse(args)
+
+	_, scores := readAllScores(*input)
+
+	logFatalIfError(os.MkdirAll(historyDir, 0777))
+	taken := time.Now().UTC().Truncate(time.Second)
+	path := filepath.Join(historyDir, taken.Format(snapshotLayout)+snapshotExt)
+	if _, err := os.Stat(path); err == nil {
+		log.Fatalf("%s already exists\n", path)
+	}
+	writeSnapshot(path, scores)
+	infof("%d songs written to %s\n", len(scores), path)
+	logSkills(taken, scores)
+
+	pruneSnapshots(*keep, time.Duration(*days)*24*time.Hour)
+}
+
+func runHistory(args []string) {
+	flags := newFlagSet("history")
+	flags.Parse(args)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SNAPSHOT\tTAKEN\tSONGS\tSIZE")
+	for _, s := range listSnapshots() {
+		info, err := os.Stat(s.path)
+		logFatalIfError(err)
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\n", s.taken.Format(snapshotLayout), s.taken.Local().Format("2006-01-02 15:04"),
+			len(readSnapshot(s.path)), info.Size())
+	}
+	logFatalIfError(w.Flush())
+}
+
+// parseSince accepts the snapshot names printed by history as well as
+// RFC 3339 timestamps and local dates.
+func parseSince(v string) (time.Time, error) {
+	if t, err := time.Parse(snapshotLayout, v); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	for _, layout := range []string{"2006-01-02 15:04", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, v, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid timestamp %q", v)
+}
+
+// snapshotAt returns the newest snapshot taken at or before t.
+func snapshotAt(snapshots []snapshot, t time.Time) (snapshot, bool) {
+	for i := len(snapshots) - 1; i >= 0; i-- {
+		if !snapshots[i].taken.After(t) {
+			return snapshots[i], true
+		}
+	}
+	return snapshot{}, false
+}
+
+// playChanges describes what changed in the play data of an instrument
+// between two versions of a record, or returns "" if nothing did.
+func playChanges(old, cur *dtxdb.SongInformation, i dtxdb.Instrument) string {
+	var changes []string
+	if plays := cur.NbPerformance.Get(i) - old.NbPerformance.Get(i); plays != 0 {
+		changes = append(changes, fmt.Sprintf("%+d plays", plays))
+	}
+	if old.HighSkill.Get(i) != cur.HighSkill.Get(i) {
+		changes = append(changes, fmt.Sprintf("skill %.2f -> %.2f", old.HighSkill.Get(i), cur.HighSkill.Get(i)))
+	}
+	if old.BestRank.Get(i) != cur.BestRank.Get(i) {
+		changes = append(changes, fmt.Sprintf("rank %s -> %s", old.BestRankLetter(i), cur.BestRankLetter(i)))
+	}
+	if !old.FullCombo.Get(i) && cur.FullCombo.Get(i) {
+		changes = append(changes, "full combo")
+	}
+	return strings.Join(changes, ", ")
+}
+
+func songLabel(s *score) string {
+	return fmt.Sprintf("%s / %s", s.SongInformation.Title, s.SongInformation.Artist)
+}
+
+// printChangelog lists the songs added to and removed from the library
+// between old and cur, and the new results of the songs played in between.
+// Records are matched by identity.
+func printChangelog(w io.Writer, old, cur []score, identity string) (added, removed, played int) {
+	byKey := recordsByIdentity(identity, old)
+	oldKeys := recordKeys(identity, old)
+
+	for i, key := range recordKeys(identity, cur) {
+		s := &cur[i]
+		o, ok := byKey[key]
+		if !ok {
+			fmt.Fprintf(w, "+ %s\n", songLabel(s))
+			added++
+			continue
+		}
+		delete(byKey, key)
+
+		changed := false
+		for _, i := range dtxdb.Instruments {
+			if changes := playChanges(&o.SongInformation, &s.SongInformation, i); changes != "" {
+				fmt.Fprintf(w, "  %s: %s %s\n", songLabel(s), i, changes)
+				changed = true
+			}
+		}
+		if changed {
+			played++
+		}
+	}
+
+	for i, key := range oldKeys {
+		if o, ok := byKey[key]; ok && o == &old[i] {
+			fmt.Fprintf(w, "- %s\n", songLabel(&old[i]))
+			removed++
+		}
+	}
+
+	return added, removed, played
+}
+
+func runChangelog(args []string) {
+	flags := newFlagSet("changelog")
+	input := inputDBFlag(flags)
+	since := flags.String("since", "", "compare with the last snapshot taken at or before `timestamp` (default the newest snapshot)")
+	identity := identityFlag(flags)
+	mmapFlag(flags)
+	readerFlags(flags)
+	overridesFlag(flags)
+	flags.Parse(args)
+	logFatalIfError(checkIdentity(*identity))
+
+	snapshots := listSnapshots()
+	if len(snapshots) == 0 {
+		log.Fatalf("no snapshots in %s, see dbdump snapshot\n", historyDir)
+	}
+	base := snapshots[len(snapshots)-1]
+	if *since != "" {
+		t, err := parseSince(*since)
+		logFatalIfError(err)
+		var ok bool
+		if base, ok = snapshotAt(snapshots, t); !ok {
+			log.Fatalf("no snapshot taken before %s, the oldest is %s\n", *since, snapshots[0].taken.Format(snapshotLayout))
+		}
+	}
+
+	old := readSnapshot(base.path)
+	_, cur := readAllScores(*input)
+
+	w := bufio.NewWriter(os.Stdout)
+	added, removed, played := printChangelog(w, old, cur, *identity)
+	logFatalIfError(w.Flush())
+	infof("%d songs added, %d removed, %d played since %s\n", added, removed, played, base.taken.Format(snapshotLayout))
+}
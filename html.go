@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/SirChronus/dtxmania-dbdump/dtxdb"
+)
+
+// htmlPreviews is the folder of the chart previews written by the preview
+// command, set with dump -html-previews. The HTML report shows the preview
+// of every song found in it.
+var htmlPreviews string
+
+const htmlHead = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>DTXMania library</title>
+<style>
+body { font-family: sans-serif; margin: 1em; }
+input { font-size: 1em; padding: .3em; width: 20em; margin-bottom: 1em; }
+table { border-collapse: collapse; }
+th, td { padding: .2em .6em; border-bottom: 1px solid #ddd; text-align: left; }
+th { background: #d9e1f2; cursor: pointer; position: sticky; top: 0; user-select: none; }
+th.asc::after { content: " \25b2"; }
+th.desc::after { content: " \25bc"; }
+td.n { text-align: right; }
+td img { display: block; }
+</style>
+</head>
+<body>
+<input id="search" type="search" placeholder="Search title, artist or genre" autofocus>
+<span id="count"></span>
+<table id="songs">
+<thead><tr>`
+
+// htmlTail makes the table searchable and sortable by clicking a header.
+// Cells sort by their data-sort value when they have one.
+const htmlTail = `</tbody>
+</table>
+<script>
+var table = document.getElementById("songs"), body = table.tBodies[0];
+var rows = Array.prototype.slice.call(body.rows), count = document.getElementById("count");
+function key(row, i) {
+	var c = row.cells[i], v = c.getAttribute("data-sort");
+	return v === null ? c.textContent.toLowerCase() : parseFloat(v);
+}
+function search() {
+	var q = document.getElementById("search").value.toLowerCase(), shown = 0;
+	rows.forEach(function (row) {
+		var hit = row.getAttribute("data-text").indexOf(q) >= 0;
+		row.style.display = hit ? "" : "none";
+		if (hit) shown++;
+	});
+	count.textContent = shown + " of " + rows.length + " songs";
+}
+Array.prototype.forEach.call(table.tHead.rows[0].cells, function (th, i) {
+	th.onclick = function () {
+		var desc = th.className === "asc";
+		Array.prototype.forEach.call(table.tHead.rows[0].cells, function (h) { h.className = ""; });
+		th.className = desc ? "desc" : "asc";
+		rows.sort(function (a, b) {
+			var x = key(a, i), y = key(b, i), d = x < y ? -1 : x > y ? 1 : 0;
+			return desc ? -d : d;
+		});
+		rows.forEach(function (row) { body.appendChild(row); });
+	};
+});
+document.getElementById("search").oninput = search;
+search();
+</script>
+</body>
+</html>
+`
+
+// htmlWriter writes a standalone page with a table of the songs.
+type htmlWriter struct {
+	w io.Writer
+}
+
+func newHTMLWriter(w io.Writer) (recordWriter, error) {
+	header := []string{"Title", "Artist", "Genre"}
+	for _, i := range dtxdb.Instruments {
+		name := strings.ToUpper(i.String()[:1]) + i.String()[1:]
+		header = append(header, name+" level", name+" rank", name+" skill")
+	}
+	if htmlPreviews != "" {
+		header = append(header, "Preview")
+	}
+
+	if _, err := io.WriteString(w, htmlHead); err != nil {
+		return nil, err
+	}
+	for _, h := range header {
+		if _, err := fmt.Fprintf(w, "<th>%s</th>", html.EscapeString(h)); err != nil {
+			return nil, err
+		}
+	}
+	_, err := io.WriteString(w, "</tr></thead>\n<tbody>\n")
+	return &htmlWriter{w}, err
+}
+
+func (h *htmlWriter) write(s *score) error {
+	info := &s.SongInformation
+	text := searchKey(info.Title + " " + info.Artist + " " + info.Genre)
+	row := fmt.Sprintf(`<tr data-text="%s"><td>%s</td><td>%s</td><td>%s</td>`,
+		html.EscapeString(text), html.EscapeString(info.Title), html.EscapeString(info.Artist), html.EscapeString(info.Genre))
+
+	for _, i := range dtxdb.Instruments {
+		if info.Level.Get(i) == 0 {
+			row += `<td data-sort="-1"></td><td data-sort="-1"></td><td data-sort="-1"></td>`
+			continue
+		}
+		// Ranks sort by their number, SS being 0 and no rank 99.
+		rank, rankSort := "", int32(dtxdb.NoRank)
+		if r := info.BestRank.Get(i); r != dtxdb.NoRank {
+			rank, rankSort = dtxdb.RankName(r), r
+		}
+		level := info.CombinedLevel(i)
+		skill := info.HighSkill.Get(i)
+		row += fmt.Sprintf(`<td class="n" data-sort="%g">%.2f</td><td data-sort="%d">%s</td><td class="n" data-sort="%g">%.2f</td>`,
+			level, level, rankSort, rank, skill, skill)
+	}
+
+	if htmlPreviews != "" {
+		row += "<td>"
+		for _, ext := range []string{".svg", ".png"} {
+			name := songID(s) + ext
+			if _, err := os.Stat(filepath.Join(htmlPreviews, name)); err == nil {
+				src := path.Join(filepath.ToSlash(htmlPreviews), name)
+				row += fmt.Sprintf(`<img src="%s" alt="" loading="lazy" width="200">`, html.EscapeString(src))
+				break
+			}
+		}
+		row += "</td>"
+	}
+
+	_, err := io.WriteString(h.w, row+"</tr>\n")
+	return err
+}
+
+// buffered is always 0, rows are written as a whole.
+func (h *htmlWriter) buffered() int {
+	return 0
+}
+
+func (h *htmlWriter) close() error {
+	_, err := io.WriteString(h.w, htmlTail)
+	return err
+}
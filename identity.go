@@ -0,0 +1,292 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/SirChronus/dtxmania-dbdump/dtxdb"
+)
+
+// Identities decide which records of two databases are the same song.
+// Comparing two states of one library, the chart path is enough; libraries
+// of different machines need a key that does not depend on where the songs
+// are installed.
+const (
+	// identityPath is the absolute chart path.
+	identityPath = "path"
+	// identityRelative is the chart path below the song folder, the folder
+	// every chart of the database is in.
+	identityRelative = "relative"
+	// identityContent is a hash of what DTXMania read from the chart file.
+	identityContent = "content"
+	// identitySong is the title, the artist, the song type and the chart
+	// file name, which tells the difficulties of a song apart like songID.
+	identitySong = "song"
+	// identitySize is the title, the artist and the size of the chart file,
+	// which finds the copies of a chart in other folders.
+	identitySize = "size"
+)
+
+var identityNames = []string{identityPath, identityRelative, identityContent, identitySong, identitySize}
+
+func identityFlag(flags *flag.FlagSet) *string {
+	return flags.String("identity", identityPath, "match the records of the databases by `key`: "+strings.Join(identityNames, ", "))
+}
+
+func checkIdentity(identity string) error {
+	for _, name := range identityNames {
+		if identity == name {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown identity %q, use one of %s", identity, strings.Join(identityNames, ", "))
+}
+
+// normalizeChartPath lower cases a path and uses backslashes, the way
+// Windows compares them.
+func normalizeChartPath(path string) string {
+	return strings.ToLower(strings.ReplaceAll(path, "/", `\`))
+}
+
+// songRoot returns the longest folder every chart of scores is in.
+func songRoot(scores []score) string {
+	if len(scores) == 0 {
+		return ""
+	}
+	root := normalizeChartPath(scores[0].FileInformation.AbsoluteFilePath)
+	for i := range scores[1:] {
+		path := normalizeChartPath(scores[i+1].FileInformation.AbsoluteFilePath)
+		for !strings.HasPrefix(path, root) {
+			root = root[:len(root)-1]
+		}
+	}
+	return root[:strings.LastIndex(root, `\`)+1]
+}
+
+// contentHash hashes the fields DTXMania reads from the chart file, leaving
+// out its path, its date and the play data.
+func contentHash(s *score) string {
+	info := &s.SongInformation
+	h := sha1.New()
+	for _, v := range []interface{}{
+		s.FileInformation.FileSize, info.Title, info.Artist, info.Comment, info.Genre,
+		info.Level, info.LevelDec, info.HiddenLevel, info.Classic, info.SongType, info.Bpm, info.Duration,
+	} {
+		fmt.Fprint(h, v, "\x00")
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// recordKeys returns the identity of every record of scores.
+func recordKeys(identity string, scores []score) []string {
+	keys := make([]string, len(scores))
+	root := ""
+	if identity == identityRelative {
+		root = songRoot(scores)
+	}
+	for i := range scores {
+		s := &scores[i]
+		switch identity {
+		case identityPath:
+			keys[i] = s.FileInformation.AbsoluteFilePath
+		case identityRelative:
+			keys[i] = strings.TrimPrefix(normalizeChartPath(s.FileInformation.AbsoluteFilePath), root)
+		case identityContent:
+			keys[i] = contentHash(s)
+		case identitySong:
+			keys[i] = fmt.Sprintf("%s\x00%s\x00%s\x00%s",
+				strings.ToLower(strings.TrimSpace(s.SongInformation.Title)),
+				strings.ToLower(strings.TrimSpace(s.SongInformation.Artist)),
+				s.SongInformation.SongType,
+				strings.ToLower(chartFileName(s)))
+		case identitySize:
+			keys[i] = fmt.Sprintf("%s\x00%s\x00%d",
+				strings.ToLower(strings.TrimSpace(s.SongInformation.Title)),
+				strings.ToLower(strings.TrimSpace(s.SongInformation.Artist)),
+				s.FileInformation.FileSize)
+		}
+	}
+	return keys
+}
+
+// recordsByIdentity indexes scores by identity. Records sharing the key of
+// an earlier one are logged and left out, so only the first is matched.
+func recordsByIdentity(identity string, scores []score) map[string]*score {
+	byKey := make(map[string]*score, len(scores))
+	for i, key := range recordKeys(identity, scores) {
+		if first, ok := byKey[key]; ok {
+			log.Printf("warning: %s has the same %s as %s, only the first is matched\n",
+				scores[i].FileInformation.AbsoluteFilePath, identity, first.FileInformation.AbsoluteFilePath)
+			continue
+		}
+		byKey[key] = &scores[i]
+	}
+	return byKey
+}
+
+// Duplicate policies choose the record kept of those sharing an identity
+// key before a database is written, DTXMania gets confused by charts
+// listed twice.
+const (
+	duplicatesFirst       = "keep-first"
+	duplicatesNewest      = "keep-newest"
+	duplicatesHighest     = "keep-highest-score"
+	duplicatesMostPlayed  = "keep-most-played"
+	duplicatesInteractive = "interactive"
+)
+
+var duplicatePolicyNames = []string{duplicatesFirst, duplicatesNewest, duplicatesHighest, duplicatesMostPlayed, duplicatesInteractive}
+
+func checkDuplicatePolicy(policy string) error {
+	for _, name := range duplicatePolicyNames {
+		if policy == name {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown duplicate policy %q, use one of %s", policy, strings.Join(duplicatePolicyNames, ", "))
+}
+
+func lastModifiedTicks(s *score) int64 {
+	return s.FileInformation.LastModified.Ticks()
+}
+
+func highestSkill(s *score) float64 {
+	best := 0.0
+	for _, i := range dtxdb.Instruments {
+		if sk := s.SongInformation.HighSkill.Get(i); sk > best {
+			best = sk
+		}
+	}
+	return best
+}
+
+// historyLines counts the lines of the performance history of s.
+func historyLines(s *score) int {
+	h := &s.SongInformation.PerformanceHistory
+	n := 0
+	for _, line := range []string{h.First, h.Second, h.Third, h.Fourth, h.Fifth} {
+		if line != "" {
+			n++
+		}
+	}
+	return n
+}
+
+// askDuplicate lets the user pick one of the records of a duplicate key.
+func askDuplicate(in *bufio.Reader, identity string, scores []score, group []int) (int, error) {
+	fmt.Fprintf(os.Stderr, "%d records have the same %s:\n", len(group), identity)
+	for n, i := range group {
+		s := &scores[i]
+		fmt.Fprintf(os.Stderr, "  %d) %s, modified %s, skill %.2f, %d plays\n",
+			n+1, s.FileInformation.AbsoluteFilePath, s.FileInformation.LastModified, highestSkill(s), totalPlays(s))
+	}
+	for {
+		fmt.Fprintf(os.Stderr, "keep [1-%d]: ", len(group))
+		line, err := in.ReadString('\n')
+		if n, convErr := strconv.Atoi(strings.TrimSpace(line)); convErr == nil && n >= 1 && n <= len(group) {
+			return group[n-1], nil
+		}
+		if err != nil {
+			return 0, fmt.Errorf("no record chosen: %w", err)
+		}
+	}
+}
+
+// resolveDuplicates keeps one record of every identity key of scores,
+// chosen by policy, where the first of them was. Records of different
+// chart files, like the difficulties of a song, are never duplicates. It
+// returns the records kept and the number removed.
+func resolveDuplicates(identity, policy string, scores []score) ([]score, int, error) {
+	keys := recordKeys(identity, scores)
+	for i := range keys {
+		keys[i] += "\x00" + strings.ToLower(chartFileName(&scores[i]))
+	}
+	groups := map[string][]int{}
+	for i, key := range keys {
+		groups[key] = append(groups[key], i)
+	}
+
+	var in *bufio.Reader
+	var kept []score
+	removed := 0
+	for i, key := range keys {
+		group := groups[key]
+		if group[0] != i {
+			continue
+		}
+		keep := i
+		switch policy {
+		case duplicatesNewest:
+			for _, j := range group[1:] {
+				if lastModifiedTicks(&scores[j]) > lastModifiedTicks(&scores[keep]) {
+					keep = j
+				}
+			}
+		case duplicatesHighest:
+			for _, j := range group[1:] {
+				a, b := &scores[j], &scores[keep]
+				if highestSkill(a) > highestSkill(b) || highestSkill(a) == highestSkill(b) && totalPlays(a) > totalPlays(b) {
+					keep = j
+				}
+			}
+		case duplicatesMostPlayed:
+			for _, j := range group[1:] {
+				a, b := &scores[j], &scores[keep]
+				if totalPlays(a) > totalPlays(b) || totalPlays(a) == totalPlays(b) && historyLines(a) > historyLines(b) {
+					keep = j
+				}
+			}
+		case duplicatesInteractive:
+			if len(group) > 1 {
+				if in == nil {
+					in = bufio.NewReader(os.Stdin)
+				}
+				var err error
+				if keep, err = askDuplicate(in, identity, scores, group); err != nil {
+					return nil, 0, err
+				}
+			}
+		}
+
+		for _, j := range group {
+			if j != keep {
+				infof("duplicate %s: %s dropped, keeping %s\n", identity,
+					scores[j].FileInformation.AbsoluteFilePath, scores[keep].FileInformation.AbsoluteFilePath)
+			}
+		}
+		removed += len(group) - 1
+		kept = append(kept, scores[keep])
+	}
+	return kept, removed, nil
+}
+
+func runDedupe(args []string) {
+	flags := newFlagSet("dedupe")
+	input := inputDBFlag(flags)
+	output := outputDBFlag(flags)
+	identity := identityFlag(flags)
+	duplicates := flags.String("duplicates", duplicatesMostPlayed, "`policy` choosing the record kept of those sharing an identity: "+strings.Join(duplicatePolicyNames, ", "))
+	dryRun := flags.Bool("n", false, "only report the duplicates")
+	readerFlags(flags)
+	flags.Parse(args)
+	logFatalIfError(checkIdentity(*identity))
+	logFatalIfError(checkDuplicatePolicy(*duplicates))
+
+	versionString, scores := readAllScores(*input)
+	kept, removed, err := resolveDuplicates(*identity, *duplicates, scores)
+	logFatalIfError(err)
+	infof("%d duplicates dropped, %d records kept\n", removed, len(kept))
+	if *dryRun {
+		return
+	}
+
+	writeSongsDB(*output, versionString, kept)
+	infof("written %s\n", *output)
+}
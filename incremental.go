@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// incrementalDump tells the records that changed since the previous run
+// apart from those that did not. Records are identified by chart path and
+// compared by a hash of their content. The hashes of the previous run are
+// kept in <output>.state, so every dump has its own.
+type incrementalDump struct {
+	statePath string
+	previous  map[string]string
+	current   map[string]string
+	changed   int
+}
+
+func loadIncrementalDump(output string) *incrementalDump {
+	d := &incrementalDump{statePath: output + ".state", previous: map[string]string{}, current: map[string]string{}}
+
+	f, err := os.Open(d.statePath)
+	if os.IsNotExist(err) {
+		infof("no %s yet, dumping every record\n", d.statePath)
+		return d
+	}
+	logFatalIfError(err)
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1024*1024)
+	for scanner.Scan() {
+		hash, path, ok := strings.Cut(scanner.Text(), "\t")
+		if !ok {
+			continue
+		}
+		d.previous[path] = hash
+	}
+	logFatalIfError(scanner.Err())
+	return d
+}
+
+func recordHash(s *score) string {
+	data, err := xml.Marshal(s)
+	logFatalIfError(err)
+	sum := sha1.Sum(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// add records s and reports whether it is new or changed.
+func (d *incrementalDump) add(s *score) bool {
+	path := s.FileInformation.AbsoluteFilePath
+	hash := recordHash(s)
+	d.current[path] = hash
+
+	if old, ok := d.previous[path]; ok && old == hash {
+		return false
+	}
+	d.changed++
+	return true
+}
+
+// removed returns the paths of the records gone since the previous run.
+func (d *incrementalDump) removed() []string {
+	var paths []string
+	for path := range d.previous {
+		if _, ok := d.current[path]; !ok {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func writeLines(path string, lines []string) {
+	var err error
+	outFile, err = os.Create(path)
+	logFatalIfError(err)
+	defer outFile.Close()
+	w := bufio.NewWriter(outFile)
+	for _, l := range lines {
+		_, err := fmt.Fprintln(w, l)
+		logFatalIfError(err)
+	}
+	logFatalIfError(w.Flush())
+}
+
+// finish writes the tombstone list and the state for the next run next to
+// the dump.
+func (d *incrementalDump) finish(output string) {
+	removed := d.removed()
+	writeLines(output+".removed", removed)
+	infof("%d records changed, %d removed, removed paths written to %s\n", d.changed, len(removed), output+".removed")
+
+	paths := make([]string, 0, len(d.current))
+	for path := range d.current {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	lines := make([]string, len(paths))
+	for i, path := range paths {
+		lines[i] = d.current[path] + "\t" + path
+	}
+	writeLines(d.statePath, lines)
+}
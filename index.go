@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// titleIndex is the search index written by the index command. Keys are
+// sorted, so the songs whose title or reading has a word starting with
+// what was typed so far are those of the range of keys with that prefix,
+// found with two binary searches.
+type titleIndex struct {
+	Version int           `json:"version"`
+	Songs   []songSummary `json:"songs"`
+	Keys    []indexKey    `json:"keys"`
+}
+
+// indexKey is a normalised title or reading from the start of one of its
+// words, with the positions in Songs of the songs having it.
+type indexKey struct {
+	Key   string `json:"key"`
+	Songs []int  `json:"songs"`
+}
+
+// searchKey normalises text the way companion tools are expected to
+// normalise what is typed: lower case, full-width letters folded and
+// spaces collapsed.
+func searchKey(s string) string {
+	return strings.Join(strings.Fields(strings.ToLower(foldWidth(s))), " ")
+}
+
+// wordSuffixes returns key from the start of each of its words.
+func wordSuffixes(key string) []string {
+	var suffixes []string
+	start := true
+	for i, r := range key {
+		word := unicode.IsLetter(r) || unicode.IsDigit(r)
+		if word && start {
+			suffixes = append(suffixes, key[i:])
+		}
+		start = !word
+	}
+	return suffixes
+}
+
+func buildTitleIndex(t transliterator, scores []score) *titleIndex {
+	index := &titleIndex{Version: 1}
+	songsOf := map[string][]int{}
+	for i := range scores {
+		s := &scores[i]
+		index.Songs = append(index.Songs, songSummaryOf(s))
+
+		keys := map[string]bool{}
+		for _, text := range []string{s.SongInformation.Title, t.Transliterate(s.SongInformation.Title)} {
+			for _, k := range wordSuffixes(searchKey(text)) {
+				keys[k] = true
+			}
+		}
+		for k := range keys {
+			songsOf[k] = append(songsOf[k], i)
+		}
+	}
+
+	for k, songs := range songsOf {
+		index.Keys = append(index.Keys, indexKey{k, songs})
+	}
+	sort.Slice(index.Keys, func(i, j int) bool {
+		return index.Keys[i].Key < index.Keys[j].Key
+	})
+	return index
+}
+
+func runIndex(args []string) {
+	flags := newFlagSet("index")
+	input := inputDBFlag(flags)
+	output := flags.String("o", "titles.json", "write the index to `file`")
+	translitName := flags.String("transliterator", "romaji", "`name` of the transliterator generating readings: romaji or none")
+	readerFlags(flags)
+	flags.Parse(args)
+	translit, err := lookupTransliterator(*translitName)
+	logFatalIfError(err)
+
+	_, scores := readAllScores(*input)
+	index := buildTitleIndex(translit, scores)
+
+	outFile, err = os.Create(*output)
+	logFatalIfError(err)
+	defer outFile.Close()
+	w := bufio.NewWriter(outFile)
+	logFatalIfError(json.NewEncoder(w).Encode(index))
+	logFatalIfError(w.Flush())
+
+	infof("%d keys of %d songs written to %s\n", len(index.Keys), len(index.Songs), *output)
+}
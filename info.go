@@ -0,0 +1,124 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strings"
+
+	"github.com/SirChronus/dtxmania-dbdump/dtxdb"
+)
+
+type formatInfo struct {
+	Name      string `json:"name"`
+	Extension string `json:"extension"`
+	Readable  bool   `json:"readable"`
+	Schema    bool   `json:"schema"`
+}
+
+// buildInfo is what info reports, for wrappers adapting to the dbdump
+// they find installed. Keys are only ever added, a feature missing from
+// Features means the build predates it.
+type buildInfo struct {
+	Version         string          `json:"version"`
+	GoVersion       string          `json:"go-version"`
+	DBVersions      []string        `json:"db-versions"`
+	Commands        []string        `json:"commands"`
+	Formats         []formatInfo    `json:"formats"`
+	Sorts           []string        `json:"sorts"`
+	StatsGroups     []string        `json:"stats-groups"`
+	Transliterators []string        `json:"transliterators"`
+	Identities      []string        `json:"identities"`
+	MergePolicies   []string        `json:"merge-policies"`
+	Features        map[string]bool `json:"features"`
+}
+
+// sortedKeys returns the keys of a map with string keys in order.
+func sortedKeys(m interface{}) []string {
+	var keys []string
+	for _, k := range reflect.ValueOf(m).MapKeys() {
+		keys = append(keys, k.String())
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func currentBuildInfo() buildInfo {
+	info := buildInfo{
+		Version:         "(devel)",
+		GoVersion:       runtime.Version(),
+		DBVersions:      dtxdb.SupportedVersions,
+		Sorts:           sortedKeys(scoreSorters),
+		StatsGroups:     sortedKeys(statsGroupKeys),
+		Transliterators: sortedKeys(transliterators),
+		Identities:      identityNames,
+		MergePolicies:   mergePolicyNames,
+		Features: map[string]bool{
+			"mmap":        mmapSupported,
+			"remote-db":   true,
+			"incremental": true,
+			"profile":     true,
+			"overrides":   true,
+			"quarantine":  true,
+			"config":      true,
+		},
+	}
+	if bi, ok := debug.ReadBuildInfo(); ok && bi.Main.Version != "" {
+		info.Version = bi.Main.Version
+	}
+	for _, c := range commands {
+		info.Commands = append(info.Commands, c.name)
+	}
+	for _, name := range strings.Split(formatNames(), ", ") {
+		f := dumpFormats[name]
+		info.Formats = append(info.Formats, formatInfo{name, f.ext, f.read != nil, f.schema != nil})
+	}
+	return info
+}
+
+func runInfo(args []string) {
+	flags := newFlagSet("info")
+	asJSON := flags.Bool("json", false, "print the information as JSON")
+	flags.Parse(args)
+
+	info := currentBuildInfo()
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		logFatalIfError(enc.Encode(info))
+		return
+	}
+
+	var formats, features []string
+	for _, f := range info.Formats {
+		formats = append(formats, f.Name)
+	}
+	for name, ok := range info.Features {
+		if ok {
+			features = append(features, name)
+		}
+	}
+	sort.Strings(features)
+
+	fmt.Printf("dbdump %s, %s\n", info.Version, info.GoVersion)
+	for _, l := range []struct {
+		label  string
+		values []string
+	}{
+		{"database versions", info.DBVersions},
+		{"commands", info.Commands},
+		{"formats", formats},
+		{"sorts", info.Sorts},
+		{"stats groups", info.StatsGroups},
+		{"transliterators", info.Transliterators},
+		{"identities", info.Identities},
+		{"merge policies", info.MergePolicies},
+		{"features", features},
+	} {
+		fmt.Printf("%-18s %s\n", l.label+":", strings.Join(l.values, ", "))
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// expandInputs returns the databases named by paths, the arguments of dump
+// or else its -i. Glob patterns like nx/*/songs.db are expanded here as the
+// Windows shells leave them alone.
+func expandInputs(paths []string) []string {
+	var inputs []string
+	for _, path := range paths {
+		if path == "-" || isRemoteDB(path) || !strings.ContainsAny(path, "*?[") {
+			inputs = append(inputs, path)
+			continue
+		}
+		matches, err := filepath.Glob(path)
+		logFatalIfError(err)
+		if len(matches) == 0 {
+			log.Fatalf("no database matches %s\n", path)
+		}
+		inputs = append(inputs, matches...)
+	}
+	return inputs
+}
+
+// eachOutput names the dump of input written with -each: the file name of
+// output in the folder of input.
+func eachOutput(input, output string) string {
+	return filepath.Join(filepath.Dir(input), filepath.Base(output))
+}
+
+// inputChain reads the records of several databases one after the other,
+// as if they were one. With more than one database every record names the
+// database it was read from in its source field.
+type inputChain struct {
+	paths []string
+	next  int
+	// done is the size of the databases read to the end.
+	done int64
+}
+
+// open opens the next database of the chain for readNextScore and returns
+// its version string.
+func (c *inputChain) open() string {
+	if c.next > 0 {
+		c.done += dbReader.Offset()
+		file.Close()
+	}
+	path := c.paths[c.next]
+	c.next++
+	versionString := openSongsDB(path)
+	if len(c.paths) > 1 {
+		infof("SongDB version of %s: %s\n", path, versionString)
+	} else {
+		infof("SongDB version: %s\n", versionString)
+	}
+	return versionString
+}
+
+// read reads the next record into s, opening the next database once the
+// current one is exhausted, and returns the offset of the record in its
+// database. It returns false after the last record of the last database.
+func (c *inputChain) read(s *score) (int64, bool) {
+	for {
+		offset := dbReader.Offset()
+		if readNextScore(s) {
+			if len(c.paths) > 1 {
+				s.Source = c.paths[c.next-1]
+			}
+			return offset, true
+		}
+		if c.next == len(c.paths) {
+			return 0, false
+		}
+		c.open()
+	}
+}
+
+// offset returns the number of bytes read from all the databases so far.
+func (c *inputChain) offset() int64 {
+	return c.done + dbReader.Offset()
+}
+
+// size returns the total size of the databases, or 0 if the size of one
+// of them, like the standard input, is unknown.
+func (c *inputChain) size() int64 {
+	var total int64
+	for _, path := range c.paths {
+		if path == "-" || isRemoteDB(path) {
+			return 0
+		}
+		fi, err := os.Stat(path)
+		if err != nil {
+			return 0
+		}
+		total += fi.Size()
+	}
+	return total
+}
+
+// close closes the database read last.
+func (c *inputChain) close() {
+	if file != nil {
+		file.Close()
+	}
+}
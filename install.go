@@ -0,0 +1,132 @@
+package main
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+func readBundleManifest(z *zip.ReadCloser) (bundleManifest, error) {
+	var manifest bundleManifest
+	for _, f := range z.File {
+		if f.Name != bundleManifestName {
+			continue
+		}
+
+		r, err := f.Open()
+		if err != nil {
+			return manifest, err
+		}
+		defer r.Close()
+		err = xml.NewDecoder(r).Decode(&manifest)
+		return manifest, err
+	}
+
+	return manifest, fmt.Errorf("%s not found, not a bundle created by dbdump bundle", bundleManifestName)
+}
+
+// bundleEntryPath returns where the bundle entry name is extracted below
+// root, refusing names that would escape it.
+func bundleEntryPath(root, name string) (string, error) {
+	clean := path.Clean(name)
+	if path.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, "../") || strings.Contains(clean, `\`) || strings.Contains(clean, ":") {
+		return "", fmt.Errorf("invalid file name %q in bundle", name)
+	}
+
+	return filepath.Join(root, filepath.FromSlash(clean)), nil
+}
+
+func extractZipFile(f *zip.File, target string) error {
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+
+	r, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	w, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(w, r); err != nil {
+		w.Close()
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	return os.Chtimes(target, f.Modified, f.Modified)
+}
+
+func runInstall(args []string) {
+	flags := newFlagSet("install")
+	input := inputDBFlag(flags)
+	root := flags.String("root", "DTXFiles", "song `folder` the bundle is unpacked into")
+	output := outputDBFlag(flags)
+	readerFlags(flags)
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		log.Fatalln("install needs the bundle to install")
+	}
+
+	z, err := zip.OpenReader(flags.Arg(0))
+	logFatalIfError(err)
+	defer z.Close()
+	manifest, err := readBundleManifest(z)
+	logFatalIfError(err)
+
+	// Refuse to mix a bundle into song folders that already exist rather
+	// than overwriting charts and their score.ini files.
+	for _, s := range manifest.Songs {
+		target, err := bundleEntryPath(*root, s.Folder)
+		logFatalIfError(err)
+		if _, err := os.Stat(target); err == nil {
+			log.Fatalf("%s already exists, is the bundle installed already?\n", target)
+		}
+	}
+
+	for _, f := range z.File {
+		if f.Name == bundleManifestName || strings.HasSuffix(f.Name, "/") {
+			continue
+		}
+		target, err := bundleEntryPath(*root, f.Name)
+		logFatalIfError(err)
+		logFatalIfError(extractZipFile(f, target))
+	}
+
+	versionString, scores := readAllScores(*input)
+	known := make(map[string]bool, len(scores))
+	for i := range scores {
+		known[strings.ToLower(scores[i].FileInformation.AbsoluteFilePath)] = true
+	}
+
+	added := 0
+	for _, s := range manifest.Songs {
+		chart, err := bundleEntryPath(*root, s.Folder+"/"+s.File)
+		logFatalIfError(err)
+		record, err := newScoreFromChart(chart)
+		if err != nil {
+			log.Printf("skipping %s: %v\n", chart, err)
+			continue
+		}
+		if known[strings.ToLower(record.FileInformation.AbsoluteFilePath)] {
+			continue
+		}
+
+		scores = append(scores, record)
+		added++
+	}
+
+	writeSongsDB(*output, versionString, scores)
+	infof("%s installed into %s, %d charts registered in %s\n", manifest.Name, *root, added, *output)
+}
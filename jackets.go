@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"log"
+	"math/bits"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	// Image formats DTXMania jackets are commonly stored in.
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+)
+
+// songFilePath resolves a file referenced by a chart, such as its
+// PreImage, which DTXMania stores relative to the song folder.
+func songFilePath(s *score, name string) string {
+	path := strings.ReplaceAll(s.FileInformation.AbsoluteFolderPath+name, `\`, "/")
+	return filepath.FromSlash(path)
+}
+
+// differenceHash computes a 64 bit perceptual hash of img: the image is
+// shrunk to 9x8 grey pixels and every bit tells whether a pixel is brighter
+// than its right neighbour. Visually similar images have hashes differing
+// in only a few bits.
+func differenceHash(img image.Image) uint64 {
+	const w, h = 9, 8
+	b := img.Bounds()
+
+	var grey [h][w]float64
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			x0, x1 := b.Min.X+x*b.Dx()/w, b.Min.X+(x+1)*b.Dx()/w
+			y0, y1 := b.Min.Y+y*b.Dy()/h, b.Min.Y+(y+1)*b.Dy()/h
+			if x1 == x0 {
+				x1++
+			}
+			if y1 == y0 {
+				y1++
+			}
+
+			var sum float64
+			for py := y0; py < y1; py++ {
+				for px := x0; px < x1; px++ {
+					r, g, b, _ := img.At(px, py).RGBA()
+					sum += 0.299*float64(r) + 0.587*float64(g) + 0.114*float64(b)
+				}
+			}
+			grey[y][x] = sum / float64((x1-x0)*(y1-y0))
+		}
+	}
+
+	var hash uint64
+	for y := 0; y < h; y++ {
+		for x := 0; x < w-1; x++ {
+			hash <<= 1
+			if grey[y][x] > grey[y][x+1] {
+				hash |= 1
+			}
+		}
+	}
+	return hash
+}
+
+func hashImageFile(path string) (uint64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return 0, err
+	}
+	return differenceHash(img), nil
+}
+
+type jacket struct {
+	hash uint64
+	song *score
+}
+
+func describeSong(s *score) string {
+	return fmt.Sprintf("%s - %s (%s)", s.SongInformation.Artist, s.SongInformation.Title, s.FileInformation.AbsoluteFolderPath)
+}
+
+func runJackets(args []string) {
+	flags := newFlagSet("jackets")
+	input := inputDBFlag(flags)
+	threshold := flags.Int("threshold", 4, "report jackets whose hashes differ in at most `n` of 64 bits")
+	readerFlags(flags)
+	flags.Parse(args)
+
+	_, scores := readAllScores(*input)
+
+	// The charts of a song folder usually share their jacket, so every
+	// image is only hashed and compared once per folder.
+	var jackets []jacket
+	seen := make(map[string]bool)
+	failed := 0
+	for i := range scores {
+		s := &scores[i]
+		if s.SongInformation.PreImage == "" {
+			continue
+		}
+		path := songFilePath(s, s.SongInformation.PreImage)
+		if seen[path] {
+			continue
+		}
+		seen[path] = true
+
+		hash, err := hashImageFile(path)
+		if err != nil {
+			failed++
+			continue
+		}
+		jackets = append(jackets, jacket{hash, s})
+	}
+	if failed > 0 {
+		log.Printf("%d jackets could not be read or are in an unsupported format\n", failed)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "DISTANCE\tSONG\tLOOKS LIKE")
+	for i := range jackets {
+		for j := i + 1; j < len(jackets); j++ {
+			a, b := jackets[i].song, jackets[j].song
+			distance := bits.OnesCount64(jackets[i].hash ^ jackets[j].hash)
+			if distance > *threshold {
+				continue
+			}
+			if a.SongInformation.Title == b.SongInformation.Title && a.SongInformation.Artist == b.SongInformation.Artist {
+				// Same metadata is already caught by text matching.
+				continue
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\n", distance, describeSong(a), describeSong(b))
+		}
+	}
+	logFatalIfError(w.Flush())
+}
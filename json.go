@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+)
+
+// flattenFields is set with dump -flatten: the JSON formats then write
+// every record as a flat object keyed by the field names with underscores,
+// like level_drums, and the csv columns are named the same way.
+var flattenFields bool
+
+// marshalRecord encodes s as the JSON object of the dump, flattened if
+// flattenFields is set.
+func marshalRecord(s *score) ([]byte, error) {
+	if !flattenFields {
+		return json.Marshal(s)
+	}
+	b := []byte{'{'}
+	for i, f := range scoreFields(s) {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		key, err := json.Marshal(sqlColumnName(f.name))
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(f.value.Interface())
+		if err != nil {
+			return nil, err
+		}
+		b = append(append(append(b, key...), ':'), value...)
+	}
+	return append(b, '}'), nil
+}
+
+// jsonWriter writes the records as a JSON array of objects named like the
+// elements of dump.xml.
+type jsonWriter struct {
+	w     io.Writer
+	first bool
+}
+
+func newJSONWriter(w io.Writer) (recordWriter, error) {
+	if _, err := io.WriteString(w, "["); err != nil {
+		return nil, err
+	}
+	return &jsonWriter{w, true}, nil
+}
+
+func (j *jsonWriter) write(s *score) error {
+	record, err := marshalRecord(s)
+	if err != nil {
+		return err
+	}
+	// With -compact the records are written as they are, on one line.
+	var data bytes.Buffer
+	sep := ","
+	if compactOutput {
+		data.Write(record)
+	} else {
+		if err := json.Indent(&data, record, "  ", "  "); err != nil {
+			return err
+		}
+		sep = ",\n  "
+	}
+	if j.first {
+		sep, j.first = strings.TrimPrefix(sep, ","), false
+	}
+	if _, err := io.WriteString(j.w, sep); err != nil {
+		return err
+	}
+	_, err = data.WriteTo(j.w)
+	return err
+}
+
+// buffered is always 0, records are written as a whole.
+func (j *jsonWriter) buffered() int {
+	return 0
+}
+
+func (j *jsonWriter) close() error {
+	if compactOutput {
+		_, err := io.WriteString(j.w, "]")
+		return err
+	}
+	_, err := io.WriteString(j.w, "\n]\n")
+	return err
+}
+
+// ndjsonWriter writes every record as a JSON object on its own line, so
+// the dump can be processed as a stream while it is written.
+type ndjsonWriter struct {
+	w io.Writer
+}
+
+func newNDJSONWriter(w io.Writer) (recordWriter, error) {
+	return &ndjsonWriter{w}, nil
+}
+
+func (n *ndjsonWriter) write(s *score) error {
+	record, err := marshalRecord(s)
+	if err != nil {
+		return err
+	}
+	_, err = n.w.Write(append(record, '\n'))
+	return err
+}
+
+// buffered is always 0, every record is written at once.
+func (n *ndjsonWriter) buffered() int {
+	return 0
+}
+
+func (n *ndjsonWriter) close() error {
+	return nil
+}
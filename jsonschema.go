@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"reflect"
+	"strings"
+
+	"github.com/SirChronus/dtxmania-dbdump/dtxdb"
+)
+
+// The JSON Schema of the JSON dump, draft 2020-12, describes the array of
+// songs with a definition per struct, named like the XSD types. The
+// records of ndjson dumps are each a #/$defs/Song.
+
+// jsonSchemaProperties keeps the properties of an object in dump order,
+// which a map would sort.
+type jsonSchemaProperties struct {
+	names   []string
+	schemas []interface{}
+}
+
+func (p jsonSchemaProperties) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, name := range p.names {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		key, err := json.Marshal(name)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(p.schemas[i])
+		if err != nil {
+			return nil, err
+		}
+		b.Write(key)
+		b.WriteByte(':')
+		b.Write(value)
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
+
+type jsonSchemaObject struct {
+	Type                 string               `json:"type"`
+	Properties           jsonSchemaProperties `json:"properties"`
+	Required             []string             `json:"required"`
+	AdditionalProperties bool                 `json:"additionalProperties"`
+}
+
+func jsonSchemaRef(t reflect.Type) map[string]string {
+	return map[string]string{"$ref": "#/$defs/" + schemaTypeName(t)}
+}
+
+func jsonSchemaType(t reflect.Type) interface{} {
+	switch t {
+	case songTypeType:
+		return jsonSchemaRef(t)
+	case dateType:
+		return map[string]string{"type": "string", "format": "date-time"}
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]string{"type": "string"}
+	case reflect.Bool:
+		return map[string]string{"type": "boolean"}
+	case reflect.Int32:
+		return map[string]interface{}{"type": "integer", "minimum": math.MinInt32, "maximum": math.MaxInt32}
+	case reflect.Int64:
+		return map[string]string{"type": "integer"}
+	case reflect.Float64:
+		return map[string]string{"type": "number"}
+	case reflect.Struct:
+		return jsonSchemaRef(t)
+	}
+	panic("jsonschema: unsupported type " + t.String())
+}
+
+// jsonFieldName returns the key of a struct field in the JSON dump.
+func jsonFieldName(f reflect.StructField) string {
+	return strings.Split(f.Tag.Get("json"), ",")[0]
+}
+
+// addJSONSchemaDefs adds the object of t to defs and then those of the
+// structs it uses that are not defined yet.
+func addJSONSchemaDefs(defs *jsonSchemaProperties, t reflect.Type, defined map[reflect.Type]bool) {
+	defined[t] = true
+	fields := schemaFields(t)
+	obj := jsonSchemaObject{Type: "object", Required: []string{}}
+	for _, f := range fields {
+		name := jsonFieldName(f)
+		obj.Properties.names = append(obj.Properties.names, name)
+		obj.Properties.schemas = append(obj.Properties.schemas, jsonSchemaType(f.Type))
+		if !optionalElement(f) {
+			obj.Required = append(obj.Required, name)
+		}
+	}
+	defs.names = append(defs.names, schemaTypeName(t))
+	defs.schemas = append(defs.schemas, obj)
+	for _, f := range fields {
+		if nestedType(f.Type) && !defined[f.Type] {
+			addJSONSchemaDefs(defs, f.Type, defined)
+		}
+	}
+}
+
+// jsonSchema returns the JSON Schema of the JSON dump.
+func jsonSchema() ([]byte, error) {
+	var defs jsonSchemaProperties
+	addJSONSchemaDefs(&defs, reflect.TypeOf(score{}), map[reflect.Type]bool{})
+
+	var names []string
+	for t := dtxdb.SongType(0); !strings.Contains(t.String(), "("); t++ {
+		names = append(names, t.String())
+	}
+	defs.names = append(defs.names, schemaTypeName(songTypeType))
+	defs.schemas = append(defs.schemas, map[string]interface{}{
+		"anyOf": []interface{}{
+			map[string]interface{}{"enum": names},
+			map[string]string{"type": "string", "pattern": "^" + songTypePattern + "$"},
+		},
+	})
+
+	return json.MarshalIndent(struct {
+		Schema string               `json:"$schema"`
+		Title  string               `json:"title"`
+		Type   string               `json:"type"`
+		Items  interface{}          `json:"items"`
+		Defs   jsonSchemaProperties `json:"$defs"`
+	}{
+		Schema: "https://json-schema.org/draft/2020-12/schema",
+		Title:  "DTXMania songs.db dump",
+		Type:   "array",
+		Items:  jsonSchemaRef(reflect.TypeOf(score{})),
+		Defs:   defs,
+	}, "", "  ")
+}
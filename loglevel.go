@@ -0,0 +1,66 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"strconv"
+)
+
+// logLevel decides which of the logs of the commands are written to
+// stderr. Warnings and errors always are.
+type logLevel int
+
+const (
+	// levelWarning leaves out everything else, set with -quiet.
+	levelWarning logLevel = iota
+	// levelInfo adds what the command is doing, the version of the
+	// databases, its progress, the records it changes and the files
+	// written.
+	levelInfo
+	// levelDebug adds a line for every record, set with -verbose.
+	levelDebug
+)
+
+var currentLogLevel = levelInfo
+
+// logLevelFlag is a boolean flag setting currentLogLevel to its value when
+// given.
+type logLevelFlag logLevel
+
+func (logLevelFlag) IsBoolFlag() bool {
+	return true
+}
+
+func (logLevelFlag) String() string {
+	return "false"
+}
+
+func (f logLevelFlag) Set(v string) error {
+	on, err := strconv.ParseBool(v)
+	if on {
+		currentLogLevel = logLevel(f)
+	}
+	return err
+}
+
+// logLevelFlags registers -quiet and -verbose, also spelled -q and -v.
+func logLevelFlags(flags *flag.FlagSet) {
+	flags.Var(logLevelFlag(levelWarning), "quiet", "only log warnings and errors")
+	aliasFlag(flags, "q", "quiet")
+	flags.Var(logLevelFlag(levelDebug), "verbose", "also log the offset and path of every record read")
+	aliasFlag(flags, "v", "verbose")
+}
+
+// infof logs at levelInfo.
+func infof(format string, v ...interface{}) {
+	if currentLogLevel >= levelInfo {
+		log.Printf(format, v...)
+	}
+}
+
+// debugf logs at levelDebug.
+func debugf(format string, v ...interface{}) {
+	if currentLogLevel >= levelDebug {
+		log.Printf(format, v...)
+	}
+}
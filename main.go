@@ -2,278 +2,642 @@ package main
 
 import (
 	"bufio"
-	"encoding/binary"
-	"encoding/xml"
+	"errors"
+	"flag"
+	"fmt"
 	"io"
 	"log"
-	"math"
 	"os"
+	"strings"
+	"sync"
 	"time"
-)
-
-type eType int32
 
-const (
-	DTX eType = iota
-	GDA
-	G2D
-	BMS
-	BME
-	SMF
+	"github.com/SirChronus/dtxmania-dbdump/dtxdb"
+	"golang.org/x/text/encoding/htmlindex"
 )
 
-func (e eType) String() string {
-	return [...]string{"DTX", "GDA", "G2D", "BMS", "BME", "SMF"}[e]
+type score struct {
+	dtxdb.Score
+
+	// Pack, SortKey and Source are not stored in songs.db. Pack is filled
+	// in from the pack manifest given with -packs, SortKey is the
+	// transliterated title used by -sort and Source the database the
+	// record was read from when dump combines several.
+	Pack    string `xml:"pack,omitempty" json:"pack,omitempty"`
+	SortKey string `xml:"sort-key,omitempty" json:"sort-key,omitempty"`
+	Source  string `xml:"source,omitempty" json:"source,omitempty"`
 }
 
-func (e eType) MarshalXML(enc *xml.Encoder, start xml.StartElement) error {
-	return enc.EncodeElement(e.String(), start)
-}
+var dbReader *dtxdb.Reader
+var file io.ReadCloser
+var outFile *os.File
 
-type dateAsString string
+func logFatalIfError(err error) {
+	if err != nil {
+		if file != nil {
+			file.Close()
+		}
 
-type fileInformation struct {
-	AbsoluteFilePath   string       `xml:"absolute-file-path"`
-	AbsoluteFolderPath string       `xml:"absolute-folder-path"`
-	LastModified       dateAsString `xml:"last-modified"`
-	FileSize           int64        `xml:"file-size"`
+		if outFile != nil {
+			outFile.Close()
+		}
+		log.Fatalln(err)
+	}
 }
 
-type songIniInformation struct {
-	LastModified dateAsString `xml:"last-modified"`
-	FileSize     int64        `xml:"file-size"`
-}
+var (
+	dbEncoding     = "utf-8"
+	dbVersion      string
+	fallbackLayout string
+	maxStringLen   int
+	strictRead     bool
+)
 
-type dgbInt32 struct {
-	Drums  int32 `xml:"drums"`
-	Guitar int32 `xml:"guitar"`
-	Bass   int32 `xml:"bass"`
+// readerFlags registers the flags controlling how openSongsDB decodes the
+// database.
+func readerFlags(flags *flag.FlagSet) {
+	flags.StringVar(&dbEncoding, "encoding", dbEncoding, "`name` of the encoding of the strings in the database, e.g. shift_jis")
+	flags.StringVar(&dbVersion, "db-version", "", "fail unless the database has the version `string`, e.g. SongsDB5")
+	flags.StringVar(&fallbackLayout, "fallback-layout", "", "read databases of a version without a known layout as version `string`, e.g. SongsDB5")
+	flags.IntVar(&maxStringLen, "max-string-len", 0, "fail on strings longer than `n` bytes, 0 is no limit")
+	flags.BoolVar(&strictRead, "strict", false, "fail on values DTXMania never writes: strings that are no UTF-8, booleans other than 0 and 1, unknown song types")
 }
 
-type dgbDouble struct {
-	Drums  float64 `xml:"drums"`
-	Guitar float64 `xml:"guitar"`
-	Bass   float64 `xml:"bass"`
+// inputDBFlag registers the -i flag of the commands reading a songs.db,
+// also spelled -input, defaulting to songsDBPath.
+func inputDBFlag(flags *flag.FlagSet) *string {
+	input := flags.String("i", songsDBPath, "read the database from `file`, http(s) URL or - for the standard input")
+	aliasFlag(flags, "input", "i")
+	return input
 }
 
-type dgbBoolean struct {
-	Drums  bool `xml:"drums"`
-	Guitar bool `xml:"guitar"`
-	Bass   bool `xml:"bass"`
+// newFlagSet returns the flags of the command name, which exits on errors
+// and has the -quiet and -verbose of every command.
+func newFlagSet(name string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ExitOnError)
+	logLevelFlags(flags)
+	return flags
 }
 
-type performanceHistory struct {
-	First  string `xml:"first"`
-	Second string `xml:"second"`
-	Third  string `xml:"third"`
-	Fourth string `xml:"fourth"`
-	Fifth  string `xml:"fifth"`
+// aliasFlag registers alias as another name of the flag name of flags.
+func aliasFlag(flags *flag.FlagSet, alias, name string) {
+	flags.Var(flags.Lookup(name).Value, alias, "same as -"+name)
 }
 
-type songInformation struct {
-	Title              string             `xml:"title"`
-	Artist             string             `xml:"artist"`
-	Comment            string             `xml:"comment"`
-	Genre              string             `xml:"genre"`
-	PreImage           string             `xml:"pre-image"`
-	PreMovie           string             `xml:"pre-movie"`
-	PreSound           string             `xml:"pre-sound"`
-	Background         string             `xml:"background"`
-	Level              dgbInt32           `xml:"level"`
-	LevelDec           dgbInt32           `xml:"level-dec"`
-	BestRank           dgbInt32           `xml:"best-rank"`
-	HighSkill          dgbDouble          `xml:"high-skill"`
-	FullCombo          dgbBoolean         `xml:"full-combo"`
-	NbPerformance      dgbInt32           `xml:"nb-performance"`
-	PerformanceHistory performanceHistory `xml:"performance-history"`
-	HiddenLevel        bool               `xml:"hidden-level"`
-	Classic            dgbBoolean         `xml:"classic"`
-	ScoreExists        dgbBoolean         `xml:"score-exists"`
-	SongType           eType              `xml:"song-type"`
-	Bpm                float64            `xml:"bpm"`
-	Duration           int32              `xml:"duration"`
+// openDBFile opens the database at path. path may also be an http or https
+// URL, or - for the standard input.
+func openDBFile(path string) io.ReadCloser {
+	if path == "-" {
+		return io.NopCloser(os.Stdin)
+	}
+	if isRemoteDB(path) {
+		return openRemoteDB(path)
+	}
+	if mmapInput {
+		if m := openMappedOrLog(path); m != nil {
+			return m
+		}
+	}
+	f, err := os.Open(path)
+	logFatalIfError(err)
+	return f
 }
 
-type score struct {
-	XMLName            xml.Name           `xml:"song"`
-	FileInformation    fileInformation    `xml:"file-info"`
-	SongIniInformation songIniInformation `xml:"song-ini-info"`
-	SongInformation    songInformation    `xml:"song-info"`
+// readerOptions returns the options of the flags registered by readerFlags
+// and of the hooks the command enabled.
+func readerOptions() []dtxdb.Option {
+	var opts []dtxdb.Option
+	if !strings.EqualFold(dbEncoding, "utf-8") {
+		e, err := htmlindex.Get(dbEncoding)
+		if err != nil {
+			log.Fatalf("unknown encoding %q\n", dbEncoding)
+		}
+		opts = append(opts, dtxdb.WithEncoding(e))
+	}
+	if dbVersion != "" {
+		opts = append(opts, dtxdb.WithVersion(dbVersion))
+	}
+	if fallbackLayout != "" {
+		opts = append(opts, dtxdb.WithFallbackLayout(fallbackLayout))
+	}
+	if maxStringLen > 0 {
+		opts = append(opts, dtxdb.WithMaxStringLen(maxStringLen))
+	}
+	if strictRead {
+		opts = append(opts, dtxdb.WithStrict(true))
+	}
+	if readTiming != nil {
+		opts = append(opts, dtxdb.WithTiming(readTiming))
+	}
+	if applyOverrides {
+		opts = append(opts, dtxdb.WithHook(overrideHook(nil)))
+	}
+	if anonymizeRecords {
+		opts = append(opts, dtxdb.WithHook(anonymizeScore))
+	}
+	for _, h := range readHooks {
+		opts = append(opts, dtxdb.WithHook(h))
+	}
+	return opts
 }
 
-var fileReader *bufio.Reader
-var file *os.File
-var outFile *os.File
-
-const tickFactor = 10000000
+// openSongsDB opens the database at path for readNextScore and returns its
+// version string.
+func openSongsDB(path string) string {
+	file = openDBFile(path)
 
-var isEOF = false
-
-func logFatalIfError(err error) {
-	if err != nil {
-		if err == io.EOF {
-			isEOF = true
-			return
-		}
+	var err error
+	dbReader, err = dtxdb.NewReader(file, readerOptions()...)
+	logFatalIfError(err)
+	warnFallbackLayout(dbReader)
 
-		if file != nil {
-			file.Close()
-		}
+	return dbReader.Version()
+}
 
-		if outFile != nil {
-			outFile.Close()
-		}
-		log.Fatalln(err)
+// warnFallbackLayout tells when r reads a database of an unknown version
+// with the layout of -fallback-layout.
+func warnFallbackLayout(r *dtxdb.Reader) {
+	if l := r.Layout(); l.Version != r.Version() {
+		log.Printf("warning: no layout is known for version %q, reading its records as %s, they may be garbled\n", r.Version(), l.Version)
 	}
 }
 
-func readStringFromDBOrFail() string {
-	length, err := binary.ReadUvarint(fileReader)
+// nextScore reads the next record of r into s. It returns false once the
+// end of the database has been reached.
+func nextScore(r *dtxdb.Reader, s *score) bool {
+	offset := r.Offset()
+	next, err := r.Next()
+	if err == io.EOF {
+		return false
+	}
+	var truncated *dtxdb.ErrTruncatedRecord
+	if errors.As(err, &truncated) {
+		if writesDB && !dropTruncated {
+			log.Fatalf("%v, not writing a database without it unless -drop-truncated is given\n", err)
+		}
+		// The records before are fine, DTXMania was probably closed while
+		// writing the database.
+		log.Printf("warning: %v, ignoring the incomplete record\n", err)
+		return false
+	}
 	logFatalIfError(err)
+	debugf("record at offset %d: %s\n", offset, next.FileInformation.AbsoluteFilePath)
 
-	stringAsBytes := make([]byte, length)
-	_, err = io.ReadFull(fileReader, stringAsBytes)
-	logFatalIfError(err)
+	s.Score = *next
+	return true
+}
 
-	return string(stringAsBytes)
+// readNextScore reads the next record of the database opened with
+// openSongsDB into s, like nextScore.
+func readNextScore(s *score) bool {
+	return nextScore(dbReader, s)
 }
 
-func readSignedInt64FromDBOrFail() int64 {
-	valueAsBytes := make([]byte, 8)
-	_, err := io.ReadFull(fileReader, valueAsBytes)
+// readScores reads every record of the database read from r.
+func readScores(r io.Reader) (string, []score) {
+	dr, err := dtxdb.NewReader(r, readerOptions()...)
 	logFatalIfError(err)
+	warnFallbackLayout(dr)
 
-	return int64(binary.LittleEndian.Uint64(valueAsBytes))
-}
+	var scores []score
+	for {
+		var s score
+		if !nextScore(dr, &s) {
+			break
+		}
+		scores = append(scores, s)
+	}
 
-func readSignedInt32FromDBOrFail() int32 {
-	valueAsBytes := make([]byte, 4)
-	_, err := io.ReadFull(fileReader, valueAsBytes)
-	logFatalIfError(err)
+	return dr.Version(), scores
+}
 
-	return int32(binary.LittleEndian.Uint32(valueAsBytes))
+// readAllScores reads every record of the database at path.
+func readAllScores(path string) (string, []score) {
+	f := openDBFile(path)
+	defer f.Close()
+	return readScores(f)
 }
 
-func readDoubleFromDBOrFail() float64 {
-	valueAsBytes := make([]byte, 8)
-	_, err := io.ReadFull(fileReader, valueAsBytes)
-	logFatalIfError(err)
+// database is the version and records of a database.
+type database struct {
+	version string
+	scores  []score
+}
 
-	return math.Float64frombits(binary.LittleEndian.Uint64(valueAsBytes))
+// readDatabases reads the databases at paths in parallel, in the order of
+// paths. Every reader has hooks of its own, so none shares state with
+// another.
+func readDatabases(paths ...string) []database {
+	dbs := make([]database, len(paths))
+	var wg sync.WaitGroup
+	for i, path := range paths {
+		wg.Add(1)
+		go func(db *database, path string) {
+			defer wg.Done()
+			db.version, db.scores = readAllScores(path)
+		}(&dbs[i], path)
+	}
+	wg.Wait()
+	return dbs
 }
 
-func readBoolFromDBOrFail() bool {
-	valueAsBytes := make([]byte, 1)
-	_, err := io.ReadFull(fileReader, valueAsBytes)
+func runDump(args []string) {
+	flags := newFlagSet("dump")
+	packsPath := packFlag(flags)
+	sortBy := flags.String("sort", "", "sort songs by `key`: "+strings.Join(sortedKeys(scoreSorters), ", ")+" (default database order)")
+	withSortKeys := flags.Bool("sort-keys", false, "include the sort key of every title in the dump")
+	translitName := flags.String("transliterator", "romaji", "`name` of the transliterator generating sort keys: romaji or none")
+	input := inputDBFlag(flags)
+	headerOnly := flags.Bool("header", false, "only print the version of the database")
+	countOnly := flags.Bool("count", false, "only print the number of records")
+	skip := flags.Int("skip", 0, "skip the first `n` records")
+	limit := flags.Int("limit", -1, "stop after `n` records, without reading the rest of the database")
+	sample := flags.Int("sample", 0, "only write a random sample of `n` records, to preview a dump")
+	sampleEven := flags.Bool("sample-even", false, "sample evenly spaced records instead of random ones")
+	var filters filterList
+	flags.Var(&filters, "filter", filterUsage)
+	formatName := flags.String("format", "", "write the dump as `format`: "+formatNames()+" (default the extension of -o, or "+defaultDumpFormat+")")
+	templateName := flags.String("template", "", "render every record through the text/template in `file` instead of a -format")
+	output := flags.String("o", defaultDumpOutput, "write the dump to `file` (default dump.xml, or the extension of -format), - for stdout")
+	aliasFlag(flags, "output", "o")
+	encodingName := flags.String("output-encoding", "utf-8", "write text dumps in `encoding`: "+outputEncodingNames())
+	compressName := flags.String("compress", "", "compress the dump with `method`: gzip or zstd (default none, or as named by -o)")
+	shardBy := flags.String("shard-by", "", "write a file per `key`, "+shardKeyNames()+", below a folder named like -o")
+	var maxSize byteSize
+	flags.Var(&maxSize, "max-output-size", "split the dump into numbered parts below `size`, e.g. 50MB")
+	flags.Var(&csvColumns, "columns", "comma separated `fields` written by the csv and markdown formats, e.g. title,artist,level.drums")
+	flags.Var(xmlIndentFlag{}, "xml-indent", "indent the xml dump by `n` spaces per level, or tab (default 2 before songs and 4 per level below)")
+	flags.BoolVar(&compactOutput, "compact", false, "write the xml and json dumps without any white space")
+	flags.StringVar(&xmlStylesheet, "xml-stylesheet", "", "refer the xml dump to the XSLT stylesheet at `href`, for browsers to render it")
+	bundledStylesheet := flags.Bool("bundled-stylesheet", false, "also write the bundled stylesheet, a table of the songs, to the -xml-stylesheet href next to the dump")
+	flags.BoolVar(&flattenFields, "flatten", false, "write flat level_drums style keys instead of nested objects in the json formats, and name the csv columns alike")
+	flags.StringVar(&htmlPreviews, "html-previews", "", "show the chart previews of `folder`, written by preview, in the html report")
+	flags.StringVar(&sqlTablePrefix, "table-prefix", sqlTablePrefix, "`prefix` of the table name written by -format mysql, letters, digits and _ only")
+	invalidChars := flags.String("invalid-chars", "", "`policy` for the characters XML cannot hold in -format xml: "+invalidCharPolicyNames()+" (default replace)")
+	each := flags.Bool("each", false, "write a dump per database given, named like -o in the folder of the database, instead of one dump of them all")
+	incremental := flags.Bool("incremental", false, "only write the records changed since the previous incremental dump to the same file, and the paths of the removed ones to <file>.removed")
+	progressEvery := flags.Duration("progress", 10*time.Second, "log the records and MiB read every `interval`, 0 for never")
+	withProfile := flags.Bool("profile", false, "report the time spent reading, decoding and encoding and the slowest records")
+	mmapFlag(flags)
+	readerFlags(flags)
+	overridesFlag(flags)
+	anonymizeFlag(flags)
+	flags.Parse(args)
+	packs := loadPackFlag(*packsPath)
+	translit, err := lookupTransliterator(*translitName)
+	logFatalIfError(err)
+	// Without -format the extension of -o names the format.
+	if *formatName == "" {
+		*formatName = formatOfOutput(*output)
+	}
+	if *formatName == "" {
+		*formatName = defaultDumpFormat
+	}
+	format, err := lookupDumpFormat(*formatName)
+	logFatalIfError(err)
+	if *templateName != "" {
+		format, err = templateFormat(*templateName)
+		logFatalIfError(err)
+	}
+	if xmlStylesheet != "" && format.ext != "xml" {
+		log.Fatalln("-xml-stylesheet only applies to -format xml")
+	}
+	if *bundledStylesheet && xmlStylesheet == "" {
+		log.Fatalln("-bundled-stylesheet needs the -xml-stylesheet href to write it to")
+	}
+	logFatalIfError(checkTablePrefix(sqlTablePrefix))
+	format, err = encodedDumpFormat(format, *encodingName)
 	logFatalIfError(err)
+	compress, err := lookupCompression(*compressName, *output)
+	logFatalIfError(err)
+	if compress != nil && maxSize > 0 {
+		log.Fatalln("-max-output-size cannot split compressed dumps, the size of a part is only known once it is compressed")
+	}
+	var sanitize func(s string) (string, bool)
+	if *invalidChars != "" {
+		if format.ext != "xml" {
+			log.Fatalln("-invalid-chars only applies to -format xml")
+		}
+		var ok bool
+		if sanitize, ok = invalidCharPolicies[*invalidChars]; !ok {
+			log.Fatalf("unknown -invalid-chars policy %q, use one of %s\n", *invalidChars, invalidCharPolicyNames())
+		}
+	}
+	var profile *dumpProfile
+	if *withProfile {
+		profile = newDumpProfile()
+	}
+	toStdout := *output == "-"
+	if toStdout && (maxSize > 0 || *incremental || *shardBy != "") {
+		log.Fatalln("-o - cannot be combined with -max-output-size, -shard-by or -incremental, they need files")
+	}
+	if *shardBy != "" && (maxSize > 0 || *incremental) {
+		log.Fatalln("-shard-by cannot be combined with -max-output-size or -incremental")
+	}
+	inputs := expandInputs([]string{*input})
+	if flags.NArg() > 0 {
+		inputs = expandInputs(flags.Args())
+	}
+	var incr *incrementalDump
+	if *incremental {
+		if *skip > 0 || *limit >= 0 || *sample > 0 {
+			log.Fatalln("-incremental needs every record, it cannot be combined with -skip, -limit or -sample")
+		}
+		if len(inputs) > 1 || *each {
+			log.Fatalln("-incremental keeps the state of a single database, it cannot be combined with several inputs or -each")
+		}
+	}
+	if *each {
+		if toStdout {
+			log.Fatalln("-each writes a file per input, it cannot be combined with -o -")
+		}
+		for _, in := range inputs {
+			if in == "-" || isRemoteDB(in) {
+				log.Fatalf("-each writes the dump next to the database, %s has no folder\n", in)
+			}
+		}
+	}
 
-	return valueAsBytes[0] != 0
-}
+	if *headerOnly {
+		for _, in := range inputs {
+			versionString := openSongsDB(in)
+			file.Close()
+			if len(inputs) > 1 {
+				fmt.Printf("%s: %s\n", in, versionString)
+			} else {
+				fmt.Println(versionString)
+			}
+		}
+		return
+	}
+	if *countOnly {
+		// Records have no fixed size, so counting them means reading them.
+		count := 0
+		for _, in := range inputs {
+			openSongsDB(in)
+			for s := (score{}); readNextScore(&s); count++ {
+			}
+			file.Close()
+		}
+		fmt.Println(count)
+		return
+	}
 
-func readDateFromDBOrFail() dateAsString {
-	dateTime := readSignedInt64FromDBOrFail()
-	baseTime := time.Date(1, 1, 1, 0, 0, 0, 0, time.UTC).Unix()
-	// Convert from C# tick time to proper UTC timestamp
-	t := time.Unix(dateTime/tickFactor+baseTime, dateTime%tickFactor)
+	if *output == "" {
+		*output = "dump." + format.ext
+		if compress != nil {
+			*output += compress.ext
+		}
+	}
+	if *incremental {
+		incr = loadIncrementalDump(*output)
+	}
+	// dumpInputs writes the records of inputs, one after the other, to a
+	// dump at output.
+	dumpInputs := func(inputs []string, output string) {
+		var sampler *recordSampler
+		if *sample > 0 {
+			sampler = newRecordSampler(*sample, *sampleEven)
+		}
 
-	return dateAsString(t.Format(time.RFC3339))
-}
+		chain := &inputChain{paths: inputs}
+		chain.open()
+		defer chain.close()
+		progress := newProgressLog(*progressEvery, chain.size())
 
-func readFileInformation(s *score) {
-	s.FileInformation.AbsoluteFilePath = readStringFromDBOrFail()
-	s.FileInformation.AbsoluteFolderPath = readStringFromDBOrFail()
-	s.FileInformation.LastModified = readDateFromDBOrFail()
-	s.FileInformation.FileSize = readSignedInt64FromDBOrFail()
-}
+		if *bundledStylesheet {
+			logFatalIfError(writeBundledStylesheet(output))
+		}
+		var out recordWriter
+		var outFileWriter *bufio.Writer
+		var compressWriter io.WriteCloser
+		if *shardBy != "" {
+			dir := output
+			if compress != nil {
+				dir = strings.TrimSuffix(dir, compress.ext)
+			}
+			out, err = newShardWriter(format, compress, strings.TrimSuffix(dir, "."+format.ext), *shardBy)
+			logFatalIfError(err)
+		} else if maxSize > 0 {
+			out, err = newSplitWriter(format, output, int64(maxSize))
+			logFatalIfError(err)
+		} else {
+			if toStdout {
+				outFile = os.Stdout
+			} else {
+				outFile, err = os.Create(output)
+				logFatalIfError(err)
+				defer outFile.Close()
+			}
+			if compress != nil {
+				compressWriter = compress.newWriter(outFile)
+				outFileWriter = bufio.NewWriter(compressWriter)
+			} else {
+				outFileWriter = bufio.NewWriter(outFile)
+			}
+			out, err = format.newWriter(outFileWriter)
+			logFatalIfError(err)
+		}
 
-func readSongIniInformation(s *score) {
-	s.SongIniInformation.LastModified = readDateFromDBOrFail()
-	s.SongIniInformation.FileSize = readSignedInt64FromDBOrFail()
-}
+		// Sorting needs every record in memory and sampling the records it
+		// kept, otherwise they are written as soon as they are read.
+		var sorted []score
+		sanitized := 0
+		for n := 0; *limit < 0 || n < *skip+*limit; n++ {
+			var s score
+			start := time.Now()
+			offset, ok := chain.read(&s)
+			if !ok {
+				break
+			}
+			progress.update(n+1, chain.offset())
+			if n < *skip {
+				continue
+			}
+			s.Pack = packs.packOf(&s)
+			if !filters.match(&s) {
+				continue
+			}
+			if sanitize != nil && sanitizeScore(&s, sanitize) {
+				sanitized++
+			}
+			if incr != nil && !incr.add(&s) {
+				debugf("%s unchanged since the previous dump\n", s.FileInformation.AbsoluteFilePath)
+				continue
+			}
+			if *withSortKeys || *sortBy != "" {
+				s.SortKey = sortKey(translit, s.SongInformation.Title)
+			}
+
+			if sampler != nil {
+				sampler.add(&s)
+				continue
+			}
+			if *sortBy != "" {
+				sorted = append(sorted, s)
+				if profile != nil {
+					profile.record(recordTiming{s.FileInformation.AbsoluteFilePath, offset, dbReader.Offset() - offset, time.Since(start)})
+				}
+				continue
+			}
+			encodeStart := time.Now()
+			logFatalIfError(out.write(&s))
+			if profile != nil {
+				profile.encode += time.Since(encodeStart)
+				profile.record(recordTiming{s.FileInformation.AbsoluteFilePath, offset, dbReader.Offset() - offset, time.Since(start)})
+			}
+		}
 
-func readDGBInt32(s *dgbInt32) {
-	s.Drums = readSignedInt32FromDBOrFail()
-	s.Guitar = readSignedInt32FromDBOrFail()
-	s.Bass = readSignedInt32FromDBOrFail()
-}
+		if sampler != nil {
+			sorted = sampler.records()
+			infof("sampled %d of %d records\n", len(sorted), sampler.seen)
+		}
+		if *sortBy != "" || sampler != nil {
+			if *sortBy != "" {
+				logFatalIfError(sortScores(translit, sorted, *sortBy))
+			}
+			encodeStart := time.Now()
+			for i := range sorted {
+				if !*withSortKeys {
+					sorted[i].SortKey = ""
+				}
+				logFatalIfError(out.write(&sorted[i]))
+			}
+			if profile != nil {
+				profile.encode += time.Since(encodeStart)
+			}
+		}
+
+		if sanitized > 0 {
+			infof("%d records held characters XML cannot hold, applied -invalid-chars %s\n", sanitized, *invalidChars)
+		}
+		logFatalIfError(out.close())
+		if outFileWriter != nil {
+			logFatalIfError(outFileWriter.Flush())
+		}
+		if compressWriter != nil {
+			logFatalIfError(compressWriter.Close())
+		}
+		if incr != nil {
+			incr.finish(output)
+		}
+	}
+	if *each {
+		for _, in := range inputs {
+			dumpInputs([]string{in}, eachOutput(in, *output))
+		}
+	} else {
+		dumpInputs(inputs, *output)
+	}
 
-func readDGBDouble(s *dgbDouble) {
-	s.Drums = readDoubleFromDBOrFail()
-	s.Guitar = readDoubleFromDBOrFail()
-	s.Bass = readDoubleFromDBOrFail()
+	infof("done\n")
+	if profile != nil {
+		logFatalIfError(profile.report(os.Stderr))
+	}
 }
 
-func readDGBBoolean(s *dgbBoolean) {
-	s.Drums = readBoolFromDBOrFail()
-	s.Guitar = readBoolFromDBOrFail()
-	s.Bass = readBoolFromDBOrFail()
+// stringList is a flag that can be given several times.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
 }
 
-func readPerfomanceHistory(s *performanceHistory) {
-	s.First = readStringFromDBOrFail()
-	s.Second = readStringFromDBOrFail()
-	s.Third = readStringFromDBOrFail()
-	s.Fourth = readStringFromDBOrFail()
-	s.Fifth = readStringFromDBOrFail()
+func (l *stringList) Set(v string) error {
+	*l = append(*l, v)
+	return nil
 }
 
-func readSongInformation(s *score) {
-	s.SongInformation.Title = readStringFromDBOrFail()
-	s.SongInformation.Artist = readStringFromDBOrFail()
-	s.SongInformation.Comment = readStringFromDBOrFail()
-	s.SongInformation.Genre = readStringFromDBOrFail()
-	s.SongInformation.PreImage = readStringFromDBOrFail()
-	s.SongInformation.PreMovie = readStringFromDBOrFail()
-	s.SongInformation.PreSound = readStringFromDBOrFail()
-	s.SongInformation.Background = readStringFromDBOrFail()
-	readDGBInt32(&s.SongInformation.Level)
-	readDGBInt32(&s.SongInformation.LevelDec)
-	readDGBInt32(&s.SongInformation.BestRank)
-	readDGBDouble(&s.SongInformation.HighSkill)
-	readDGBBoolean(&s.SongInformation.FullCombo)
-	readDGBInt32(&s.SongInformation.NbPerformance)
-	readPerfomanceHistory(&s.SongInformation.PerformanceHistory)
-	s.SongInformation.HiddenLevel = readBoolFromDBOrFail()
-	readDGBBoolean(&s.SongInformation.Classic)
-	readDGBBoolean(&s.SongInformation.ScoreExists)
-	s.SongInformation.SongType = eType(readSignedInt32FromDBOrFail())
-	s.SongInformation.Bpm = readDoubleFromDBOrFail()
-	s.SongInformation.Duration = readSignedInt32FromDBOrFail()
+type command struct {
+	name  string
+	usage string
+	run   func(args []string)
+}
 
+var commands []command
+
+// The table is filled in by init since info lists it.
+func init() {
+	commands = []command{
+		{"add", "append the charts of new song folders to songs.db", runAdd},
+		{"anonymize", "strip comments, player names and user folders from songs.db to share it", runAnonymize},
+		{"build", "build a songs.db from an xml or json dump, same as encode", runEncode},
+		{"bundle", "zip the folders of selected songs into a shareable pack", runBundle},
+		{"calendar", "export the play history as an iCalendar file of play sessions", runCalendar},
+		{"carry", "carry scores forward to charts DTXMania re-enumerated", runCarry},
+		{"changelog", "list what changed in the library since a snapshot", runChangelog},
+		{"check", "report records with values DTXMania never writes", runCheck},
+		{"dedupe", "drop the records listing a chart twice from songs.db", runDedupe},
+		{"diff", "list the records added, removed and changed between two databases", runDiff},
+		{"dump", "dump songs.db to dump.xml (default)", runDump},
+		{"edit", "set fields of the songs matching filters and write a new songs.db", runEdit},
+		{"encode", "write an xml or json dump, edited or not, back into a songs.db", runEncode},
+		{"history", "list the snapshots taken with snapshot", runHistory},
+		{"import-scores", "copy the play data of another songs.db, like playdata import -from", runImportScores},
+		{"index", "export a prefix index of the titles for search as you type", runIndex},
+		{"info", "print what this build supports", runInfo},
+		{"install", "unpack a bundle into the song folder and register its charts", runInstall},
+		{"jackets", "find songs with near-identical jackets but different metadata", runJackets},
+		{"merge", "merge the changes two copies of songs.db made to a common base", runMerge},
+		{"overrides", "write the corrections of the " + overrideFileName + " files back to songs.db", runOverrides},
+		{"playdata", "export or import the play data of all songs", runPlayData},
+		{"preview", "render the note density of the charts as SVG or PNG strips", runPreview},
+		{"prune", "remove the songs matching filters or missing their chart from songs.db", runPrune},
+		{"progress", "chart the high skills of a song or overall over the snapshots", runProgress},
+		{"redis", "export the songs to Redis for fast lookups", runRedis},
+		{"reconcile", "find moved chart files and rewrite their paths", runReconcile},
+		{"repro", "reproduce and minimise a parser failure on a corrupt database", runRepro},
+		{"reset-scores", "clear the play data of all or some instruments for a fresh start", runResetScores},
+		{"rewrite-paths", "rewrite the paths of songs moved to another folder", runRewritePaths},
+		{"scan", "enumerate the song folder into a new songs.db like DTXMania does", runScan},
+		{"schema", "print the schema of the xml, json, protobuf or avro dump format", runSchema},
+		{"serve", "serve the library and a song request queue over HTTP", runServe},
+		{"setup", "locate the DTXMania install, choose the dump format and run a first dump", runSetup},
+		{"skill", "split the skill into HOT and OTHER songs like GITADORA", runSkill},
+		{"sort", "reorder the records of songs.db by title, artist, level or folder", runSort},
+		{"snapshot", "archive a compressed dump of songs.db in .dbdump/history", runSnapshot},
+		{"stats", "print library statistics grouped by artist, charter, year or pack", runStats},
+		{"validate", "check a dump.xml against the XML Schema of the dump", runValidate},
+		{"verify", "check that every record of songs.db encodes back to the same bytes", runVerify},
+	}
 }
 
-func readScore(s *score) {
-	readFileInformation(s)
-	readSongIniInformation(s)
-	readSongInformation(s)
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: %s [command] [flags]\n\n", os.Args[0])
+	fmt.Fprintln(os.Stderr, "Without a command songs.db is dumped to dump.xml, or as set up with setup,")
+	fmt.Fprintln(os.Stderr, "and flags given without a command are those of dump.")
+	fmt.Fprintln(os.Stderr, "\nCommands:")
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %-13s %s\n", c.name, c.usage)
+	}
+	fmt.Fprintf(os.Stderr, "\nRun %s <command> -h for the flags of a command.\n", os.Args[0])
 }
 
 func main() {
-	file, err := os.Open("songs.db")
-	logFatalIfError(err)
-	defer file.Close()
-	fileReader = bufio.NewReader(file)
-
-	outFile, err = os.Create("dump.xml")
-	logFatalIfError(err)
-	defer outFile.Close()
-	outFileWriter := bufio.NewWriter(outFile)
-	_, err = outFileWriter.WriteString("<songs>\n")
-	logFatalIfError(err)
-	enc := xml.NewEncoder(outFileWriter)
-	enc.Indent("  ", "    ")
-
-	versionString := readStringFromDBOrFail()
-
-	log.Printf("SongDB version: %s\n", versionString)
-	for !isEOF {
-		var s score
-		readScore(&s)
-		logFatalIfError(enc.Encode(s))
+	// setup replaces the config file, a broken one must not stop it.
+	if len(os.Args) < 2 || os.Args[1] != "setup" {
+		logFatalIfError(loadConfig())
+	}
+	if len(os.Args) < 2 {
+		runDump(nil)
+		return
+	}
+	switch arg := os.Args[1]; {
+	case arg == "-h" || arg == "-help" || arg == "--help" || arg == "help":
+		usage()
+		return
+	case strings.HasPrefix(arg, "-"):
+		runDump(os.Args[1:])
+		return
 	}
 
-	_, err = outFileWriter.WriteString("\n</songs>")
-	logFatalIfError(outFileWriter.Flush())
+	for _, c := range commands {
+		if c.name == os.Args[1] {
+			c.run(os.Args[2:])
+			return
+		}
+	}
 
-	log.Println("done")
+	usage()
+	os.Exit(2)
 }
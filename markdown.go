@@ -0,0 +1,69 @@
+package main
+
+import (
+	"io"
+	"strings"
+)
+
+// markdownColumns are written by the markdown format unless -columns is
+// given, every field would make a table too wide to read.
+var markdownColumns = []string{"title", "artist", "genre", "level.drums", "level.guitar", "level.bass"}
+
+// markdownWriter writes a GitHub flavored Markdown table with a row per
+// record.
+type markdownWriter struct {
+	w       io.Writer
+	columns []int
+}
+
+var markdownEscaper = strings.NewReplacer("|", `\|`, "\r\n", "<br>", "\n", "<br>", "\r", "<br>")
+
+func newMarkdownWriter(w io.Writer) (recordWriter, error) {
+	header := []string(csvColumns)
+	if len(header) == 0 {
+		header = markdownColumns
+	}
+
+	var b strings.Builder
+	writeMarkdownRow(&b, header)
+	b.WriteString("|")
+	for range header {
+		b.WriteString(" --- |")
+	}
+	b.WriteString("\n")
+	if _, err := io.WriteString(w, b.String()); err != nil {
+		return nil, err
+	}
+	return &markdownWriter{w, columnIndices(header)}, nil
+}
+
+func writeMarkdownRow(b *strings.Builder, cells []string) {
+	b.WriteString("|")
+	for _, c := range cells {
+		b.WriteString(" ")
+		b.WriteString(markdownEscaper.Replace(c))
+		b.WriteString(" |")
+	}
+	b.WriteString("\n")
+}
+
+func (m *markdownWriter) write(s *score) error {
+	fields := scoreFields(s)
+	cells := make([]string, len(m.columns))
+	for i, column := range m.columns {
+		cells[i] = fields[column].String()
+	}
+	var b strings.Builder
+	writeMarkdownRow(&b, cells)
+	_, err := io.WriteString(m.w, b.String())
+	return err
+}
+
+// buffered is always 0, rows are written as a whole.
+func (m *markdownWriter) buffered() int {
+	return 0
+}
+
+func (m *markdownWriter) close() error {
+	return nil
+}
@@ -0,0 +1,313 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Merge policies decide which value a field gets when both sides changed
+// it differently.
+const (
+	policyMine   = "mine"
+	policyTheirs = "theirs"
+	policyBest   = "best"
+	policyNewest = "newest"
+	policyFail   = "fail"
+)
+
+var mergePolicyNames = []string{policyMine, policyTheirs, policyBest, policyNewest, policyFail}
+
+// mergePolicies maps field names, or the start of them like "high-skill",
+// to a policy.
+type mergePolicies struct {
+	byField map[string]string
+	def     string
+}
+
+func (p *mergePolicies) String() string {
+	var rules []string
+	for field, policy := range p.byField {
+		rules = append(rules, field+"="+policy)
+	}
+	sort.Strings(rules)
+	return strings.Join(rules, ",")
+}
+
+func (p *mergePolicies) Set(v string) error {
+	field, policy, ok := strings.Cut(v, "=")
+	if !ok {
+		return fmt.Errorf("policy %q is not field=policy", v)
+	}
+	if err := checkMergePolicy(policy); err != nil {
+		return err
+	}
+	if _, ok := lookupField(&score{}, field); !ok && !isFieldGroup(field) {
+		return fmt.Errorf("unknown field %q", field)
+	}
+	p.byField[field] = policy
+	return nil
+}
+
+func checkMergePolicy(policy string) error {
+	for _, name := range mergePolicyNames {
+		if policy == name {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown policy %q, use one of %s", policy, strings.Join(mergePolicyNames, ", "))
+}
+
+// isFieldGroup reports whether prefix names a group of fields like
+// "high-skill" or "file-info".
+func isFieldGroup(prefix string) bool {
+	for _, name := range fieldNames() {
+		if strings.HasPrefix(name, prefix+".") {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *mergePolicies) policyFor(field string) string {
+	for name := field; name != ""; {
+		if policy, ok := p.byField[name]; ok {
+			return policy
+		}
+		i := strings.LastIndex(name, ".")
+		if i < 0 {
+			break
+		}
+		name = name[:i]
+	}
+	return p.def
+}
+
+// bestValue picks the better play result of two conflicting values, the
+// way playdata merge does. ok is false for fields that are not results.
+func bestValue(field string, mine, theirs *score, m, t reflect.Value) (reflect.Value, bool) {
+	group := field
+	if i := strings.Index(field, "."); i >= 0 {
+		group = field[:i]
+	}
+
+	switch group {
+	case "best-rank":
+		if t.Int() < m.Int() {
+			return t, true
+		}
+		return m, true
+	case "high-skill":
+		if t.Float() > m.Float() {
+			return t, true
+		}
+		return m, true
+	case "nb-performance":
+		if t.Int() > m.Int() {
+			return t, true
+		}
+		return m, true
+	case "full-combo", "score-exists":
+		if t.Bool() {
+			return t, true
+		}
+		return m, true
+	case "performance-history":
+		if totalPlays(theirs) > totalPlays(mine) {
+			return t, true
+		}
+		return m, true
+	}
+	return reflect.Value{}, false
+}
+
+func totalPlays(s *score) int32 {
+	n := &s.SongInformation.NbPerformance
+	return n.Drums + n.Guitar + n.Bass
+}
+
+// mergeConflict describes a conflict and how it was resolved.
+type mergeConflict struct {
+	path, field         string
+	mine, theirs, taken string
+	policy              string
+}
+
+func (c mergeConflict) String() string {
+	return fmt.Sprintf("conflict: %s %s: mine %q, theirs %q, took %q (%s)", c.path, c.field, c.mine, c.theirs, c.taken, c.policy)
+}
+
+// mergeRecord merges the changes made to base in mine and theirs into
+// mine. base is nil for records both sides added, or when merging without
+// a base. Fields only one side changed get that change, conflicts are
+// resolved with policies. It returns the conflicts, with unresolved ones
+// for the fail policy.
+func mergeRecord(base, mine, theirs *score, policies *mergePolicies) (conflicts []mergeConflict, unresolved bool) {
+	var baseFields []scoreField
+	if base != nil {
+		baseFields = scoreFields(base)
+	}
+	theirFields := scoreFields(theirs)
+	// Taken before file-info is merged into mine.
+	theirsNewer := lastModifiedTicks(theirs) > lastModifiedTicks(mine)
+
+	for i, f := range scoreFields(mine) {
+		t := theirFields[i]
+		if reflect.DeepEqual(f.value.Interface(), t.value.Interface()) {
+			continue
+		}
+		if base != nil {
+			b := baseFields[i].value.Interface()
+			if reflect.DeepEqual(f.value.Interface(), b) {
+				f.value.Set(t.value)
+				continue
+			}
+			if reflect.DeepEqual(t.value.Interface(), b) {
+				continue
+			}
+		}
+
+		c := mergeConflict{path: mine.FileInformation.AbsoluteFilePath, field: f.name, mine: f.String(), theirs: t.String()}
+		c.policy = policies.policyFor(f.name)
+		switch c.policy {
+		case policyTheirs:
+			f.value.Set(t.value)
+		case policyBest:
+			if v, ok := bestValue(f.name, mine, theirs, f.value, t.value); ok {
+				f.value.Set(v)
+			} else {
+				c.policy += ", kept mine"
+			}
+		case policyNewest:
+			// The side whose chart was modified last, mine if neither.
+			if theirsNewer {
+				f.value.Set(t.value)
+			}
+		case policyFail:
+			unresolved = true
+		}
+		c.taken = f.String()
+		conflicts = append(conflicts, c)
+	}
+
+	return conflicts, unresolved
+}
+
+func runMerge(args []string) {
+	flags := newFlagSet("merge")
+	basePath := flags.String("base", "", "the `songs.db` both databases started from; without it every field the databases differ in is a conflict")
+	minePath := flags.String("mine", songsDBPath, "your `songs.db`")
+	theirsPath := flags.String("theirs", "", "the other `songs.db`")
+	policies := &mergePolicies{byField: map[string]string{}}
+	flags.Var(policies, "policy", "resolve conflicts of a field or group of fields with `field=policy`, may be repeated")
+	flags.StringVar(&policies.def, "default-policy", policyBest, "`policy` of the fields without -policy: "+strings.Join(mergePolicyNames, ", "))
+	output := outputDBFlag(flags)
+	identity := identityFlag(flags)
+	duplicates := flags.String("duplicates", duplicatesFirst, "`policy` choosing the record written of those sharing an identity: "+strings.Join(duplicatePolicyNames, ", "))
+	dryRun := flags.Bool("n", false, "only report the changes and conflicts")
+	readerFlags(flags)
+	flags.Parse(args)
+	if *theirsPath == "" {
+		log.Fatalln("merge needs -theirs")
+	}
+	logFatalIfError(checkMergePolicy(policies.def))
+	logFatalIfError(checkIdentity(*identity))
+	logFatalIfError(checkDuplicatePolicy(*duplicates))
+
+	paths := []string{*minePath, *theirsPath}
+	if *basePath != "" {
+		paths = append(paths, *basePath)
+	}
+	dbs := readDatabases(paths...)
+	versionString, mine, theirs := dbs[0].version, dbs[0].scores, dbs[1].scores
+	var baseScores []score
+	if len(dbs) > 2 {
+		baseScores = dbs[2].scores
+	}
+	r := mergeDatabases(*identity, baseScores, mine, theirs, policies)
+
+	for _, c := range r.conflicts {
+		log.Println(c)
+	}
+	merged, duplicated, err := resolveDuplicates(*identity, *duplicates, r.merged)
+	logFatalIfError(err)
+	infof("%d records added, %d removed, %d duplicates dropped, %d conflicts\n", r.added, r.removed, duplicated, len(r.conflicts))
+	if r.failed {
+		log.Println("conflicts left unresolved by the fail policy, nothing written")
+		os.Exit(1)
+	}
+	if *dryRun {
+		return
+	}
+
+	writeSongsDB(*output, versionString, merged)
+	infof("written %s\n", *output)
+}
+
+// mergeResult is what mergeDatabases did.
+type mergeResult struct {
+	merged         []score
+	conflicts      []mergeConflict
+	added, removed int
+	failed         bool
+}
+
+// mergeDatabases merges the records of mine and theirs, matched by
+// identity, with mergeRecord. Without base every record only one side has
+// is kept.
+func mergeDatabases(identity string, baseScores, mine, theirs []score, policies *mergePolicies) mergeResult {
+	base, theirsByKey := recordsByIdentity(identity, baseScores), recordsByIdentity(identity, theirs)
+	mineKeys, theirsKeys := recordKeys(identity, mine), recordKeys(identity, theirs)
+
+	var merged []score
+	var conflicts []mergeConflict
+	failed := false
+	seen := make(map[string]bool, len(mine))
+	added, removed := 0, 0
+	for i := range mine {
+		m := &mine[i]
+		path, key := m.FileInformation.AbsoluteFilePath, mineKeys[i]
+		seen[key] = true
+		b, t := base[key], theirsByKey[key]
+
+		if t == nil {
+			if b != nil {
+				if reflect.DeepEqual(b.Score, m.Score) {
+					infof("removed by them: %s\n", path)
+					removed++
+					continue
+				}
+				log.Printf("conflict: %s removed by them but changed by you, kept\n", path)
+			}
+			merged = append(merged, *m)
+			continue
+		}
+
+		c, unresolved := mergeRecord(b, m, t, policies)
+		conflicts = append(conflicts, c...)
+		failed = failed || unresolved
+		merged = append(merged, *m)
+	}
+
+	for i := range theirs {
+		t := &theirs[i]
+		path, key := t.FileInformation.AbsoluteFilePath, theirsKeys[i]
+		if seen[key] {
+			continue
+		}
+		if b, ok := base[key]; ok {
+			if reflect.DeepEqual(b.Score, t.Score) {
+				continue
+			}
+			log.Printf("conflict: %s removed by you but changed by them, kept\n", path)
+		} else {
+			infof("added by them: %s\n", path)
+			added++
+		}
+		merged = append(merged, *t)
+	}
+	return mergeResult{merged, conflicts, added, removed, failed}
+}
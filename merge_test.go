@@ -0,0 +1,143 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/SirChronus/dtxmania-dbdump/dtxdb"
+)
+
+// chartSizes are the sizes of the chart files of testChart.
+var chartSizes = map[string]int64{"bas.dtx": 1000, "adv.dtx": 2000, "ext.dtx": 3000}
+
+// testChart returns a record of the chart file name of a song, as DTXMania
+// stores every difficulty of a song as a record of its own.
+func testChart(title, chart string, highSkill float64, plays int32) score {
+	return testChartIn(title, title, chart, highSkill, plays)
+}
+
+// testChartIn is testChart with the song in folder.
+func testChartIn(folder, title, chart string, highSkill float64, plays int32) score {
+	var s score
+	s.FileInformation.AbsoluteFilePath = `C:\DTXFiles\` + folder + `\` + chart
+	s.FileInformation.AbsoluteFolderPath = `C:\DTXFiles\` + folder + `\`
+	s.FileInformation.FileSize = chartSizes[chart]
+	s.FileInformation.LastModified = dtxdb.DateFromTicks(637800000000000000)
+	s.SongIniInformation.LastModified = dtxdb.DateFromTicks(637800000000000000)
+	s.SongInformation.Title, s.SongInformation.Artist = title, "Artist"
+	s.SongInformation.HighSkill.Drums = highSkill
+	s.SongInformation.NbPerformance.Drums = plays
+	return s
+}
+
+func TestResolveDuplicates(t *testing.T) {
+	tests := []struct {
+		name     string
+		identity string
+		policy   string
+		scores   []score
+		want     []string
+	}{
+		{"difficulties of a song", identitySong, duplicatesFirst,
+			[]score{testChart("one", "bas.dtx", 0, 0), testChart("one", "adv.dtx", 0, 0), testChart("one", "ext.dtx", 0, 0)},
+			[]string{"bas.dtx", "adv.dtx", "ext.dtx"}},
+		{"same chart twice", identitySong, duplicatesFirst,
+			[]score{testChart("one", "ext.dtx", 10, 1), testChart("one", "bas.dtx", 0, 0), testChart("one", "ext.dtx", 20, 2)},
+			[]string{"ext.dtx", "bas.dtx"}},
+		{"most played", identitySong, duplicatesMostPlayed,
+			[]score{testChart("one", "ext.dtx", 10, 1), testChart("one", "ext.dtx", 20, 2)},
+			[]string{"ext.dtx"}},
+		{"copies in other folders", identitySize, duplicatesMostPlayed,
+			[]score{testChart("one", "ext.dtx", 10, 1), testChartIn("copy", "one", "bas.dtx", 0, 0), testChartIn("copy", "one", "ext.dtx", 20, 2)},
+			[]string{"ext.dtx", "bas.dtx"}},
+		{"content of different charts", identityContent, duplicatesFirst,
+			[]score{testChart("one", "bas.dtx", 0, 0), testChart("one", "ext.dtx", 0, 0)},
+			[]string{"bas.dtx", "ext.dtx"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kept, removed, err := resolveDuplicates(tt.identity, tt.policy, tt.scores)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if removed != len(tt.scores)-len(tt.want) {
+				t.Errorf("removed %d records, want %d", removed, len(tt.scores)-len(tt.want))
+			}
+			if len(kept) != len(tt.want) {
+				t.Fatalf("kept %d records, want %d", len(kept), len(tt.want))
+			}
+			for i := range kept {
+				if got := chartFileName(&kept[i]); got != tt.want[i] {
+					t.Errorf("record %d is %s, want %s", i, got, tt.want[i])
+				}
+			}
+		})
+	}
+	if kept, _, _ := resolveDuplicates(identitySong, duplicatesMostPlayed, tests[2].scores); kept[0].SongInformation.HighSkill.Drums != 20 {
+		t.Errorf("keep-most-played kept the record played %d times", kept[0].SongInformation.NbPerformance.Drums)
+	}
+}
+
+func TestMergeDatabases(t *testing.T) {
+	mine := []score{testChart("one", "bas.dtx", 50, 1), testChart("one", "adv.dtx", 0, 0), testChart("one", "ext.dtx", 0, 0)}
+	theirs := []score{testChart("one", "bas.dtx", 0, 0), testChart("one", "adv.dtx", 0, 0), testChart("one", "ext.dtx", 120, 3)}
+	want := map[string]float64{"bas.dtx": 50, "adv.dtx": 0, "ext.dtx": 120}
+
+	for _, identity := range []string{identityPath, identitySong} {
+		t.Run(identity, func(t *testing.T) {
+			r := mergeDatabases(identity, nil, mine, theirs, &mergePolicies{byField: map[string]string{}, def: policyBest})
+			if r.failed || r.added != 0 || r.removed != 0 {
+				t.Errorf("failed %v, %d added, %d removed, want nothing", r.failed, r.added, r.removed)
+			}
+			merged, removed, err := resolveDuplicates(identity, duplicatesFirst, r.merged)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if removed != 0 || len(merged) != len(want) {
+				t.Fatalf("merged %d records with %d duplicates, want %d", len(merged), removed, len(want))
+			}
+			for i := range merged {
+				chart := chartFileName(&merged[i])
+				if got := merged[i].SongInformation.HighSkill.Drums; got != want[chart] {
+					t.Errorf("%s high skill %v, want %v", chart, got, want[chart])
+				}
+			}
+		})
+	}
+}
+
+func TestMergeRecord(t *testing.T) {
+	tests := []struct {
+		name       string
+		policy     string
+		withBase   bool
+		mine       float64
+		theirs     float64
+		want       float64
+		conflicts  int
+		unresolved bool
+	}{
+		{"best", policyBest, false, 50, 120, 120, 1, false},
+		{"mine", policyMine, false, 50, 120, 50, 1, false},
+		{"theirs", policyTheirs, false, 50, 120, 120, 1, false},
+		{"fail", policyFail, false, 50, 120, 50, 1, true},
+		{"same", policyFail, false, 50, 50, 50, 0, false},
+		{"only theirs changed", policyMine, true, 0, 120, 120, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mine, theirs := testChart("one", "ext.dtx", tt.mine, 1), testChart("one", "ext.dtx", tt.theirs, 1)
+			var base *score
+			if tt.withBase {
+				b := testChart("one", "ext.dtx", tt.mine, 1)
+				base = &b
+			}
+			conflicts, unresolved := mergeRecord(base, &mine, &theirs, &mergePolicies{byField: map[string]string{}, def: tt.policy})
+			if got := mine.SongInformation.HighSkill.Drums; got != tt.want {
+				t.Errorf("high skill %v, want %v", got, tt.want)
+			}
+			if len(conflicts) != tt.conflicts || unresolved != tt.unresolved {
+				t.Errorf("%d conflicts, unresolved %v, want %d and %v", len(conflicts), unresolved, tt.conflicts, tt.unresolved)
+			}
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"log"
+	"os"
+)
+
+// mmapInput makes openSongsDB map local databases into memory instead of
+// reading them through a buffer, see mmapFlag.
+var mmapInput bool
+
+func mmapFlag(flags *flag.FlagSet) {
+	flags.BoolVar(&mmapInput, "mmap", false, "map the database into memory instead of reading it, where supported")
+}
+
+// mappedFile is a read-only memory mapping of a whole file.
+type mappedFile struct {
+	*bytes.Reader
+	data []byte
+}
+
+func openMappedFile(path string) (*mappedFile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if info.Size() == 0 {
+		// Empty files can not be mapped.
+		return &mappedFile{Reader: bytes.NewReader(nil)}, nil
+	}
+
+	data, err := mapFile(f, int(info.Size()))
+	if err != nil {
+		return nil, err
+	}
+	return &mappedFile{bytes.NewReader(data), data}, nil
+}
+
+func (m *mappedFile) Close() error {
+	if m.data == nil {
+		return nil
+	}
+
+	data := m.data
+	m.data = nil
+	m.Reader = bytes.NewReader(nil)
+	return unmapFile(data)
+}
+
+// openMappedOrLog maps path into memory, returning nil if that is not
+// possible so the caller can fall back to reading the file.
+func openMappedOrLog(path string) *mappedFile {
+	m, err := openMappedFile(path)
+	if err != nil {
+		log.Printf("not mapping %s into memory: %v\n", path, err)
+		return nil
+	}
+	return m
+}
@@ -0,0 +1,20 @@
+//go:build !aix && !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !solaris && !windows
+// +build !aix,!darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!solaris,!windows
+
+package main
+
+import (
+	"errors"
+	"os"
+)
+
+// mmapSupported reports whether mapFile works on this platform.
+const mmapSupported = false
+
+func mapFile(f *os.File, size int) ([]byte, error) {
+	return nil, errors.New("memory mapped files are not supported on this platform")
+}
+
+func unmapFile(data []byte) error {
+	return nil
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"math"
+	"reflect"
+
+	"github.com/SirChronus/dtxmania-dbdump/dtxdb"
+)
+
+// msgpackWriter writes every record as a MessagePack map, one after the
+// other, the way MessagePack streams are read. The maps have the keys of
+// the JSON dump; song types and dates are strings.
+type msgpackWriter struct {
+	w   *bufio.Writer
+	buf []byte
+}
+
+func newMsgpackWriter(w io.Writer) (recordWriter, error) {
+	return &msgpackWriter{w: bufio.NewWriter(w)}, nil
+}
+
+func (m *msgpackWriter) write(s *score) error {
+	m.buf = appendMsgpackMap(m.buf[:0], reflect.ValueOf(s).Elem())
+	_, err := m.w.Write(m.buf)
+	return err
+}
+
+func (m *msgpackWriter) buffered() int {
+	return m.w.Buffered()
+}
+
+func (m *msgpackWriter) close() error {
+	return m.w.Flush()
+}
+
+func appendMsgpackString(b []byte, s string) []byte {
+	switch n := len(s); {
+	case n < 32:
+		b = append(b, 0xa0|byte(n))
+	case n <= math.MaxUint8:
+		b = append(b, 0xd9, byte(n))
+	case n <= math.MaxUint16:
+		b = append(b, 0xda, byte(n>>8), byte(n))
+	default:
+		b = appendUint32(append(b, 0xdb), uint32(n))
+	}
+	return append(b, s...)
+}
+
+func appendMsgpackInt(b []byte, v int64) []byte {
+	switch {
+	case v >= -32 && v < 128:
+		return append(b, byte(v))
+	case v >= math.MinInt8 && v <= math.MaxInt8:
+		return append(b, 0xd0, byte(v))
+	case v >= math.MinInt16 && v <= math.MaxInt16:
+		return append(b, 0xd1, byte(v>>8), byte(v))
+	case v >= math.MinInt32 && v <= math.MaxInt32:
+		return appendUint32(append(b, 0xd2), uint32(v))
+	}
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], uint64(v))
+	return append(append(b, 0xd3), buf[:]...)
+}
+
+func appendMsgpackMap(b []byte, v reflect.Value) []byte {
+	names, values := elementFields(v)
+	if n := len(names); n < 16 {
+		b = append(b, 0x80|byte(n))
+	} else {
+		b = append(b, 0xde, byte(n>>8), byte(n))
+	}
+	for i, f := range values {
+		b = appendMsgpackString(b, names[i])
+		b = appendMsgpackValue(b, f)
+	}
+	return b
+}
+
+func appendMsgpackValue(b []byte, v reflect.Value) []byte {
+	if t, ok := v.Interface().(dtxdb.SongType); ok {
+		return appendMsgpackString(b, t.String())
+	}
+	if d, ok := v.Interface().(dtxdb.Date); ok {
+		return appendMsgpackString(b, d.String())
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return appendMsgpackString(b, v.String())
+	case reflect.Bool:
+		if v.Bool() {
+			return append(b, 0xc3)
+		}
+		return append(b, 0xc2)
+	case reflect.Int32, reflect.Int64:
+		return appendMsgpackInt(b, v.Int())
+	case reflect.Float64:
+		var buf [8]byte
+		binary.BigEndian.PutUint64(buf[:], math.Float64bits(v.Float()))
+		return append(append(b, 0xcb), buf[:]...)
+	case reflect.Struct:
+		return appendMsgpackMap(b, v)
+	}
+	panic("msgpack: unsupported type " + v.Type().String())
+}
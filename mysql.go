@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/SirChronus/dtxmania-dbdump/dtxdb"
+)
+
+// sqlTablePrefix is prepended to the table name of the mysql format, set
+// with dump -table-prefix.
+var sqlTablePrefix = "dtx_"
+
+// checkTablePrefix rejects prefixes that would need quoting, as the table
+// name is written between backticks as it is.
+func checkTablePrefix(prefix string) error {
+	for _, r := range prefix {
+		if !(r == '_' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z') {
+			return fmt.Errorf("table prefix %q may only hold letters, digits and _", prefix)
+		}
+	}
+	return nil
+}
+
+// mysqlBatchRows is the number of rows per INSERT statement.
+const mysqlBatchRows = 100
+
+// sqlColumnName turns a field name like "level.drums" into level_drums.
+func sqlColumnName(field string) string {
+	return strings.NewReplacer(".", "_", "-", "_").Replace(field)
+}
+
+func mysqlColumnType(v reflect.Value) string {
+	if v.Type() == dateType {
+		return "DATETIME(6) NULL"
+	}
+	if v.Type().Implements(stringerType) {
+		return "VARCHAR(16) NOT NULL"
+	}
+	switch v.Kind() {
+	case reflect.Bool:
+		return "TINYINT(1) NOT NULL"
+	case reflect.Int32:
+		return "INT NOT NULL"
+	case reflect.Int64:
+		return "BIGINT NOT NULL"
+	case reflect.Float64:
+		return "DOUBLE NULL"
+	}
+	return "TEXT NOT NULL"
+}
+
+var mysqlEscaper = strings.NewReplacer(`\`, `\\`, `'`, `\'`, "\x00", `\0`, "\n", `\n`, "\r", `\r`, "\x1a", `\Z`)
+
+func mysqlString(s string) string {
+	return "'" + mysqlEscaper.Replace(s) + "'"
+}
+
+// mysqlDate converts a date to a DATETIME literal in UTC. Dates before the
+// range MySQL supports, like those of charts DTXMania never found, are
+// NULL.
+func mysqlDate(d dtxdb.Date) string {
+	if d.Year() < 1000 {
+		return "NULL"
+	}
+	return "'" + d.UTC().Format("2006-01-02 15:04:05.000000") + "'"
+}
+
+func mysqlValue(v reflect.Value) string {
+	if v.Type() == dateType {
+		return mysqlDate(v.Interface().(dtxdb.Date))
+	}
+	if v.Type().Implements(stringerType) {
+		return mysqlString(v.Interface().(fmt.Stringer).String())
+	}
+	switch v.Kind() {
+	case reflect.Bool:
+		if v.Bool() {
+			return "1"
+		}
+		return "0"
+	case reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10)
+	case reflect.Float64:
+		if math.IsNaN(v.Float()) || math.IsInf(v.Float(), 0) {
+			return "NULL"
+		}
+		return strconv.FormatFloat(v.Float(), 'g', -1, 64)
+	}
+	return mysqlString(v.String())
+}
+
+// mysqlWriter writes a script creating a songs table and inserting every
+// record into it, to be run with the mysql client.
+type mysqlWriter struct {
+	w       *bufio.Writer
+	table   string
+	columns string
+	rows    int
+}
+
+func newMySQLWriter(w io.Writer) (recordWriter, error) {
+	r, err := newMySQLInsertWriter(w)
+	if err != nil {
+		return nil, err
+	}
+	m := r.(*mysqlWriter)
+
+	var definitions []string
+	for _, f := range scoreFields(&score{}) {
+		definitions = append(definitions, fmt.Sprintf("  `%s` %s", sqlColumnName(f.name), mysqlColumnType(f.value)))
+	}
+	fmt.Fprintf(m.w, "DROP TABLE IF EXISTS %s;\n", m.table)
+	fmt.Fprintf(m.w, "CREATE TABLE %s (\n  `id` INT NOT NULL AUTO_INCREMENT PRIMARY KEY,\n%s\n) DEFAULT CHARSET=utf8mb4;\n",
+		m.table, strings.Join(definitions, ",\n"))
+	return m, nil
+}
+
+// newMySQLInsertWriter only inserts into the table, for the parts after
+// the first of a split dump.
+func newMySQLInsertWriter(w io.Writer) (recordWriter, error) {
+	if err := checkTablePrefix(sqlTablePrefix); err != nil {
+		return nil, err
+	}
+	m := &mysqlWriter{w: bufio.NewWriter(w), table: "`" + sqlTablePrefix + "songs`"}
+
+	var columns []string
+	for _, f := range scoreFields(&score{}) {
+		columns = append(columns, "`"+sqlColumnName(f.name)+"`")
+	}
+	m.columns = strings.Join(columns, ", ")
+	return m, nil
+}
+
+func (m *mysqlWriter) write(s *score) error {
+	if m.rows%mysqlBatchRows == 0 {
+		if m.rows > 0 {
+			m.w.WriteString(";\n")
+		}
+		fmt.Fprintf(m.w, "INSERT INTO %s (%s) VALUES\n", m.table, m.columns)
+	} else {
+		m.w.WriteString(",\n")
+	}
+	m.rows++
+
+	var values []string
+	for _, f := range scoreFields(s) {
+		values = append(values, mysqlValue(f.value))
+	}
+	_, err := fmt.Fprintf(m.w, "(%s)", strings.Join(values, ", "))
+	return err
+}
+
+func (m *mysqlWriter) buffered() int {
+	return m.w.Buffered()
+}
+
+func (m *mysqlWriter) close() error {
+	if m.rows > 0 {
+		m.w.WriteString(";\n")
+	}
+	return m.w.Flush()
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/SirChronus/dtxmania-dbdump/dtxdb"
+)
+
+// overrideFileName is the file curators put in a song folder to correct
+// what DTXMania read from the charts. It is written in a subset of YAML:
+//
+//	title: Correct Title
+//	genre: Anime
+//	charts:
+//	  ext.dtx:
+//	    level:
+//	      drums: 85
+//
+// Fields at the top apply to every chart of the folder, those below charts
+// to the named chart file only. Nested keys name the dotted field, so
+// "level.drums: 85" can be written on one line as well.
+const overrideFileName = "dbdump.override.yaml"
+
+// applyOverrides is set by -overrides.
+var applyOverrides bool
+
+func overridesFlag(flags *flag.FlagSet) {
+	flags.BoolVar(&applyOverrides, "overrides", false, "apply the "+overrideFileName+" files of the song folders")
+}
+
+// playDataGroups are the fields holding the progress of the player, which
+// override files cannot set.
+var playDataGroups = []string{"best-rank", "high-skill", "full-combo", "score-exists", "nb-performance", "performance-history"}
+
+// overridable reports whether an override file may set the field, which
+// leaves the chart metadata below song-info.
+func overridable(name string) bool {
+	group := strings.Split(name, ".")[0]
+	if group == "file-info" || group == "song-ini-info" || group == "pack" || group == "sort-key" || group == "source" {
+		return false
+	}
+	for _, g := range playDataGroups {
+		if group == g {
+			return false
+		}
+	}
+	return true
+}
+
+// override is a value of an override file. chart is empty for values
+// applying to the whole folder.
+type override struct {
+	chart, field, value string
+	line                int
+}
+
+// parseYAMLValue removes the quotes or the trailing comment of a value.
+func parseYAMLValue(v string) (string, error) {
+	switch {
+	case strings.HasPrefix(v, `"`):
+		return strconv.Unquote(v)
+	case strings.HasPrefix(v, "'"):
+		if len(v) < 2 || !strings.HasSuffix(v, "'") {
+			return "", fmt.Errorf("unterminated string %s", v)
+		}
+		return strings.ReplaceAll(v[1:len(v)-1], "''", "'"), nil
+	}
+	if i := strings.Index(v, " #"); i >= 0 {
+		v = v[:i]
+	}
+	return strings.TrimSpace(v), nil
+}
+
+// parseOverrides reads an override file, path is only used in errors.
+func parseOverrides(r io.Reader, path string) ([]override, error) {
+	type key struct {
+		indent int
+		name   string
+	}
+	var overrides []override
+	var parents []key
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimRight(scanner.Text(), " \t\r")
+		trimmed := strings.TrimLeft(text, " ")
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") || text == "---" {
+			continue
+		}
+		if strings.HasPrefix(trimmed, "\t") {
+			return nil, fmt.Errorf("%s:%d: indent with spaces, not tabs", path, line)
+		}
+		indent := len(text) - len(trimmed)
+		for len(parents) > 0 && parents[len(parents)-1].indent >= indent {
+			parents = parents[:len(parents)-1]
+		}
+
+		name, value := trimmed, ""
+		if i := strings.Index(trimmed, ": "); i >= 0 {
+			name, value = trimmed[:i], strings.TrimSpace(trimmed[i+2:])
+		} else if !strings.HasSuffix(trimmed, ":") {
+			return nil, fmt.Errorf("%s:%d: expected \"key: value\"", path, line)
+		}
+		name = strings.TrimSuffix(name, ":")
+		if value == "" {
+			parents = append(parents, key{indent, name})
+			continue
+		}
+
+		v, err := parseYAMLValue(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, line, err)
+		}
+		names := []string{}
+		for _, p := range parents {
+			names = append(names, p.name)
+		}
+		names = append(names, name)
+
+		o := override{value: v, line: line}
+		if names[0] == "charts" {
+			if len(names) < 3 {
+				return nil, fmt.Errorf("%s:%d: charts lists chart files with the fields to set", path, line)
+			}
+			o.chart, names = names[1], names[2:]
+		}
+		o.field = strings.Join(names, ".")
+		if _, ok := lookupField(&score{}, o.field); !ok || !overridable(o.field) {
+			return nil, fmt.Errorf("%s:%d: %s cannot be overridden", path, line, o.field)
+		}
+		overrides = append(overrides, o)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return overrides, nil
+}
+
+// overrideHook applies the override files of the song folders to the
+// records read. report, if not nil, is called for every value changed.
+func overrideHook(report func(s *score, field, old, new string)) dtxdb.Hook {
+	type folder struct {
+		path      string
+		overrides []override
+		err       error
+	}
+	folders := map[string]*folder{}
+
+	return func(rec *dtxdb.Score) error {
+		dir := rec.FileInformation.AbsoluteFolderPath
+		f, ok := folders[dir]
+		if !ok {
+			f = &folder{path: filepath.Join(dir, overrideFileName)}
+			if r, err := os.Open(f.path); err == nil {
+				f.overrides, f.err = parseOverrides(r, f.path)
+				r.Close()
+			} else if !os.IsNotExist(err) {
+				f.err = err
+			}
+			folders[dir] = f
+		}
+		if f.err != nil {
+			return f.err
+		}
+
+		s := score{Score: *rec}
+		chart := chartFileName(&s)
+		for _, o := range f.overrides {
+			if o.chart != "" && !strings.EqualFold(o.chart, chart) {
+				continue
+			}
+			field, _ := lookupField(&s, o.field)
+			old := field.String()
+			if err := field.set(o.value); err != nil {
+				return fmt.Errorf("%s:%d: %v", f.path, o.line, err)
+			}
+			if report != nil && field.String() != old {
+				report(&s, o.field, old, field.String())
+			}
+		}
+		*rec = s.Score
+		return nil
+	}
+}
+
+func runOverrides(args []string) {
+	flags := newFlagSet("overrides")
+	input := inputDBFlag(flags)
+	output := outputDBFlag(flags)
+	dryRun := flags.Bool("n", false, "only report the values the override files change")
+	readerFlags(flags)
+	flags.Parse(args)
+
+	changed := map[string]bool{}
+	readHooks = append(readHooks, overrideHook(func(s *score, field, old, new string) {
+		infof("%s: %s %q -> %q\n", s.FileInformation.AbsoluteFilePath, field, old, new)
+		changed[s.FileInformation.AbsoluteFilePath] = true
+	}))
+	versionString, scores := readAllScores(*input)
+
+	infof("%d records changed by %s files\n", len(changed), overrideFileName)
+	if *dryRun {
+		return
+	}
+
+	writeSongsDB(*output, versionString, scores)
+	infof("written %s\n", *output)
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+type packEntry struct {
+	prefix string
+	name   string
+}
+
+// packManifest maps folder prefixes to the pack the songs below them come
+// from. Entries are ordered from the longest prefix to the shortest so the
+// most specific entry wins.
+type packManifest []packEntry
+
+// loadPackManifest reads a manifest with one "prefix = pack name" entry per
+// line. Empty lines and lines starting with # are ignored.
+func loadPackManifest(path string) (packManifest, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var m packManifest
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		eq := strings.Index(text, "=")
+		if eq < 0 {
+			return nil, fmt.Errorf("%s:%d: expected \"prefix = pack name\"", path, line)
+		}
+		prefix := normalizePackPath(strings.TrimSpace(text[:eq]))
+		name := strings.TrimSpace(text[eq+1:])
+		if prefix == "" || name == "" {
+			return nil, fmt.Errorf("%s:%d: empty prefix or pack name", path, line)
+		}
+		m = append(m, packEntry{prefix, name})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(m, func(i, j int) bool {
+		return len(m[i].prefix) > len(m[j].prefix)
+	})
+	return m, nil
+}
+
+func normalizePackPath(path string) string {
+	return strings.ToLower(strings.ReplaceAll(path, "\\", "/"))
+}
+
+// packOf returns the name of the pack the song belongs to, or an empty
+// string if no entry matches. Prefixes match either the start of the folder
+// path or any folder inside it, so "DTXFiles.Aery/" matches
+// "C:\DTXMania\DTXFiles.Aery\song\".
+func (m packManifest) packOf(s *score) string {
+	folder := normalizePackPath(s.FileInformation.AbsoluteFolderPath)
+	for _, e := range m {
+		if strings.HasPrefix(folder, e.prefix) || strings.Contains(folder, "/"+e.prefix) {
+			return e.name
+		}
+	}
+
+	return ""
+}
+
+// packFlag registers the -packs flag shared by the commands that tag songs
+// with their pack.
+func packFlag(flags *flag.FlagSet) *string {
+	return flags.String("packs", "", "tag songs with the pack names from the manifest `file`")
+}
+
+// loadPackFlag loads the manifest named by a -packs flag, if one was given.
+func loadPackFlag(path string) packManifest {
+	if path == "" {
+		return nil
+	}
+
+	m, err := loadPackManifest(path)
+	logFatalIfError(err)
+	return m
+}
@@ -0,0 +1,311 @@
+package main
+
+import (
+	"encoding/binary"
+	"io"
+	"math"
+	"reflect"
+
+	"github.com/SirChronus/dtxmania-dbdump/dtxdb"
+)
+
+// Parquet files are written with a flat schema, a required column for
+// every field of the XML dump named like the SQL columns. Values are PLAIN
+// encoded and uncompressed, one data page per column and row group; the
+// metadata is encoded with the Thrift compact protocol.
+
+// parquetRowGroupRows is the number of records of a row group.
+const parquetRowGroupRows = 10000
+
+// Parquet physical types.
+const (
+	parquetBoolean   = 0
+	parquetInt32     = 1
+	parquetInt64     = 2
+	parquetDouble    = 5
+	parquetByteArray = 6
+)
+
+// Thrift compact protocol types.
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// thriftWriter encodes structs with the Thrift compact protocol. Fields
+// are written in the order of their ids.
+type thriftWriter struct {
+	buf  []byte
+	last []int16
+}
+
+func newThriftWriter() *thriftWriter {
+	return &thriftWriter{last: []int16{0}}
+}
+
+func (t *thriftWriter) varint(v uint64) {
+	var b [binary.MaxVarintLen64]byte
+	t.buf = append(t.buf, b[:binary.PutUvarint(b[:], v)]...)
+}
+
+func zigzag(v int64) uint64 {
+	return uint64(v<<1) ^ uint64(v>>63)
+}
+
+func (t *thriftWriter) field(id int16, typ byte) {
+	last := &t.last[len(t.last)-1]
+	if delta := id - *last; delta > 0 && delta <= 15 {
+		t.buf = append(t.buf, byte(delta)<<4|typ)
+	} else {
+		t.buf = append(t.buf, typ)
+		t.varint(zigzag(int64(id)))
+	}
+	*last = id
+}
+
+func (t *thriftWriter) i32(id int16, v int32) {
+	t.field(id, thriftI32)
+	t.varint(zigzag(int64(v)))
+}
+
+func (t *thriftWriter) i64(id int16, v int64) {
+	t.field(id, thriftI64)
+	t.varint(zigzag(v))
+}
+
+func (t *thriftWriter) binary(id int16, s string) {
+	t.field(id, thriftBinary)
+	t.varint(uint64(len(s)))
+	t.buf = append(t.buf, s...)
+}
+
+func (t *thriftWriter) list(id int16, elem byte, n int) {
+	t.field(id, thriftList)
+	if n < 15 {
+		t.buf = append(t.buf, byte(n)<<4|elem)
+	} else {
+		t.buf = append(t.buf, 0xf0|elem)
+		t.varint(uint64(n))
+	}
+}
+
+// begin starts a struct, as field id or as an element of a list when id
+// is 0.
+func (t *thriftWriter) begin(id int16) {
+	if id != 0 {
+		t.field(id, thriftStruct)
+	}
+	t.last = append(t.last, 0)
+}
+
+func (t *thriftWriter) end() {
+	t.buf = append(t.buf, 0)
+	t.last = t.last[:len(t.last)-1]
+}
+
+func appendLittleUint32(b []byte, v uint32) []byte {
+	return append(b, byte(v), byte(v>>8), byte(v>>16), byte(v>>24))
+}
+
+func appendLittleUint64(b []byte, v uint64) []byte {
+	return appendLittleUint32(appendLittleUint32(b, uint32(v)), uint32(v>>32))
+}
+
+type parquetColumn struct {
+	name     string
+	physical int32
+	utf8     bool
+	data     []byte
+}
+
+type parquetChunk struct {
+	offset, size int64
+}
+
+type parquetRowGroup struct {
+	rows   int64
+	size   int64
+	chunks []parquetChunk
+}
+
+// parquetWriter keeps the values of a row group in memory and writes the
+// file metadata, which lists every row group, on close.
+type parquetWriter struct {
+	w       *countingWriter
+	columns []parquetColumn
+	rows    int
+	total   int64
+	groups  []parquetRowGroup
+}
+
+func parquetColumnType(v reflect.Value) (int32, bool) {
+	if _, ok := v.Interface().(dtxdb.SongType); ok {
+		return parquetByteArray, true
+	}
+	switch v.Kind() {
+	case reflect.Bool:
+		return parquetBoolean, false
+	case reflect.Int32:
+		return parquetInt32, false
+	case reflect.Int64:
+		return parquetInt64, false
+	case reflect.Float64:
+		return parquetDouble, false
+	}
+	return parquetByteArray, true
+}
+
+func newParquetWriter(w io.Writer) (recordWriter, error) {
+	p := &parquetWriter{w: &countingWriter{w: w}}
+	for _, f := range scoreFields(&score{}) {
+		physical, utf8 := parquetColumnType(f.value)
+		p.columns = append(p.columns, parquetColumn{name: sqlColumnName(f.name), physical: physical, utf8: utf8})
+	}
+	if _, err := io.WriteString(p.w, "PAR1"); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *parquetWriter) write(s *score) error {
+	for i, f := range scoreFields(s) {
+		c := &p.columns[i]
+		switch c.physical {
+		case parquetBoolean:
+			// Booleans are bit packed, the first value in the lowest bit.
+			if p.rows%8 == 0 {
+				c.data = append(c.data, 0)
+			}
+			if f.value.Bool() {
+				c.data[len(c.data)-1] |= 1 << (p.rows % 8)
+			}
+		case parquetInt32:
+			c.data = appendLittleUint32(c.data, uint32(f.value.Int()))
+		case parquetInt64:
+			c.data = appendLittleUint64(c.data, uint64(f.value.Int()))
+		case parquetDouble:
+			c.data = appendLittleUint64(c.data, math.Float64bits(f.value.Float()))
+		default:
+			v := f.String()
+			c.data = appendLittleUint32(c.data, uint32(len(v)))
+			c.data = append(c.data, v...)
+		}
+	}
+	p.rows++
+	p.total++
+	if p.rows == parquetRowGroupRows {
+		return p.flushRowGroup()
+	}
+	return nil
+}
+
+// pageHeader encodes the header of a PLAIN encoded data page of n values
+// without repetition or definition levels.
+func pageHeader(size, n int) []byte {
+	t := newThriftWriter()
+	t.i32(1, 0) // DATA_PAGE
+	t.i32(2, int32(size))
+	t.i32(3, int32(size))
+	t.begin(5)
+	t.i32(1, int32(n))
+	t.i32(2, 0) // PLAIN
+	t.i32(3, 3) // RLE
+	t.i32(4, 3) // RLE
+	t.end()
+	t.end()
+	return t.buf
+}
+
+func (p *parquetWriter) flushRowGroup() error {
+	group := parquetRowGroup{rows: int64(p.rows)}
+	for i := range p.columns {
+		c := &p.columns[i]
+		chunk := parquetChunk{offset: p.w.n}
+		if _, err := p.w.Write(pageHeader(len(c.data), p.rows)); err != nil {
+			return err
+		}
+		if _, err := p.w.Write(c.data); err != nil {
+			return err
+		}
+		chunk.size = p.w.n - chunk.offset
+		group.size += chunk.size
+		group.chunks = append(group.chunks, chunk)
+		c.data = c.data[:0]
+	}
+	p.groups = append(p.groups, group)
+	p.rows = 0
+	return nil
+}
+
+func (p *parquetWriter) fileMetaData() []byte {
+	t := newThriftWriter()
+	t.i32(1, 1)
+	t.list(2, thriftStruct, len(p.columns)+1)
+	t.begin(0)
+	t.binary(4, "song")
+	t.i32(5, int32(len(p.columns)))
+	t.end()
+	for _, c := range p.columns {
+		t.begin(0)
+		t.i32(1, c.physical)
+		t.i32(3, 0) // REQUIRED
+		t.binary(4, c.name)
+		if c.utf8 {
+			t.i32(6, 0) // UTF8
+			t.begin(10)
+			t.begin(1) // STRING
+			t.end()
+			t.end()
+		}
+		t.end()
+	}
+	t.i64(3, p.total)
+
+	t.list(4, thriftStruct, len(p.groups))
+	for _, g := range p.groups {
+		t.begin(0)
+		t.list(1, thriftStruct, len(g.chunks))
+		for i, chunk := range g.chunks {
+			c := &p.columns[i]
+			t.begin(0)
+			t.i64(2, chunk.offset)
+			t.begin(3)
+			t.i32(1, c.physical)
+			t.list(2, thriftI32, 2)
+			t.varint(zigzag(0)) // PLAIN
+			t.varint(zigzag(3)) // RLE
+			t.list(3, thriftBinary, 1)
+			t.varint(uint64(len(c.name)))
+			t.buf = append(t.buf, c.name...)
+			t.i32(4, 0) // UNCOMPRESSED
+			t.i64(5, g.rows)
+			t.i64(6, chunk.size)
+			t.i64(7, chunk.size)
+			t.i64(9, chunk.offset)
+			t.end()
+			t.end()
+		}
+		t.i64(2, g.size)
+		t.i64(3, g.rows)
+		t.end()
+	}
+	t.binary(6, "dtxmania-dbdump")
+	t.end()
+	return t.buf
+}
+
+func (p *parquetWriter) close() error {
+	if p.rows > 0 {
+		if err := p.flushRowGroup(); err != nil {
+			return err
+		}
+	}
+	meta := p.fileMetaData()
+	meta = appendLittleUint32(meta, uint32(len(meta)))
+	meta = append(meta, "PAR1"...)
+	_, err := p.w.Write(meta)
+	return err
+}
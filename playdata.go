@@ -0,0 +1,304 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/SirChronus/dtxmania-dbdump/dtxdb"
+)
+
+// songID returns an identifier for the chart of a record that survives
+// reinstalls and moved song folders: it is derived from the title, the
+// artist and the chart file name, which tells the difficulties of a song
+// apart.
+func songID(s *score) string {
+	h := sha1.New()
+	for _, field := range []string{s.SongInformation.Title, s.SongInformation.Artist, chartFileName(s)} {
+		h.Write([]byte(strings.ToLower(strings.TrimSpace(field))))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// playRecord holds the player progress of one chart.
+type playRecord struct {
+	ID                 string                   `xml:"id,attr"`
+	Title              string                   `xml:"title,attr"`
+	Artist             string                   `xml:"artist,attr"`
+	BestRank           dtxdb.DGBInt32           `xml:"best-rank"`
+	HighSkill          dtxdb.DGBDouble          `xml:"high-skill"`
+	FullCombo          dtxdb.DGBBoolean         `xml:"full-combo"`
+	ScoreExists        dtxdb.DGBBoolean         `xml:"score-exists"`
+	NbPerformance      dtxdb.DGBInt32           `xml:"nb-performance"`
+	PerformanceHistory dtxdb.PerformanceHistory `xml:"performance-history"`
+}
+
+type playData struct {
+	XMLName xml.Name     `xml:"play-data"`
+	Songs   []playRecord `xml:"song"`
+}
+
+func playRecordOf(s *score) playRecord {
+	return playRecord{
+		ID:                 songID(s),
+		Title:              s.SongInformation.Title,
+		Artist:             s.SongInformation.Artist,
+		BestRank:           s.SongInformation.BestRank,
+		HighSkill:          s.SongInformation.HighSkill,
+		FullCombo:          s.SongInformation.FullCombo,
+		ScoreExists:        s.SongInformation.ScoreExists,
+		NbPerformance:      s.SongInformation.NbPerformance,
+		PerformanceHistory: s.SongInformation.PerformanceHistory,
+	}
+}
+
+func (p *playRecord) applyTo(s *score) {
+	s.SongInformation.BestRank = p.BestRank
+	s.SongInformation.HighSkill = p.HighSkill
+	s.SongInformation.FullCombo = p.FullCombo
+	s.SongInformation.ScoreExists = p.ScoreExists
+	s.SongInformation.NbPerformance = p.NbPerformance
+	s.SongInformation.PerformanceHistory = p.PerformanceHistory
+}
+
+func readPlayData(path string) playData {
+	f, err := os.Open(path)
+	logFatalIfError(err)
+	defer f.Close()
+
+	var data playData
+	logFatalIfError(xml.NewDecoder(bufio.NewReader(f)).Decode(&data))
+	return data
+}
+
+func writePlayData(path string, data playData) {
+	f, err := os.Create(path)
+	logFatalIfError(err)
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	logFatalIfError(enc.Encode(data))
+	logFatalIfError(w.Flush())
+}
+
+func runPlayData(args []string) {
+	if len(args) > 0 {
+		switch args[0] {
+		case "export":
+			exportPlayData(args[1:])
+			return
+		case "import":
+			importPlayData(args[1:])
+			return
+		case "merge":
+			mergePlayData(args[1:])
+			return
+		}
+	}
+
+	fmt.Fprintln(os.Stderr, "usage: dbdump playdata export|import|merge [flags]")
+	os.Exit(2)
+}
+
+func exportPlayData(args []string) {
+	flags := newFlagSet("playdata export")
+	input := inputDBFlag(flags)
+	output := flags.String("o", "playdata.xml", "write the play data to `file`")
+	readerFlags(flags)
+	flags.Parse(args)
+
+	_, scores := readAllScores(*input)
+
+	var data playData
+	seen := make(map[string]bool, len(scores))
+	for i := range scores {
+		p := playRecordOf(&scores[i])
+		if seen[p.ID] {
+			log.Printf("duplicate: %s has the same title, artist and chart name as another song, skipped\n", scores[i].FileInformation.AbsoluteFilePath)
+			continue
+		}
+		seen[p.ID] = true
+		data.Songs = append(data.Songs, p)
+	}
+
+	writePlayData(*output, data)
+	infof("play data of %d songs written to %s\n", len(data.Songs), *output)
+}
+
+func importPlayData(args []string) {
+	flags := newFlagSet("playdata import")
+	input := flags.String("i", "playdata.xml", "read the play data from `file`")
+	from := flags.String("from", "", "copy the play data of the songs.db `file` instead, matching the songs by -identity")
+	identity := identityFlag(flags)
+	best := flags.Bool("best", false, "keep the better result of both for every song and instrument")
+	db := flags.String("db", songsDBPath, "restore the play data into the records of the songs.db `file`")
+	output := outputDBFlag(flags)
+	readerFlags(flags)
+	flags.Parse(args)
+
+	if *from != "" {
+		logFatalIfError(checkIdentity(*identity))
+		importPlayDataFromDB(*from, *db, *identity, *best, *output)
+		return
+	}
+	restorePlayData(readPlayData(*input), *db, *best, *output)
+}
+
+// runImportScores is playdata import -from, with the database to copy the
+// play data from as argument.
+func runImportScores(args []string) {
+	flags := newFlagSet("import-scores")
+	identity := identityFlag(flags)
+	best := flags.Bool("best", false, "keep the better result of both for every song and instrument")
+	db := flags.String("db", songsDBPath, "restore the play data into the records of the songs.db `file`")
+	output := outputDBFlag(flags)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: %s import-scores [flags] old/songs.db\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	readerFlags(flags)
+	flags.Parse(args)
+	logFatalIfError(checkIdentity(*identity))
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	importPlayDataFromDB(flags.Arg(0), *db, *identity, *best, *output)
+}
+
+// applyPlayRecord restores p onto s, or only what p did better if best is
+// set.
+func applyPlayRecord(p *playRecord, s *score, best bool) {
+	if best {
+		merged := playRecordOf(s)
+		mergePlayRecord(&merged, p)
+		p = &merged
+	}
+	p.applyTo(s)
+}
+
+// importPlayDataFromDB copies the play data of the records of the database
+// at path onto those of the database at dbPath with the same identity and
+// writes the result to output, for moving to another install.
+func importPlayDataFromDB(path, dbPath, identity string, best bool, output string) {
+	dbs := readDatabases(path, dbPath)
+	source, versionString, scores := dbs[0].scores, dbs[1].version, dbs[1].scores
+	sourceByKey := recordsByIdentity(identity, source)
+
+	restored := 0
+	matched := make(map[string]bool, len(sourceByKey))
+	for i, key := range recordKeys(identity, scores) {
+		if src, ok := sourceByKey[key]; ok {
+			p := playRecordOf(src)
+			applyPlayRecord(&p, &scores[i], best)
+			matched[key] = true
+			restored++
+		}
+	}
+	for i, key := range recordKeys(identity, source) {
+		if !matched[key] && totalPlays(&source[i]) > 0 {
+			log.Printf("not in songs.db: %s\n", source[i].FileInformation.AbsoluteFilePath)
+		}
+	}
+
+	writeSongsDB(output, versionString, scores)
+	infof("play data of %d songs imported into %s\n", restored, output)
+}
+
+// restorePlayData applies data onto the records of the database at dbPath
+// with the same song ID and writes the result to output.
+func restorePlayData(data playData, dbPath string, best bool, output string) {
+	byID := make(map[string]*playRecord, len(data.Songs))
+	for i := range data.Songs {
+		byID[data.Songs[i].ID] = &data.Songs[i]
+	}
+
+	versionString, scores := readAllScores(dbPath)
+	restored := 0
+	matched := make(map[string]bool, len(byID))
+	for i := range scores {
+		if p, ok := byID[songID(&scores[i])]; ok {
+			applyPlayRecord(p, &scores[i], best)
+			matched[p.ID] = true
+			restored++
+		}
+	}
+	for _, p := range data.Songs {
+		if !matched[p.ID] {
+			log.Printf("not in songs.db: %s - %s\n", p.Artist, p.Title)
+		}
+	}
+
+	writeSongsDB(output, versionString, scores)
+	infof("play data of %d songs restored into %s\n", restored, output)
+}
+
+func totalPerformances(p *playRecord) int32 {
+	return p.NbPerformance.Drums + p.NbPerformance.Guitar + p.NbPerformance.Bass
+}
+
+// mergePlayRecord folds other into p, keeping the better result of every
+// instrument. Play counts are not added up since both exports usually share
+// the plays made before the data was copied to the second machine; the
+// history of the record with the most plays is kept.
+func mergePlayRecord(p, other *playRecord) {
+	if totalPerformances(other) > totalPerformances(p) {
+		p.PerformanceHistory = other.PerformanceHistory
+	}
+
+	for _, i := range dtxdb.Instruments {
+		if other.BestRank.Get(i) < p.BestRank.Get(i) {
+			p.BestRank.Set(i, other.BestRank.Get(i))
+		}
+		if other.HighSkill.Get(i) > p.HighSkill.Get(i) {
+			p.HighSkill.Set(i, other.HighSkill.Get(i))
+		}
+		if other.NbPerformance.Get(i) > p.NbPerformance.Get(i) {
+			p.NbPerformance.Set(i, other.NbPerformance.Get(i))
+		}
+		p.FullCombo.Set(i, p.FullCombo.Get(i) || other.FullCombo.Get(i))
+		p.ScoreExists.Set(i, p.ScoreExists.Get(i) || other.ScoreExists.Get(i))
+	}
+}
+
+func mergePlayData(args []string) {
+	flags := newFlagSet("playdata merge")
+	output := flags.String("playdata", "playdata.xml", "write the merged play data to `file`")
+	apply := flags.Bool("apply", false, "also restore the merged play data into the database of -i, written to -o")
+	input := inputDBFlag(flags)
+	dbOutput := outputDBFlag(flags)
+	readerFlags(flags)
+	flags.Parse(args)
+	if flags.NArg() < 2 {
+		log.Fatalln("merge needs at least two play data files")
+	}
+
+	var merged playData
+	byID := make(map[string]int)
+	for _, path := range flags.Args() {
+		for _, p := range readPlayData(path).Songs {
+			if i, ok := byID[p.ID]; ok {
+				mergePlayRecord(&merged.Songs[i], &p)
+				continue
+			}
+			byID[p.ID] = len(merged.Songs)
+			merged.Songs = append(merged.Songs, p)
+		}
+	}
+
+	writePlayData(*output, merged)
+	infof("play data of %d songs merged into %s\n", len(merged.Songs), *output)
+
+	if *apply {
+		restorePlayData(merged, *input, false, *dbOutput)
+	}
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/SirChronus/dtxmania-dbdump/dtxdb"
+)
+
+// previewColors are the colours of the strips of each instrument.
+var previewColors = map[dtxdb.Instrument]color.RGBA{
+	dtxdb.Drums:  {0xe0, 0x50, 0x40, 0xff},
+	dtxdb.Guitar: {0x40, 0xb0, 0x50, 0xff},
+	dtxdb.Bass:   {0x40, 0x70, 0xe0, 0xff},
+}
+
+var previewBackground = color.RGBA{0x20, 0x20, 0x20, 0xff}
+
+// chartPreview is the note density of a chart over time: for every
+// instrument with notes, the number of notes of each of the bins the chart
+// is cut into.
+type chartPreview struct {
+	title string
+	parts []dtxdb.Instrument
+	bins  map[dtxdb.Instrument][]int
+	max   int
+}
+
+func newChartPreview(title string, notes []chartNote, bins int) *chartPreview {
+	p := &chartPreview{title: title, bins: map[dtxdb.Instrument][]int{}}
+	if len(notes) == 0 {
+		return p
+	}
+
+	end := notes[len(notes)-1].at
+	for _, n := range notes {
+		counts, ok := p.bins[n.part]
+		if !ok {
+			counts = make([]int, bins)
+			p.bins[n.part] = counts
+		}
+		bin := bins - 1
+		if end > 0 {
+			bin = int(n.at / end * float64(bins))
+		}
+		if bin >= bins {
+			bin = bins - 1
+		}
+		counts[bin]++
+		if counts[bin] > p.max {
+			p.max = counts[bin]
+		}
+	}
+	for _, part := range dtxdb.Instruments {
+		if _, ok := p.bins[part]; ok {
+			p.parts = append(p.parts, part)
+		}
+	}
+	return p
+}
+
+// previewRect is a bar of a preview, in pixels.
+type previewRect struct {
+	x, y, w, h int
+	color      color.RGBA
+}
+
+// rects lays out the preview as one strip of width by height pixels per
+// instrument, each bin a bar as high as its density relative to the
+// densest bin of the chart.
+func (p *chartPreview) rects(width, height int) []previewRect {
+	var rects []previewRect
+	for row, part := range p.parts {
+		counts := p.bins[part]
+		for i, n := range counts {
+			if n == 0 {
+				continue
+			}
+			x, next := i*width/len(counts), (i+1)*width/len(counts)
+			h := (n*height + p.max - 1) / p.max
+			rects = append(rects, previewRect{x, (row+1)*height - h, next - x, h, previewColors[part]})
+		}
+	}
+	return rects
+}
+
+func hexColor(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+func (p *chartPreview) writeSVG(w io.Writer, width, height int) error {
+	total := height * len(p.parts)
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`+"\n", width, total, width, total)
+	fmt.Fprint(bw, "<title>")
+	xml.EscapeText(bw, []byte(p.title))
+	fmt.Fprint(bw, "</title>\n")
+	fmt.Fprintf(bw, `<rect width="%d" height="%d" fill="%s"/>`+"\n", width, total, hexColor(previewBackground))
+	for _, r := range p.rects(width, height) {
+		fmt.Fprintf(bw, `<rect x="%d" y="%d" width="%d" height="%d" fill="%s"/>`+"\n", r.x, r.y, r.w, r.h, hexColor(r.color))
+	}
+	fmt.Fprintln(bw, "</svg>")
+	return bw.Flush()
+}
+
+func (p *chartPreview) writePNG(w io.Writer, width, height int) error {
+	img := image.NewRGBA(image.Rect(0, 0, width, height*len(p.parts)))
+	draw.Draw(img, img.Bounds(), image.NewUniform(previewBackground), image.Point{}, draw.Src)
+	for _, r := range p.rects(width, height) {
+		draw.Draw(img, image.Rect(r.x, r.y, r.x+r.w, r.y+r.h), image.NewUniform(r.color), image.Point{}, draw.Src)
+	}
+	return png.Encode(w, img)
+}
+
+func writePreviewFile(path, format string, p *chartPreview, width, height int) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if format == "png" {
+		err = p.writePNG(f, width, height)
+	} else {
+		err = p.writeSVG(f, width, height)
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func runPreview(args []string) {
+	flags := newFlagSet("preview")
+	input := inputDBFlag(flags)
+	output := flags.String("o", "previews", "write the previews to `folder`, named by song id")
+	format := flags.String("format", "svg", "image `format`: svg or png")
+	width := flags.Int("width", 400, "`width` of the previews in pixels")
+	height := flags.Int("height", 24, "`height` of the strip of each instrument in pixels")
+	bins := flags.Int("bins", 100, "cut the charts into `n` bins of note density")
+	var filters filterList
+	flags.Var(&filters, "filter", filterUsage)
+	readerFlags(flags)
+	flags.Parse(args)
+	if *format != "svg" && *format != "png" {
+		log.Fatalf("unknown preview format %q, use svg or png\n", *format)
+	}
+	if *width <= 0 || *height <= 0 || *bins <= 0 {
+		log.Fatalln("-width, -height and -bins must be positive")
+	}
+
+	_, scores := readAllScores(*input)
+	logFatalIfError(os.MkdirAll(*output, 0777))
+
+	written, skipped, failed := 0, 0, 0
+	for i := range scores {
+		s := &scores[i]
+		if !filters.match(s) {
+			continue
+		}
+		// Only DTX note data is understood, the other formats name their
+		// channels differently.
+		if s.SongInformation.SongType != dtxdb.DTX {
+			skipped++
+			continue
+		}
+
+		data, err := os.ReadFile(songFilePath(s, chartFileName(s)))
+		if err != nil {
+			failed++
+			continue
+		}
+		p := newChartPreview(s.SongInformation.Title, parseChartNotes(decodeChartText(data)), *bins)
+		if len(p.parts) == 0 {
+			skipped++
+			continue
+		}
+		path := filepath.Join(*output, songID(s)+"."+*format)
+		logFatalIfError(writePreviewFile(path, *format, p, *width, *height))
+		written++
+	}
+	if failed > 0 {
+		log.Printf("%d charts could not be read\n", failed)
+	}
+	infof("%d previews written to %s, %d charts without DTX notes skipped\n", written, *output, skipped)
+}
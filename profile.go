@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/SirChronus/dtxmania-dbdump/dtxdb"
+)
+
+// readTiming is set by dump -profile to time the reads of openSongsDB.
+var readTiming *dtxdb.Timing
+
+const slowestRecords = 10
+
+type recordTiming struct {
+	path     string
+	offset   int64
+	size     int64
+	duration time.Duration
+}
+
+// dumpProfile collects where the time of a dump went.
+type dumpProfile struct {
+	start   time.Time
+	read    dtxdb.Timing
+	encode  time.Duration
+	records int
+	slowest []recordTiming
+}
+
+func newDumpProfile() *dumpProfile {
+	p := &dumpProfile{start: time.Now()}
+	readTiming = &p.read
+	return p
+}
+
+// record keeps r if it is one of the slowest records so far.
+func (p *dumpProfile) record(r recordTiming) {
+	p.records++
+	if len(p.slowest) == slowestRecords && r.duration <= p.slowest[len(p.slowest)-1].duration {
+		return
+	}
+
+	i := sort.Search(len(p.slowest), func(i int) bool { return p.slowest[i].duration < r.duration })
+	p.slowest = append(p.slowest, recordTiming{})
+	copy(p.slowest[i+1:], p.slowest[i:])
+	p.slowest[i] = r
+	if len(p.slowest) > slowestRecords {
+		p.slowest = p.slowest[:slowestRecords]
+	}
+}
+
+func (p *dumpProfile) report(out io.Writer) error {
+	total := time.Since(p.start)
+	percent := func(d time.Duration) float64 {
+		if total == 0 {
+			return 0
+		}
+		return float64(d) / float64(total) * 100
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(w, "SECTION\tTIME\t%%\t\n")
+	sections := []struct {
+		name string
+		d    time.Duration
+	}{
+		{"I/O", p.read.IO},
+		{"string decode", p.read.Strings},
+		{"other decode", p.read.Records - p.read.Strings},
+		{"encode", p.encode},
+		{"other", total - p.read.IO - p.read.Records - p.encode},
+		{"total", total},
+	}
+	for _, s := range sections {
+		fmt.Fprintf(w, "%s\t%v\t%.1f\t\n", s.name, s.d.Round(time.Microsecond), percent(s.d))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "\nslowest of %d records:\n", p.records)
+	w = tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TIME\tOFFSET\tBYTES\tPATH")
+	for _, r := range p.slowest {
+		fmt.Fprintf(w, "%v\t%d\t%d\t%s\n", r.duration.Round(time.Microsecond), r.offset, r.size, r.path)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/SirChronus/dtxmania-dbdump/dtxdb"
+)
+
+// skillLogPath is where snapshot appends the high skills that changed, one
+// "timestamp\tsong id\tinstrument\tskill" line each. Snapshots are pruned,
+// the log keeps the whole progress at a fraction of their size.
+var skillLogPath = filepath.Join(".dbdump", "skills.log")
+
+type skillEntry struct {
+	taken time.Time
+	song  string
+	part  dtxdb.Instrument
+	skill float64
+}
+
+func readSkillLog() ([]skillEntry, error) {
+	f, err := os.Open(skillLogPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []skillEntry
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Split(scanner.Text(), "\t")
+		if len(fields) != 4 {
+			return nil, fmt.Errorf("%s:%d: expected 4 fields", skillLogPath, line)
+		}
+		var e skillEntry
+		var err error
+		if e.taken, err = time.Parse(snapshotLayout, fields[0]); err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", skillLogPath, line, err)
+		}
+		if e.part, err = dtxdb.ParseInstrument(fields[2]); err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", skillLogPath, line, err)
+		}
+		if e.skill, err = strconv.ParseFloat(fields[3], 64); err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", skillLogPath, line, err)
+		}
+		e.song = fields[1]
+		entries = append(entries, e)
+	}
+	return entries, scanner.Err()
+}
+
+type songPart struct {
+	song string
+	part dtxdb.Instrument
+}
+
+// latestSkills returns the last skill logged of every song and instrument.
+func latestSkills(entries []skillEntry) map[songPart]float64 {
+	latest := map[songPart]float64{}
+	for _, e := range entries {
+		latest[songPart{e.song, e.part}] = e.skill
+	}
+	return latest
+}
+
+// appendSkills writes the skills of scores that differ from latest to w
+// and updates latest. Charts never played are left out.
+func appendSkills(w io.Writer, latest map[songPart]float64, taken time.Time, scores []score) (int, error) {
+	n := 0
+	for i := range scores {
+		s := &scores[i]
+		id := songID(s)
+		for _, part := range dtxdb.Instruments {
+			skill := s.SongInformation.HighSkill.Get(part)
+			last, ok := latest[songPart{id, part}]
+			if skill == last && (ok || skill == 0) {
+				continue
+			}
+			latest[songPart{id, part}] = skill
+			line := []string{taken.UTC().Format(snapshotLayout), id, part.String(), strconv.FormatFloat(skill, 'f', -1, 64)}
+			if _, err := fmt.Fprintln(w, strings.Join(line, "\t")); err != nil {
+				return n, err
+			}
+			n++
+		}
+	}
+	return n, nil
+}
+
+// logSkills appends the skills of a snapshot to the skill log. Once, when
+// there is no log yet, the older snapshots still kept are logged first.
+func logSkills(taken time.Time, scores []score) {
+	entries, err := readSkillLog()
+	backfill := os.IsNotExist(err)
+	if !backfill {
+		logFatalIfError(err)
+	}
+
+	f, err := os.OpenFile(skillLogPath, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0666)
+	logFatalIfError(err)
+	defer f.Close()
+	w := bufio.NewWriter(f)
+
+	latest := latestSkills(entries)
+	if backfill {
+		for _, snap := range listSnapshots() {
+			if !snap.taken.Before(taken) {
+				continue
+			}
+			_, err := appendSkills(w, latest, snap.taken, readSnapshot(snap.path))
+			logFatalIfError(err)
+		}
+	}
+	n, err := appendSkills(w, latest, taken, scores)
+	logFatalIfError(err)
+	logFatalIfError(w.Flush())
+	infof("%d new high skills logged in %s\n", n, skillLogPath)
+}
+
+// skillPoint is a value of a progress chart.
+type skillPoint struct {
+	taken time.Time
+	value float64
+	label string
+}
+
+func printSkillChart(w io.Writer, title string, points []skillPoint) {
+	fmt.Fprintln(w, title)
+	max := 0.0
+	for _, p := range points {
+		if p.value > max {
+			max = p.value
+		}
+	}
+	for _, p := range points {
+		width := 0
+		if max > 0 {
+			width = int(p.value / max * 40)
+		}
+		bar := strings.Repeat("█", width) + strings.Repeat(" ", 40-width)
+		fmt.Fprintf(w, "  %s  %6.2f  %s %s\n", p.taken.Local().Format("2006-01-02 15:04"), p.value, bar, p.label)
+	}
+}
+
+// songProgress charts every change of the skills of one song.
+func songProgress(w io.Writer, entries []skillEntry, id string) bool {
+	found := false
+	for _, part := range dtxdb.Instruments {
+		var points []skillPoint
+		for _, e := range entries {
+			if e.song == id && e.part == part {
+				points = append(points, skillPoint{e.taken, e.skill, ""})
+			}
+		}
+		if len(points) > 0 {
+			printSkillChart(w, part.String(), points)
+			found = true
+		}
+	}
+	return found
+}
+
+// overallProgress charts the mean skill of the charts played of every
+// instrument at every point of the log.
+func overallProgress(w io.Writer, entries []skillEntry) {
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].taken.Before(entries[j].taken)
+	})
+	for _, part := range dtxdb.Instruments {
+		skills := map[string]float64{}
+		var points []skillPoint
+		for i, e := range entries {
+			if e.part == part {
+				skills[e.song] = e.skill
+			}
+			if i+1 < len(entries) && entries[i+1].taken.Equal(e.taken) {
+				continue
+			}
+			played, sum := 0, 0.0
+			for _, sk := range skills {
+				if sk > 0 {
+					played++
+					sum += sk
+				}
+			}
+			if played > 0 {
+				points = append(points, skillPoint{e.taken, sum / float64(played), fmt.Sprintf("%d charts", played)})
+			}
+		}
+		if len(points) > 0 {
+			printSkillChart(w, part.String(), points)
+		}
+	}
+}
+
+func runProgress(args []string) {
+	flags := newFlagSet("progress")
+	song := flags.String("song", "", "chart the high skills of the song with `id`, as exported by playdata or serve")
+	overall := flags.Bool("overall", false, "chart the mean high skill of the charts played of every instrument")
+	flags.Parse(args)
+	if (*song == "") == !*overall {
+		log.Fatalln("progress needs either -song or -overall")
+	}
+
+	entries, err := readSkillLog()
+	if os.IsNotExist(err) {
+		log.Fatalf("no %s yet, it is written by dbdump snapshot\n", skillLogPath)
+	}
+	logFatalIfError(err)
+
+	w := bufio.NewWriter(os.Stdout)
+	if *overall {
+		overallProgress(w, entries)
+	} else if !songProgress(w, entries, *song) {
+		log.Fatalf("no skills logged for song %s\n", *song)
+	}
+	logFatalIfError(w.Flush())
+}
@@ -0,0 +1,44 @@
+package main
+
+import "time"
+
+// progressLog logs how far dump got every so often, so dumps of huge
+// libraries do not run silently for minutes.
+type progressLog struct {
+	every time.Duration
+	// total is the size of the databases, 0 if one of them has no known
+	// size.
+	total int64
+	next  time.Time
+}
+
+// newProgressLog returns a progressLog logging every interval, or nil if
+// every is 0.
+func newProgressLog(every time.Duration, total int64) *progressLog {
+	if every <= 0 {
+		return nil
+	}
+	return &progressLog{every: every, total: total, next: time.Now().Add(every)}
+}
+
+// update logs the number of records and bytes read once the interval has
+// passed since the last line.
+func (p *progressLog) update(records int, read int64) {
+	if p == nil {
+		return
+	}
+	now := time.Now()
+	if now.Before(p.next) {
+		return
+	}
+	p.next = now.Add(p.every)
+	if p.total > 0 {
+		infof("%d records, %.1f of %.1f MiB read (%.0f%%)\n", records, mebibytes(read), mebibytes(p.total), 100*float64(read)/float64(p.total))
+	} else {
+		infof("%d records, %.1f MiB read\n", records, mebibytes(read))
+	}
+}
+
+func mebibytes(n int64) float64 {
+	return float64(n) / (1 << 20)
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"strings"
+
+	"github.com/SirChronus/dtxmania-dbdump/dtxdb"
+)
+
+// Protocol Buffers dumps are a stream of Song messages, each preceded by
+// its size as a varint like writeDelimitedTo and parseDelimitedFrom of the
+// protobuf libraries expect. The proto3 schema printed by schema -format
+// proto is derived from the XML tags of score: a message per struct, its
+// fields numbered in dump order and named like the Avro fields. Song types
+// are an enum, dates strings as in the dump.
+
+func protoType(t reflect.Type) string {
+	if t == songTypeType {
+		return "SongType"
+	}
+	if t == dateType {
+		return "string"
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "bool"
+	case reflect.Int32:
+		return "int32"
+	case reflect.Int64:
+		return "int64"
+	case reflect.Float64:
+		return "double"
+	case reflect.Struct:
+		return schemaTypeName(t)
+	}
+	panic("protobuf: unsupported type " + t.String())
+}
+
+// writeProtoMessages writes the message of t and then those of the
+// structs it uses that are not defined yet.
+func writeProtoMessages(b *strings.Builder, t reflect.Type, defined map[reflect.Type]bool) {
+	defined[t] = true
+	fields := schemaFields(t)
+	fmt.Fprintf(b, "\nmessage %s {\n", schemaTypeName(t))
+	for i, f := range fields {
+		fmt.Fprintf(b, "  %s %s = %d;\n", protoType(f.Type), avroName(xmlFieldName(f)), i+1)
+	}
+	b.WriteString("}\n")
+	for _, f := range fields {
+		if nestedType(f.Type) && !defined[f.Type] {
+			writeProtoMessages(b, f.Type, defined)
+		}
+	}
+}
+
+// protoSchema returns the .proto file describing the messages of a
+// protobuf dump.
+func protoSchema() string {
+	var b strings.Builder
+	b.WriteString("// Schema of dbdump dump -format protobuf, a stream of length-delimited\n// Song messages.\n")
+	b.WriteString("syntax = \"proto3\";\n\npackage dtxmania;\n")
+	writeProtoMessages(&b, reflect.TypeOf(score{}), map[reflect.Type]bool{})
+	b.WriteString("\nenum SongType {\n")
+	for t := dtxdb.SongType(0); !strings.Contains(t.String(), "("); t++ {
+		fmt.Fprintf(&b, "  %s = %d;\n", t, t)
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+func appendProtoVarint(b []byte, v uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	return append(b, buf[:binary.PutUvarint(buf[:], v)]...)
+}
+
+// Protocol Buffers wire types.
+const (
+	protoVarint  = 0
+	protoFixed64 = 1
+	protoBytes   = 2
+)
+
+func appendProtoTag(b []byte, number, wireType int) []byte {
+	return appendProtoVarint(b, uint64(number)<<3|uint64(wireType))
+}
+
+// appendProtoMessage appends the fields of the struct v. Like proto3 does,
+// scalar fields holding their zero value are left out.
+func appendProtoMessage(b []byte, v reflect.Value) []byte {
+	for i, f := range schemaValues(v) {
+		number := i + 1
+		if d, ok := f.Interface().(dtxdb.Date); ok {
+			f = reflect.ValueOf(d.String())
+		}
+		switch f.Kind() {
+		case reflect.Struct:
+			sub := appendProtoMessage(nil, f)
+			b = appendProtoTag(b, number, protoBytes)
+			b = appendProtoVarint(b, uint64(len(sub)))
+			b = append(b, sub...)
+		case reflect.String:
+			if f.Len() > 0 {
+				b = appendProtoTag(b, number, protoBytes)
+				b = appendProtoVarint(b, uint64(f.Len()))
+				b = append(b, f.String()...)
+			}
+		case reflect.Bool:
+			if f.Bool() {
+				b = appendProtoTag(b, number, protoVarint)
+				b = append(b, 1)
+			}
+		case reflect.Int32, reflect.Int64:
+			// Negative values take ten bytes, as int32 is sign extended.
+			if f.Int() != 0 {
+				b = appendProtoTag(b, number, protoVarint)
+				b = appendProtoVarint(b, uint64(f.Int()))
+			}
+		case reflect.Float64:
+			if bits := math.Float64bits(f.Float()); bits != 0 {
+				b = appendProtoTag(b, number, protoFixed64)
+				b = appendLittleUint64(b, bits)
+			}
+		default:
+			panic("protobuf: unsupported type " + f.Type().String())
+		}
+	}
+	return b
+}
+
+// protobufWriter writes every record as a length-delimited Song message.
+type protobufWriter struct {
+	w   io.Writer
+	buf []byte
+}
+
+func newProtobufWriter(w io.Writer) (recordWriter, error) {
+	return &protobufWriter{w: w}, nil
+}
+
+func (p *protobufWriter) write(s *score) error {
+	msg := appendProtoMessage(nil, reflect.ValueOf(s).Elem())
+	p.buf = appendProtoVarint(p.buf[:0], uint64(len(msg)))
+	p.buf = append(p.buf, msg...)
+	_, err := p.w.Write(p.buf)
+	return err
+}
+
+// buffered is always 0, every message is written at once.
+func (p *protobufWriter) buffered() int {
+	return 0
+}
+
+func (p *protobufWriter) close() error {
+	return nil
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"log"
+	"os"
+)
+
+// chartMissing tells whether the chart file of s is gone.
+func chartMissing(s *score) bool {
+	_, err := os.Stat(s.FileInformation.AbsoluteFilePath)
+	return os.IsNotExist(err)
+}
+
+func runPrune(args []string) {
+	flags := newFlagSet("prune")
+	input := inputDBFlag(flags)
+	output := outputDBFlag(flags)
+	var filters filterList
+	flags.Var(&filters, "filter", "remove songs matching `field=value` or the preset @name, may be repeated; operators are = != ~ !~ ^= < <= > >=")
+	missing := flags.Bool("missing", false, "remove songs whose chart file is missing")
+	dryRun := flags.Bool("n", false, "only report the songs that would be removed")
+	readerFlags(flags)
+	flags.Parse(args)
+
+	// Like edit, pruning every record takes a filter matching all of them.
+	if len(filters) == 0 && !*missing {
+		log.Fatalln("no songs selected, give at least one -filter or -missing")
+	}
+
+	versionString, scores := readAllScores(*input)
+	kept := scores[:0]
+	removed := 0
+	for _, s := range scores {
+		if filters.match(&s) && (!*missing || chartMissing(&s)) {
+			infof("removed: %s\n", s.FileInformation.AbsoluteFilePath)
+			removed++
+			continue
+		}
+		kept = append(kept, s)
+	}
+
+	infof("%d records removed, %d kept\n", removed, len(kept))
+	if *missing && len(kept) == 0 && removed > 0 {
+		log.Fatalln("every chart is missing, is the song folder where songs.db has it? nothing written")
+	}
+	if *dryRun {
+		return
+	}
+
+	writeSongsDB(*output, versionString, kept)
+	infof("written %s\n", *output)
+}
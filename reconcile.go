@@ -0,0 +1,188 @@
+package main
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/SirChronus/dtxmania-dbdump/dtxdb"
+)
+
+// fileKey identifies a chart file independently of where it is stored.
+// songs.db keeps no content hash of the charts, so the file name and size
+// recorded in the database are what moved files are matched on.
+type fileKey struct {
+	name string
+	size int64
+}
+
+func fileKeyOf(path string, size int64) fileKey {
+	// Paths in songs.db always use Windows separators, which filepath.Base
+	// does not know about on other platforms.
+	name := path[strings.LastIndexAny(path, `\/`)+1:]
+	return fileKey{strings.ToLower(name), size}
+}
+
+// indexChartFiles returns every file below roots, keyed by name and size.
+func indexChartFiles(roots []string) map[fileKey][]string {
+	index := make(map[fileKey][]string)
+	for _, root := range roots {
+		err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				log.Printf("skipping %s: %v\n", path, err)
+				return nil
+			}
+			if info.Mode().IsRegular() {
+				abs, err := filepath.Abs(path)
+				if err != nil {
+					return err
+				}
+				key := fileKeyOf(abs, info.Size())
+				index[key] = append(index[key], abs)
+			}
+			return nil
+		})
+		logFatalIfError(err)
+	}
+
+	return index
+}
+
+// pickMovedFile selects the file a dead record most likely moved to. When
+// several files share a name and size, only one with the modification time
+// recorded in songs.db is accepted.
+func pickMovedFile(s *score, candidates []string) (string, bool) {
+	if len(candidates) == 1 {
+		return candidates[0], true
+	}
+
+	recorded := s.FileInformation.LastModified.Ticks()
+
+	var match string
+	for _, c := range candidates {
+		info, err := os.Stat(c)
+		if err != nil {
+			continue
+		}
+		modified := fileDate(info.ModTime()).Ticks()
+		if modified/dtxdb.TicksPerSecond != recorded/dtxdb.TicksPerSecond {
+			continue
+		}
+		if match != "" {
+			return "", false
+		}
+		match = c
+	}
+
+	return match, match != ""
+}
+
+func runReconcile(args []string) {
+	flags := newFlagSet("reconcile")
+	input := inputDBFlag(flags)
+	var roots stringList
+	flags.Var(&roots, "root", "song `folder` to search for moved charts, may be repeated (default .)")
+	output := outputDBFlag(flags)
+	dryRun := flags.Bool("n", false, "only report what would be rewritten")
+	readerFlags(flags)
+	flags.Parse(args)
+	if len(roots) == 0 {
+		roots = stringList{"."}
+	}
+
+	versionString, scores := readAllScores(*input)
+	index := indexChartFiles(roots)
+
+	moved, missing := 0, 0
+	for i := range scores {
+		s := &scores[i]
+		if _, err := os.Stat(s.FileInformation.AbsoluteFilePath); !os.IsNotExist(err) {
+			continue
+		}
+
+		key := fileKeyOf(s.FileInformation.AbsoluteFilePath, s.FileInformation.FileSize)
+		path, ok := pickMovedFile(s, index[key])
+		if !ok {
+			if len(index[key]) > 1 {
+				log.Printf("ambiguous: %s matches %d files\n", s.FileInformation.AbsoluteFilePath, len(index[key]))
+			} else {
+				log.Printf("missing: %s\n", s.FileInformation.AbsoluteFilePath)
+			}
+			missing++
+			continue
+		}
+
+		infof("moved: %s -> %s\n", s.FileInformation.AbsoluteFilePath, path)
+		s.FileInformation.AbsoluteFilePath = path
+		s.FileInformation.AbsoluteFolderPath = filepath.Dir(path) + string(filepath.Separator)
+		moved++
+	}
+
+	infof("%d records moved, %d still missing\n", moved, missing)
+	if *dryRun {
+		return
+	}
+
+	writeSongsDB(*output, versionString, scores)
+	infof("written %s\n", *output)
+}
+
+// rewritePathPrefix returns path with the folder from replaced by to, or
+// false if path is not below from. Folders are compared ignoring case and
+// the kind of slashes, like Windows does.
+func rewritePathPrefix(path, from, to string) (string, bool) {
+	if len(path) < len(from) || !strings.EqualFold(strings.ReplaceAll(path[:len(from)], "/", `\`), from) {
+		return "", false
+	}
+	rest := path[len(from):]
+	if rest != "" && rest[0] != '\\' && rest[0] != '/' {
+		// D:\DTX2 is not below D:\DTX.
+		return "", false
+	}
+	return to + rest, true
+}
+
+func runRewritePaths(args []string) {
+	flags := newFlagSet("rewrite-paths")
+	input := inputDBFlag(flags)
+	from := flags.String("from", "", "the `folder` the songs were moved from, e.g. D:\\DTX")
+	to := flags.String("to", "", "the `folder` the songs are in now, e.g. E:\\Games\\DTX")
+	output := outputDBFlag(flags)
+	dryRun := flags.Bool("n", false, "only report how many paths would be rewritten")
+	readerFlags(flags)
+	flags.Parse(args)
+	if *from == "" || *to == "" {
+		log.Fatalln("rewrite-paths needs -from and -to")
+	}
+	fromPrefix := strings.TrimRight(strings.ReplaceAll(*from, "/", `\`), `\`)
+	toPrefix := strings.TrimRight(strings.ReplaceAll(*to, "/", `\`), `\`)
+	if fromPrefix == "" {
+		log.Fatalln("-from names no folder")
+	}
+
+	versionString, scores := readAllScores(*input)
+	rewritten := 0
+	for i := range scores {
+		f := &scores[i].FileInformation
+		file, fileOK := rewritePathPrefix(f.AbsoluteFilePath, fromPrefix, toPrefix)
+		folder, folderOK := rewritePathPrefix(f.AbsoluteFolderPath, fromPrefix, toPrefix)
+		if fileOK {
+			f.AbsoluteFilePath = file
+		}
+		if folderOK {
+			f.AbsoluteFolderPath = folder
+		}
+		if fileOK || folderOK {
+			rewritten++
+		}
+	}
+
+	infof("%d of %d records rewritten\n", rewritten, len(scores))
+	if *dryRun {
+		return
+	}
+
+	writeSongsDB(*output, versionString, scores)
+	infof("written %s\n", *output)
+}
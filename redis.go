@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/SirChronus/dtxmania-dbdump/dtxdb"
+)
+
+// The Redis export is written as raw RESP commands, either straight to a
+// server or to a file for redis-cli --pipe, so no client library is needed.
+// Keys below the prefix:
+//
+//	song:<id>            hash of every field of the dump
+//	level:<instrument>   sorted set of song ids by level
+//	skill:<instrument>   sorted set of song ids by high skill
+//	artist:<artist>      set of song ids, artist in lower case
+//	title:<title>        set of song ids, title in lower case
+//	keys                 set of every key above, used to remove them when
+//	                     the export is run again
+
+// clearKeysScript deletes the keys listed in the set KEYS[1] and the set.
+const clearKeysScript = `for _, k in ipairs(redis.call('SMEMBERS', KEYS[1])) do redis.call('DEL', k) end
+redis.call('DEL', KEYS[1])
+return 0`
+
+// respWriter writes commands in the RESP protocol and counts them.
+type respWriter struct {
+	w        *bufio.Writer
+	commands int
+}
+
+func (r *respWriter) command(args ...string) {
+	fmt.Fprintf(r.w, "*%d\r\n", len(args))
+	for _, a := range args {
+		fmt.Fprintf(r.w, "$%d\r\n%s\r\n", len(a), a)
+	}
+	r.commands++
+}
+
+// redisSearchKey normalises titles and artists for the lookup sets.
+func redisSearchKey(s string) string {
+	return strings.ToLower(strings.Join(strings.Fields(s), " "))
+}
+
+func exportToRedis(r *respWriter, prefix string, scores []score) {
+	keysKey := prefix + "keys"
+	r.command("EVAL", clearKeysScript, "1", keysKey)
+
+	written := map[string]bool{}
+	track := func(key string) {
+		if !written[key] {
+			written[key] = true
+			r.command("SADD", keysKey, key)
+		}
+	}
+
+	for i := range scores {
+		s := &scores[i]
+		id := songID(s)
+
+		key := prefix + "song:" + id
+		args := []string{"HSET", key, "id", id}
+		for _, f := range scoreFields(s) {
+			args = append(args, f.name, f.String())
+		}
+		r.command(args...)
+		track(key)
+
+		for _, part := range dtxdb.Instruments {
+			if s.SongInformation.Level.Get(part) == 0 {
+				continue
+			}
+			levelKey := prefix + "level:" + part.String()
+			level := strconv.FormatFloat(s.SongInformation.CombinedLevel(part), 'f', 2, 64)
+			r.command("ZADD", levelKey, level, id)
+			track(levelKey)
+
+			skillKey := prefix + "skill:" + part.String()
+			skill := strconv.FormatFloat(s.SongInformation.HighSkill.Get(part), 'f', -1, 64)
+			r.command("ZADD", skillKey, skill, id)
+			track(skillKey)
+		}
+
+		for _, lookup := range []struct{ kind, value string }{
+			{"artist", s.SongInformation.Artist},
+			{"title", s.SongInformation.Title},
+		} {
+			if v := redisSearchKey(lookup.value); v != "" {
+				setKey := prefix + lookup.kind + ":" + v
+				r.command("SADD", setKey, id)
+				track(setKey)
+			}
+		}
+	}
+}
+
+// readRedisReplies reads n replies and returns the first error reply.
+func readRedisReplies(r *bufio.Reader, n int) error {
+	var first error
+	for i := 0; i < n; i++ {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			return errors.New("redis: empty reply")
+		}
+
+		switch line[0] {
+		case '-':
+			if first == nil {
+				first = errors.New("redis: " + line[1:])
+			}
+		case '$':
+			// Bulk string, only returned here for nil or short values.
+			if size, _ := strconv.Atoi(line[1:]); size >= 0 {
+				if _, err := io.CopyN(io.Discard, r, int64(size)+2); err != nil {
+					return err
+				}
+			}
+		case '+', ':':
+		default:
+			return fmt.Errorf("redis: unexpected reply %q", line)
+		}
+	}
+	return first
+}
+
+func runRedis(args []string) {
+	flags := newFlagSet("redis")
+	input := inputDBFlag(flags)
+	addr := flags.String("addr", "localhost:6379", "`address` of the Redis server")
+	password := flags.String("password", "", "authenticate with `password`")
+	prefix := flags.String("prefix", "dtx:", "`prefix` of every key written")
+	output := flags.String("o", "", "write the commands to `file` for redis-cli --pipe instead of sending them")
+	readerFlags(flags)
+	flags.Parse(args)
+
+	_, scores := readAllScores(*input)
+
+	if *output != "" {
+		var err error
+		outFile, err = os.Create(*output)
+		logFatalIfError(err)
+		defer outFile.Close()
+		r := &respWriter{w: bufio.NewWriter(outFile)}
+		exportToRedis(r, *prefix, scores)
+		logFatalIfError(r.w.Flush())
+		infof("%d songs written to %s as %d commands\n", len(scores), *output, r.commands)
+		return
+	}
+
+	conn, err := net.DialTimeout("tcp", *addr, 10*time.Second)
+	logFatalIfError(err)
+	defer conn.Close()
+
+	var commands bytes.Buffer
+	r := &respWriter{w: bufio.NewWriter(&commands)}
+	if *password != "" {
+		r.command("AUTH", *password)
+	}
+	exportToRedis(r, *prefix, scores)
+	logFatalIfError(r.w.Flush())
+
+	// Replies are read while the commands are sent, otherwise both sides
+	// end up waiting for the other to read once the socket buffers fill.
+	replies := make(chan error, 1)
+	go func() {
+		replies <- readRedisReplies(bufio.NewReader(conn), r.commands)
+	}()
+	_, err = commands.WriteTo(conn)
+	logFatalIfError(err)
+	logFatalIfError(<-replies)
+
+	infof("%d songs written to %s\n", len(scores), *addr)
+}
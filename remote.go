@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+func isRemoteDB(path string) bool {
+	return strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://")
+}
+
+const (
+	minRangeBlock = 64 * 1024
+	maxRangeBlock = 4 * 1024 * 1024
+)
+
+// httpRangeReader reads a remote database with HTTP range requests, so only
+// the part of the file that is actually parsed is downloaded. Blocks grow
+// with every request to keep the number of requests low when the whole
+// file is read after all. Servers ignoring ranges are read as a plain
+// download.
+type httpRangeReader struct {
+	url       string
+	client    *http.Client
+	offset    int64
+	blockSize int64
+	block     []byte
+	stream    io.ReadCloser
+	eof       bool
+}
+
+func openRemoteDB(url string) *httpRangeReader {
+	return &httpRangeReader{url: url, client: http.DefaultClient, blockSize: minRangeBlock}
+}
+
+func (r *httpRangeReader) Read(p []byte) (int, error) {
+	if r.stream != nil {
+		n, err := r.stream.Read(p)
+		r.offset += int64(n)
+		return n, err
+	}
+
+	if len(r.block) == 0 {
+		if r.eof {
+			return 0, io.EOF
+		}
+		if err := r.fetch(); err != nil {
+			return 0, err
+		}
+		if r.stream != nil {
+			return r.Read(p)
+		}
+		if len(r.block) == 0 {
+			return 0, io.EOF
+		}
+	}
+
+	n := copy(p, r.block)
+	r.block = r.block[n:]
+	r.offset += int64(n)
+	return n, nil
+}
+
+func (r *httpRangeReader) fetch() error {
+	req, err := http.NewRequest(http.MethodGet, r.url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", r.offset, r.offset+r.blockSize-1))
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		defer resp.Body.Close()
+		r.block, err = io.ReadAll(resp.Body)
+		if err != nil {
+			return err
+		}
+		if int64(len(r.block)) < r.blockSize {
+			r.eof = true
+		}
+		if r.blockSize < maxRangeBlock {
+			r.blockSize *= 2
+		}
+		return nil
+
+	case http.StatusRequestedRangeNotSatisfiable:
+		resp.Body.Close()
+		r.eof = true
+		return nil
+
+	case http.StatusOK:
+		// No range support, download the file and skip what was read.
+		if _, err := io.CopyN(io.Discard, resp.Body, r.offset); err != nil {
+			resp.Body.Close()
+			return err
+		}
+		r.stream = resp.Body
+		return nil
+	}
+
+	resp.Body.Close()
+	return fmt.Errorf("%s: %s", r.url, resp.Status)
+}
+
+func (r *httpRangeReader) Close() error {
+	if r.stream != nil {
+		return r.stream.Close()
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"runtime/debug"
+	"strings"
+
+	"github.com/SirChronus/dtxmania-dbdump/dtxdb"
+)
+
+// parseResult is the outcome of reading a whole database the way dump does.
+type parseResult struct {
+	kind    string // "ok", "error" or "panic"
+	message string
+	stack   []byte
+	records int
+}
+
+// signature identifies a failure independently of the offsets and lengths
+// in its message, which change while the input is minimised.
+func (r parseResult) signature() string {
+	digitless := strings.Map(func(c rune) rune {
+		if c >= '0' && c <= '9' {
+			return -1
+		}
+		return c
+	}, r.message)
+	return r.kind + ": " + digitless
+}
+
+// parseCrashFile decodes data and encodes every record to XML, recovering
+// from panics so they can be reported.
+func parseCrashFile(data []byte) (result parseResult) {
+	defer func() {
+		if v := recover(); v != nil {
+			result.kind = "panic"
+			result.message = fmt.Sprint(v)
+			result.stack = debug.Stack()
+		}
+	}()
+
+	r, err := dtxdb.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return parseResult{kind: "error", message: err.Error()}
+	}
+	for {
+		next, err := r.Next()
+		if err == io.EOF {
+			return parseResult{kind: "ok", records: result.records}
+		}
+		if err != nil {
+			return parseResult{kind: "error", message: err.Error(), records: result.records}
+		}
+		if _, err := xml.Marshal(score{Score: *next}); err != nil {
+			return parseResult{kind: "error", message: err.Error(), records: result.records}
+		}
+		result.records++
+	}
+}
+
+// minimizeCrashFile shrinks data while it keeps failing with signature:
+// first by truncating it, then by removing ever smaller chunks.
+func minimizeCrashFile(data []byte, signature string) []byte {
+	fails := func(d []byte) bool {
+		return parseCrashFile(d).signature() == signature
+	}
+
+	lo, hi := 0, len(data)
+	for lo < hi {
+		mid := (lo + hi) / 2
+		if fails(data[:mid]) {
+			hi = mid
+		} else {
+			lo = mid + 1
+		}
+	}
+	data = data[:hi]
+
+	for chunk := len(data) / 2; chunk > 0; chunk /= 2 {
+		for start := 0; start+chunk <= len(data); {
+			candidate := append(append([]byte{}, data[:start]...), data[start+chunk:]...)
+			if fails(candidate) {
+				data = candidate
+				continue
+			}
+			start += chunk
+		}
+	}
+
+	return data
+}
+
+func runRepro(args []string) {
+	flags := newFlagSet("repro")
+	output := flags.String("o", "", "write the minimised input to `file` (default <crashfile>.min)")
+	noMinimize := flags.Bool("n", false, "only report the failure, do not minimise the input")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: %s repro [flags] crashfile\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	crashFile := flags.Arg(0)
+	if *output == "" {
+		*output = crashFile + ".min"
+	}
+
+	data, err := os.ReadFile(crashFile)
+	logFatalIfError(err)
+
+	result := parseCrashFile(data)
+	switch result.kind {
+	case "ok":
+		log.Printf("%s: %d records read without failure\n", crashFile, result.records)
+		return
+	case "panic":
+		log.Printf("%s: panic after %d records: %s\n%s", crashFile, result.records, result.message, result.stack)
+	default:
+		log.Printf("%s: error after %d records: %s\n", crashFile, result.records, result.message)
+	}
+	if *noMinimize {
+		return
+	}
+
+	minimized := minimizeCrashFile(data, result.signature())
+	logFatalIfError(os.WriteFile(*output, minimized, 0666))
+	infof("minimised %d bytes to %d, written %s\n", len(data), len(minimized), *output)
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/SirChronus/dtxmania-dbdump/dtxdb"
+)
+
+// resetPart clears the play data of part in s, as if the chart was never
+// played on it. It tells whether there was any.
+func resetPart(s *score, part dtxdb.Instrument) bool {
+	info := &s.SongInformation
+	played := info.BestRank.Get(part) != dtxdb.NoRank || info.HighSkill.Get(part) != 0 ||
+		info.FullCombo.Get(part) || info.ScoreExists.Get(part) || info.NbPerformance.Get(part) != 0
+	info.BestRank.Set(part, dtxdb.NoRank)
+	info.HighSkill.Set(part, 0)
+	info.FullCombo.Set(part, false)
+	info.ScoreExists.Set(part, false)
+	info.NbPerformance.Set(part, 0)
+	return played
+}
+
+// resetHistory removes the lines of the performance history naming one of
+// parts, like "13/10/04 Drums:Cleared", moving the others up. Every line
+// goes when all parts are reset, since some lines name no instrument.
+func resetHistory(h *dtxdb.PerformanceHistory, parts []dtxdb.Instrument) bool {
+	lines := []*string{&h.First, &h.Second, &h.Third, &h.Fourth, &h.Fifth}
+	var kept []string
+	for _, line := range lines {
+		if *line == "" {
+			continue
+		}
+		drop := len(parts) == len(dtxdb.Instruments)
+		for _, part := range parts {
+			drop = drop || strings.Contains(strings.ToLower(*line), part.String()+":")
+		}
+		if !drop {
+			kept = append(kept, *line)
+		}
+	}
+	changed := false
+	for i, line := range lines {
+		v := ""
+		if i < len(kept) {
+			v = kept[i]
+		}
+		changed = changed || *line != v
+		*line = v
+	}
+	return changed
+}
+
+func runResetScores(args []string) {
+	flags := newFlagSet("reset-scores")
+	input := inputDBFlag(flags)
+	output := outputDBFlag(flags)
+	var partNames stringList
+	flags.Var(&partNames, "part", "only reset the scores of `instrument`: drums, guitar or bass, may be repeated (default all)")
+	var filters filterList
+	flags.Var(&filters, "filter", filterUsage)
+	dryRun := flags.Bool("n", false, "only report how many songs would be reset")
+	readerFlags(flags)
+	flags.Parse(args)
+
+	parts := dtxdb.Instruments
+	if len(partNames) > 0 {
+		parts = nil
+		for _, name := range partNames {
+			part, err := dtxdb.ParseInstrument(name)
+			logFatalIfError(err)
+			parts = append(parts, part)
+		}
+	}
+
+	versionString, scores := readAllScores(*input)
+	reset := 0
+	for i := range scores {
+		s := &scores[i]
+		if !filters.match(s) {
+			continue
+		}
+		changed := resetHistory(&s.SongInformation.PerformanceHistory, parts)
+		for _, part := range parts {
+			changed = resetPart(s, part) || changed
+		}
+		if changed {
+			reset++
+		}
+	}
+
+	infof("scores of %d songs reset\n", reset)
+	if *dryRun {
+		return
+	}
+
+	writeSongsDB(*output, versionString, scores)
+	infof("written %s\n", *output)
+}
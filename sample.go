@@ -0,0 +1,91 @@
+package main
+
+import (
+	"math/rand"
+	"sort"
+	"time"
+)
+
+// sampledScore is a record kept by a recordSampler along with its position
+// in the database.
+type sampledScore struct {
+	index int
+	s     score
+}
+
+// recordSampler keeps n records of a database read once, without knowing
+// how many records there are. Random samples are reservoir sampled, even
+// ones keep every stride-th record and double the stride whenever twice
+// the number of records wanted were kept.
+type recordSampler struct {
+	n      int
+	even   bool
+	rng    *rand.Rand
+	seen   int
+	stride int
+	kept   []sampledScore
+}
+
+func newRecordSampler(n int, even bool) *recordSampler {
+	return &recordSampler{n: n, even: even, rng: rand.New(rand.NewSource(time.Now().UnixNano())), stride: 1}
+}
+
+func (r *recordSampler) add(s *score) {
+	i := r.seen
+	r.seen++
+
+	if r.even {
+		if i%r.stride != 0 {
+			return
+		}
+		r.kept = append(r.kept, sampledScore{i, *s})
+		if len(r.kept) == 2*r.n {
+			half := r.kept[:0]
+			for j := 0; j < len(r.kept); j += 2 {
+				half = append(half, r.kept[j])
+			}
+			r.kept = half
+			r.stride *= 2
+		}
+		return
+	}
+
+	if len(r.kept) < r.n {
+		r.kept = append(r.kept, sampledScore{i, *s})
+	} else if j := r.rng.Intn(i + 1); j < r.n {
+		r.kept[j] = sampledScore{i, *s}
+	}
+}
+
+// records returns the sample in database order.
+func (r *recordSampler) records() []score {
+	kept := r.kept
+	if len(kept) > r.n {
+		// Only even samples keep more, up to twice as many, every stride-th
+		// record. Pick the ones closest to the middle of n equal parts of
+		// the records seen.
+		picked := make([]sampledScore, r.n)
+		prev := -1
+		for j := range picked {
+			middle := (2*j + 1) * r.seen / (2 * r.n)
+			k := (middle + r.stride/2) / r.stride
+			if k <= prev {
+				k = prev + 1
+			}
+			if last := len(kept) - (r.n - j); k > last {
+				k = last
+			}
+			picked[j], prev = kept[k], k
+		}
+		kept = picked
+	}
+	sort.Slice(kept, func(i, j int) bool {
+		return kept[i].index < kept[j].index
+	})
+
+	scores := make([]score, len(kept))
+	for i := range kept {
+		scores[i] = kept[i].s
+	}
+	return scores
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Titles and comments of some charts hold control characters or bytes
+// that are no UTF-8, which XML cannot hold. The XML encoder writes them as
+// U+FFFD; dump -invalid-chars replaces them before encoding the same way,
+// strips them, or escapes them in a way encode -unescape reads back.
+
+// invalidCharPolicies rewrite the strings of a record for XML, returning
+// whether they changed anything.
+var invalidCharPolicies = map[string]func(s string) (string, bool){
+	"replace": func(s string) (string, bool) {
+		return sanitizeString(s, func(r rune, bad bool) string { return "\uFFFD" })
+	},
+	"strip": func(s string) (string, bool) {
+		return sanitizeString(s, func(r rune, bad bool) string { return "" })
+	},
+	"escape": escapeString,
+}
+
+func invalidCharPolicyNames() string {
+	var names []string
+	for name := range invalidCharPolicies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// isXMLChar tells whether r is a character of XML 1.0.
+func isXMLChar(r rune) bool {
+	return r == '\t' || r == '\n' || r == '\r' ||
+		r >= 0x20 && r <= 0xd7ff ||
+		r >= 0xe000 && r <= 0xfffd ||
+		r >= 0x10000 && r <= utf8.MaxRune
+}
+
+// sanitizeString returns s with the characters XML cannot hold and the
+// bytes that are no UTF-8 replaced by policy, and whether there were any.
+// policy gets invalid bytes as a rune of their value with bad set.
+func sanitizeString(s string, policy func(r rune, bad bool) string) (string, bool) {
+	var b strings.Builder
+	changed := false
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		bad := r == utf8.RuneError && size == 1
+		if !bad && isXMLChar(r) {
+			b.WriteString(s[i : i+size])
+		} else {
+			if bad {
+				r = rune(s[i])
+			}
+			b.WriteString(policy(r, bad))
+			changed = true
+		}
+		i += size
+	}
+	if !changed {
+		return s, false
+	}
+	return b.String(), true
+}
+
+// escapeString writes the characters XML cannot hold as \u0001 and the
+// bytes that are no UTF-8 as \xFF. Backslashes that unescapeString would
+// take for the start of an escape are doubled, so paths like C:\DTXFiles
+// keep their single ones.
+func escapeString(s string) (string, bool) {
+	var b strings.Builder
+	changed := false
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		switch {
+		case r == utf8.RuneError && size == 1:
+			fmt.Fprintf(&b, `\x%02X`, s[i])
+			changed = true
+		case !isXMLChar(r):
+			fmt.Fprintf(&b, `\u%04X`, r)
+			changed = true
+		case r == '\\' && escapeFollows(s[i+1:]):
+			b.WriteString(`\\`)
+			changed = true
+		default:
+			b.WriteString(s[i : i+size])
+		}
+		i += size
+	}
+	if !changed {
+		return s, false
+	}
+	return b.String(), true
+}
+
+// escapeFollows tells whether a backslash before s reads as the start of
+// an escape once s is escaped.
+func escapeFollows(s string) bool {
+	if s == "" {
+		return false
+	}
+	r, size := utf8.DecodeRuneInString(s)
+	if r == '\\' || r == utf8.RuneError && size == 1 || !isXMLChar(r) {
+		return true
+	}
+	_, ok := unescapeAt(`\` + s)
+	return ok
+}
+
+// unescapeAt decodes the \xFF or \u0001 escape s starts with, returning
+// what it stands for.
+func unescapeAt(s string) (string, bool) {
+	if len(s) >= 4 && s[1] == 'x' {
+		if v, err := strconv.ParseUint(s[2:4], 16, 8); err == nil {
+			return string([]byte{byte(v)}), true
+		}
+	}
+	if len(s) >= 6 && s[1] == 'u' {
+		if v, err := strconv.ParseUint(s[2:6], 16, 16); err == nil {
+			return string(rune(v)), true
+		}
+	}
+	return "", false
+}
+
+// unescapeString undoes escapeString.
+func unescapeString(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		switch {
+		case s[i] != '\\':
+			b.WriteByte(s[i])
+			i++
+		case strings.HasPrefix(s[i:], `\\`):
+			b.WriteByte('\\')
+			i += 2
+		default:
+			v, ok := unescapeAt(s[i:])
+			if !ok {
+				b.WriteByte('\\')
+				i++
+				continue
+			}
+			b.WriteString(v)
+			if s[i+1] == 'x' {
+				i += 4
+			} else {
+				i += 6
+			}
+		}
+	}
+	return b.String()
+}
+
+// sanitizeScore applies policy to every string of s and tells whether it
+// changed any.
+func sanitizeScore(s *score, policy func(s string) (string, bool)) bool {
+	changed := false
+	for _, f := range scoreFields(s) {
+		if f.value.Kind() != reflect.String {
+			continue
+		}
+		if v, ok := policy(f.value.String()); ok {
+			f.value.SetString(v)
+			changed = true
+		}
+	}
+	return changed
+}
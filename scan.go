@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/SirChronus/dtxmania-dbdump/dtxdb"
+)
+
+// scanCharts returns the records of every chart file below roots, in the
+// order of the folders. Charts that cannot be read are logged and left
+// out.
+func scanCharts(roots []string) []score {
+	var scores []score
+	for _, root := range roots {
+		err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				log.Printf("skipping %s: %v\n", path, err)
+				return nil
+			}
+			if _, ok := chartTypes[strings.ToLower(filepath.Ext(path))]; !ok || !info.Mode().IsRegular() {
+				return nil
+			}
+			s, err := newScoreFromChart(path)
+			if err != nil {
+				log.Printf("skipping %s: %v\n", path, err)
+				return nil
+			}
+			// The scores are in the .score.ini, which DTXMania only reads
+			// when it differs from what songs.db recorded of it, so none is
+			// recorded.
+			s.SongIniInformation = dtxdb.SongIniInformation{LastModified: dtxdb.DateFromTicks(0)}
+			scores = append(scores, s)
+			return nil
+		})
+		logFatalIfError(err)
+	}
+	return scores
+}
+
+func runScan(args []string) {
+	flags := newFlagSet("scan")
+	var roots stringList
+	flags.Var(&roots, "root", "song `folder` to enumerate, may be repeated (default DTXFiles)")
+	output := outputDBFlag(flags)
+	version := versionFlag(flags)
+	flags.Parse(args)
+	logFatalIfError(checkVersion(*version))
+	if len(roots) == 0 {
+		roots = stringList{"DTXFiles"}
+	}
+
+	scores := scanCharts(roots)
+	writeSongsDB(*output, *version, scores)
+	infof("%d charts enumerated into %s\n", len(scores), *output)
+}
+
+func runAdd(args []string) {
+	flags := newFlagSet("add")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: %s add [flags] folder...\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	input := inputDBFlag(flags)
+	output := outputDBFlag(flags)
+	readerFlags(flags)
+	flags.Parse(args)
+	if flags.NArg() == 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	versionString, scores := readAllScores(*input)
+	known := make(map[string]bool, len(scores))
+	for i := range scores {
+		known[strings.ToLower(scores[i].FileInformation.AbsoluteFilePath)] = true
+	}
+
+	added := 0
+	for _, s := range scanCharts(flags.Args()) {
+		if known[strings.ToLower(s.FileInformation.AbsoluteFilePath)] {
+			continue
+		}
+		infof("added: %s\n", s.FileInformation.AbsoluteFilePath)
+		scores = append(scores, s)
+		added++
+	}
+
+	writeSongsDB(*output, versionString, scores)
+	infof("%d charts added to %s\n", added, *output)
+}
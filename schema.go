@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/SirChronus/dtxmania-dbdump/dtxdb"
+)
+
+var (
+	songTypeType = reflect.TypeOf(dtxdb.SongType(0))
+	dateType     = reflect.TypeOf(dtxdb.Date{})
+)
+
+// nestedType tells whether values of t are dumped as elements of their
+// own. Dates are structs but dumped as a single value.
+func nestedType(t reflect.Type) bool {
+	return t.Kind() == reflect.Struct && t != dateType
+}
+
+// dgbTypeNames are the names of the instances of dtxdb.DGB in the schemas,
+// whose reflect names like DGB[int32] no schema language can hold.
+var dgbTypeNames = map[reflect.Type]string{
+	reflect.TypeOf(dtxdb.DGBInt32{}):   "DGBInt32",
+	reflect.TypeOf(dtxdb.DGBDouble{}):  "DGBDouble",
+	reflect.TypeOf(dtxdb.DGBBoolean{}): "DGBBoolean",
+}
+
+// schemaTypeName returns the name of the struct type t in the schemas.
+func schemaTypeName(t reflect.Type) string {
+	if t == reflect.TypeOf(score{}) {
+		return "Song"
+	}
+	if name, ok := dgbTypeNames[t]; ok {
+		return name
+	}
+	return t.Name()
+}
+
+// schemaFields lists the fields of the struct type t that are part of the
+// dump, flattening the embedded dtxdb.Score.
+func schemaFields(t reflect.Type) []reflect.StructField {
+	var fields []reflect.StructField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Anonymous {
+			fields = append(fields, schemaFields(f.Type)...)
+			continue
+		}
+		if xmlFieldName(f) != "" {
+			fields = append(fields, f)
+		}
+	}
+	return fields
+}
+
+// schemaValues lists the values of the fields schemaFields lists for v.
+func schemaValues(v reflect.Value) []reflect.Value {
+	var values []reflect.Value
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).Anonymous {
+			values = append(values, schemaValues(v.Field(i))...)
+		} else if xmlFieldName(t.Field(i)) != "" {
+			values = append(values, v.Field(i))
+		}
+	}
+	return values
+}
+
+// schemaFormats are the formats schema prints the schema of.
+func schemaFormats() []string {
+	return formatNamesWhere(func(f dumpFormat) bool { return f.schema != nil })
+}
+
+func runSchema(args []string) {
+	flags := newFlagSet("schema")
+	format := flags.String("format", "protobuf", "print the schema of `format`, one of "+strings.Join(schemaFormats(), ", "))
+	flags.Parse(args)
+
+	schema := dumpFormats[*format].schema
+	if schema == nil {
+		log.Fatalf("no schema for format %q, use one of %s", *format, strings.Join(schemaFormats(), ", "))
+	}
+	b, err := schema()
+	logFatalIfError(err)
+	_, err = fmt.Fprintf(os.Stdout, "%s\n", strings.TrimSuffix(string(b), "\n"))
+	logFatalIfError(err)
+}
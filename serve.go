@@ -0,0 +1,220 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// songSummary is the JSON representation of a song served by serve.
+type songSummary struct {
+	ID     string `json:"id"`
+	Title  string `json:"title"`
+	Artist string `json:"artist"`
+	Genre  string `json:"genre"`
+}
+
+func songSummaryOf(s *score) songSummary {
+	return songSummary{
+		ID:     songID(s),
+		Title:  s.SongInformation.Title,
+		Artist: s.SongInformation.Artist,
+		Genre:  s.SongInformation.Genre,
+	}
+}
+
+type songRequest struct {
+	Song      songSummary `json:"song"`
+	Requester string      `json:"requester,omitempty"`
+	Time      time.Time   `json:"time"`
+}
+
+// requestQueue holds the songs requested by viewers, oldest first.
+type requestQueue struct {
+	mu       sync.Mutex
+	requests []songRequest
+	max      int
+}
+
+// library answers lookups of the songs loaded from songs.db. It is never
+// modified once the server has started.
+type library struct {
+	songs []songSummary
+	byID  map[string]*songSummary
+}
+
+func newLibrary(scores []score) *library {
+	l := &library{byID: make(map[string]*songSummary, len(scores))}
+	for i := range scores {
+		l.songs = append(l.songs, songSummaryOf(&scores[i]))
+	}
+	for i := range l.songs {
+		l.byID[l.songs[i].ID] = &l.songs[i]
+	}
+
+	return l
+}
+
+// find returns the song with the given ID or, when id is empty, the first
+// song having the given title and, if not empty, artist.
+func (l *library) find(id, title, artist string) (songSummary, bool) {
+	if id != "" {
+		s, ok := l.byID[id]
+		if !ok {
+			return songSummary{}, false
+		}
+		return *s, true
+	}
+
+	for _, s := range l.songs {
+		if strings.EqualFold(s.Title, title) && (artist == "" || strings.EqualFold(s.Artist, artist)) {
+			return s, true
+		}
+	}
+	return songSummary{}, false
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("writing response: %v\n", err)
+	}
+}
+
+func writeJSONError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
+
+func (l *library) handleSongs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSONError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	q := strings.ToLower(r.URL.Query().Get("q"))
+	found := []songSummary{}
+	for _, s := range l.songs {
+		if q == "" || strings.Contains(strings.ToLower(s.Title), q) || strings.Contains(strings.ToLower(s.Artist), q) {
+			found = append(found, s)
+		}
+	}
+	writeJSON(w, http.StatusOK, found)
+}
+
+func (q *requestQueue) handleRequests(l *library) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			q.mu.Lock()
+			requests := append([]songRequest{}, q.requests...)
+			q.mu.Unlock()
+			writeJSON(w, http.StatusOK, requests)
+
+		case http.MethodPost:
+			var body struct {
+				ID        string `json:"id"`
+				Title     string `json:"title"`
+				Artist    string `json:"artist"`
+				Requester string `json:"requester"`
+			}
+			if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4096)).Decode(&body); err != nil {
+				writeJSONError(w, http.StatusBadRequest, "invalid request body")
+				return
+			}
+			if body.ID == "" && body.Title == "" {
+				writeJSONError(w, http.StatusBadRequest, "id or title is required")
+				return
+			}
+
+			song, ok := l.find(body.ID, body.Title, body.Artist)
+			if !ok {
+				writeJSONError(w, http.StatusNotFound, "song is not in the library")
+				return
+			}
+			req := songRequest{Song: song, Requester: body.Requester, Time: time.Now()}
+			status, message := q.add(req)
+			if status != http.StatusCreated {
+				writeJSONError(w, status, message)
+				return
+			}
+			writeJSON(w, status, req)
+
+		case http.MethodDelete:
+			if !q.remove(strings.TrimPrefix(r.URL.Path, "/requests/")) {
+				writeJSONError(w, http.StatusNotFound, "song is not in the queue")
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+
+		default:
+			writeJSONError(w, http.StatusMethodNotAllowed, "method not allowed")
+		}
+	}
+}
+
+func (q *requestQueue) add(req songRequest) (int, string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for _, r := range q.requests {
+		if r.Song.ID == req.Song.ID {
+			return http.StatusConflict, "song is already requested"
+		}
+	}
+	if len(q.requests) >= q.max {
+		return http.StatusServiceUnavailable, "request queue is full"
+	}
+
+	q.requests = append(q.requests, req)
+	return http.StatusCreated, ""
+}
+
+// remove drops the request for the song with the given ID, typically once
+// it has been played.
+func (q *requestQueue) remove(id string) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for i, r := range q.requests {
+		if r.Song.ID == id {
+			q.requests = append(q.requests[:i], q.requests[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func runServe(args []string) {
+	flags := newFlagSet("serve")
+	input := inputDBFlag(flags)
+	addr := flags.String("addr", "localhost:8080", "listen on `address`")
+	maxRequests := flags.Int("max-requests", 50, "maximum number of queued song requests")
+	readerFlags(flags)
+	flags.Parse(args)
+
+	_, scores := readAllScores(*input)
+	l := newLibrary(scores)
+	q := &requestQueue{max: *maxRequests}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/songs", l.handleSongs)
+	mux.HandleFunc("/requests", q.handleRequests(l))
+	mux.HandleFunc("/requests/", q.handleRequests(l))
+
+	// Clients that never finish their request would otherwise keep their
+	// connection, and a goroutine, forever.
+	server := &http.Server{
+		Addr:              *addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       30 * time.Second,
+		WriteTimeout:      60 * time.Second,
+		IdleTimeout:       2 * time.Minute,
+	}
+	infof("serving %d songs on http://%s\n", len(l.songs), *addr)
+	logFatalIfError(server.ListenAndServe())
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/SirChronus/dtxmania-dbdump/dtxdb"
+)
+
+// installCandidates lists the folders DTXMania is usually unpacked to,
+// the working directory first.
+func installCandidates() []string {
+	var dirs []string
+	if wd, err := os.Getwd(); err == nil {
+		dirs = append(dirs, wd)
+	}
+	home, _ := os.UserHomeDir()
+	if runtime.GOOS == "windows" {
+		for _, drive := range []string{`C:\`, `D:\`} {
+			dirs = append(dirs, drive+"DTXMania", drive+"DTXManiaGR", drive+"Games\\DTXMania")
+		}
+		for _, env := range []string{"ProgramFiles", "ProgramFiles(x86)"} {
+			if dir := os.Getenv(env); dir != "" {
+				dirs = append(dirs, filepath.Join(dir, "DTXMania"))
+			}
+		}
+		if home != "" {
+			for _, sub := range []string{"DTXMania", `Desktop\DTXMania`, `Downloads\DTXMania`, `Documents\DTXMania`} {
+				dirs = append(dirs, filepath.Join(home, sub))
+			}
+		}
+	} else if home != "" {
+		dirs = append(dirs, filepath.Join(home, "DTXMania"), filepath.Join(home, ".wine", "drive_c", "DTXMania"))
+	}
+	return dirs
+}
+
+// findInstalls returns the songs.db of the candidate folders having one.
+func findInstalls() []string {
+	var found []string
+	seen := map[string]bool{}
+	for _, dir := range installCandidates() {
+		path := filepath.Join(dir, "songs.db")
+		if seen[strings.ToLower(path)] {
+			continue
+		}
+		seen[strings.ToLower(path)] = true
+		if info, err := os.Stat(path); err == nil && info.Mode().IsRegular() {
+			found = append(found, path)
+		}
+	}
+	return found
+}
+
+// checkSongsDB opens path and reads the version of the database, telling
+// a songs.db from any other file.
+func checkSongsDB(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	r, err := dtxdb.NewReader(f)
+	if err != nil {
+		return "", fmt.Errorf("%s is no songs.db DTXMania wrote: %v", path, err)
+	}
+	return r.Version(), nil
+}
+
+// ask prints question and returns the answer, or def for an empty one.
+func ask(in *bufio.Reader, question, def string) (string, error) {
+	if def != "" {
+		fmt.Fprintf(os.Stderr, "%s [%s]: ", question, def)
+	} else {
+		fmt.Fprintf(os.Stderr, "%s: ", question)
+	}
+	line, err := in.ReadString('\n')
+	if err != nil && line == "" {
+		return "", fmt.Errorf("setup cancelled: %w", err)
+	}
+	if answer := strings.TrimSpace(line); answer != "" {
+		return answer, nil
+	}
+	return def, nil
+}
+
+// askDatabase asks for the DTXMania install until a songs.db is found in
+// it. The installs found may be picked by number.
+func askDatabase(in *bufio.Reader) (string, error) {
+	found := findInstalls()
+	def := ""
+	if len(found) > 0 {
+		fmt.Fprintln(os.Stderr, "DTXMania installs found:")
+		for i, path := range found {
+			fmt.Fprintf(os.Stderr, "  %d) %s\n", i+1, filepath.Dir(path))
+		}
+		def = "1"
+	} else {
+		fmt.Fprintln(os.Stderr, "No DTXMania install found, songs.db is in the folder of DTXManiaGR.exe.")
+	}
+
+	for {
+		answer, err := ask(in, "DTXMania folder or songs.db", def)
+		if err != nil {
+			return "", err
+		}
+		path := answer
+		if n, err := strconv.Atoi(answer); err == nil && n >= 1 && n <= len(found) {
+			path = found[n-1]
+		} else if info, err := os.Stat(path); err == nil && info.IsDir() {
+			path = filepath.Join(path, "songs.db")
+		}
+
+		version, err := checkSongsDB(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			continue
+		}
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(os.Stderr, "%s is a %s database.\n", abs, version)
+		return abs, nil
+	}
+}
+
+func runSetup(args []string) {
+	flags := newFlagSet("setup")
+	noDump := flags.Bool("no-dump", false, "only write the config file, do not run a first dump")
+	flags.Parse(args)
+
+	path, err := configPath()
+	logFatalIfError(err)
+	// Only the answered settings are replaced, the encoding and filters
+	// stay as they are.
+	c, err := readConfig(path)
+	logFatalIfError(err)
+	if _, err := os.Stat(path); err == nil {
+		fmt.Fprintf(os.Stderr, "%s exists already, its database, format and output are replaced.\n", path)
+	}
+
+	in := bufio.NewReader(os.Stdin)
+	c.Database, err = askDatabase(in)
+	logFatalIfError(err)
+
+	var format dumpFormat
+	for {
+		def := c.Format
+		if def == "" {
+			def = "xml"
+		}
+		c.Format, err = ask(in, "Dump format ("+formatNames()+")", def)
+		logFatalIfError(err)
+		if format, err = lookupDumpFormat(c.Format); err == nil {
+			break
+		}
+		fmt.Fprintln(os.Stderr, err)
+	}
+
+	wd, err := os.Getwd()
+	logFatalIfError(err)
+	c.Output, err = ask(in, "Write the dump to", filepath.Join(wd, "dump."+format.ext))
+	logFatalIfError(err)
+	c.Output, err = filepath.Abs(c.Output)
+	logFatalIfError(err)
+	logFatalIfError(os.MkdirAll(filepath.Dir(c.Output), 0777))
+
+	logFatalIfError(saveConfig(path, c))
+	infof("settings written to %s\n", path)
+	applyConfig(c)
+
+	if *noDump {
+		return
+	}
+	fmt.Fprintln(os.Stderr, "Running a first dump, from now on running dbdump without arguments does the same.")
+	runDump(nil)
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// shardKeys name the file of a song in a dump sharded with -shard-by. The
+// names of folders are their paths with forward slashes, made relative to
+// the library once every song is known.
+var shardKeys = map[string]func(s *score) string{
+	"genre": func(s *score) string {
+		if s.SongInformation.Genre == "" {
+			return "(no genre)"
+		}
+		return s.SongInformation.Genre
+	},
+	"folder": func(s *score) string {
+		return strings.TrimSuffix(strings.ReplaceAll(s.FileInformation.AbsoluteFolderPath, "\\", "/"), "/")
+	},
+}
+
+func shardKeyNames() string {
+	var names []string
+	for name := range shardKeys {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// shardWriter writes a dump as a complete file of the format per key below
+// a folder, e.g. dump/J-POP.xml. Songs are kept until close, as the songs
+// of a key may come anywhere in the database.
+type shardWriter struct {
+	format   dumpFormat
+	compress *compression
+	dir      string
+	byFolder bool
+	key      func(s *score) string
+	shards   map[string][]score
+}
+
+func newShardWriter(format dumpFormat, compress *compression, dir, by string) (*shardWriter, error) {
+	key, ok := shardKeys[by]
+	if !ok {
+		return nil, fmt.Errorf("unknown shard key %q, use one of %s", by, shardKeyNames())
+	}
+	return &shardWriter{format: format, compress: compress, dir: dir, byFolder: by == "folder", key: key, shards: map[string][]score{}}, nil
+}
+
+func (s *shardWriter) write(sc *score) error {
+	key := s.key(sc)
+	s.shards[key] = append(s.shards[key], *sc)
+	return nil
+}
+
+// shardFileName makes key usable as a file name on every system.
+func shardFileName(key string) string {
+	return strings.Map(func(r rune) rune {
+		if r < ' ' || strings.ContainsRune(`<>:"/\|?*`, r) {
+			return '_'
+		}
+		return r
+	}, key)
+}
+
+// paths returns the file of every key. Folders keep their place in the
+// tree below the folder holding all of them.
+func (s *shardWriter) paths() map[string]string {
+	ext := "." + s.format.ext
+	if s.compress != nil {
+		ext += s.compress.ext
+	}
+	paths := make(map[string]string, len(s.shards))
+	if !s.byFolder {
+		for key := range s.shards {
+			paths[key] = filepath.Join(s.dir, shardFileName(key)+ext)
+		}
+		return paths
+	}
+
+	var common []string
+	first := true
+	for key := range s.shards {
+		parent := strings.Split(path.Dir(key), "/")
+		if first {
+			common, first = parent, false
+			continue
+		}
+		n := 0
+		for n < len(common) && n < len(parent) && strings.EqualFold(common[n], parent[n]) {
+			n++
+		}
+		common = common[:n]
+	}
+	for key := range s.shards {
+		parts := strings.Split(key, "/")[len(common):]
+		for i, p := range parts {
+			parts[i] = shardFileName(p)
+		}
+		paths[key] = filepath.Join(s.dir, filepath.Join(parts...)+ext)
+	}
+	return paths
+}
+
+func (s *shardWriter) writeShard(name string, scores []score) error {
+	if err := os.MkdirAll(filepath.Dir(name), 0777); err != nil {
+		return err
+	}
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var w io.Writer = f
+	var compressWriter io.WriteCloser
+	if s.compress != nil {
+		compressWriter = s.compress.newWriter(f)
+		w = compressWriter
+	}
+	buf := bufio.NewWriter(w)
+	out, err := s.format.newWriter(buf)
+	if err != nil {
+		return err
+	}
+	for i := range scores {
+		if err := out.write(&scores[i]); err != nil {
+			return err
+		}
+	}
+	if err := out.close(); err != nil {
+		return err
+	}
+	if err := buf.Flush(); err != nil {
+		return err
+	}
+	if compressWriter != nil {
+		if err := compressWriter.Close(); err != nil {
+			return err
+		}
+	}
+	return f.Close()
+}
+
+// close writes the shards. Keys making the same file name, which only
+// differ in characters file names cannot hold, share the file.
+func (s *shardWriter) close() error {
+	paths := s.paths()
+	keys := make([]string, 0, len(s.shards))
+	for key := range s.shards {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	files := map[string][]score{}
+	var names []string
+	for _, key := range keys {
+		name := paths[key]
+		if _, ok := files[name]; !ok {
+			names = append(names, name)
+		}
+		files[name] = append(files[name], s.shards[key]...)
+	}
+	for _, name := range names {
+		if err := s.writeShard(name, files[name]); err != nil {
+			return err
+		}
+	}
+	infof("dump sharded into %d files below %s\n", len(names), s.dir)
+	return nil
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/SirChronus/dtxmania-dbdump/dtxdb"
+)
+
+// skillPoints is the skill of a chart the way GITADORA counts it: the
+// level times the achievement rate times 20, up to 200 for a perfect play
+// of a level 10.00 chart.
+func skillPoints(s *score, part dtxdb.Instrument) float64 {
+	return s.SongInformation.CombinedLevel(part) * s.SongInformation.HighSkill.Get(part) / 100 * 20
+}
+
+// hotList names the song folders counting as HOT, the rest of the library
+// counting as OTHER. Entries match folders like pack manifest prefixes do,
+// or the pack of a song when -packs is given.
+type hotList []string
+
+func loadHotList(path string) (hotList, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var l hotList
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		l = append(l, text)
+	}
+	return l, scanner.Err()
+}
+
+func (l hotList) isHot(s *score) bool {
+	folder := normalizePackPath(s.FileInformation.AbsoluteFolderPath)
+	for _, e := range l {
+		prefix := normalizePackPath(e)
+		if strings.HasPrefix(folder, prefix) || strings.Contains(folder, "/"+prefix) || s.Pack != "" && strings.EqualFold(s.Pack, e) {
+			return true
+		}
+	}
+	return false
+}
+
+// songSkill is the best chart of a song for the skill split.
+type songSkill struct {
+	Title       string  `json:"title"`
+	Artist      string  `json:"artist"`
+	Chart       string  `json:"chart"`
+	Level       float64 `json:"level"`
+	Achievement float64 `json:"achievement"`
+	Points      float64 `json:"points"`
+}
+
+type skillGroup struct {
+	Name  string      `json:"name"`
+	Total float64     `json:"total"`
+	Songs []songSkill `json:"songs"`
+}
+
+type skillSplit struct {
+	Part   string       `json:"part"`
+	Total  float64      `json:"total"`
+	Groups []skillGroup `json:"groups"`
+}
+
+// splitSkill adds up the best count songs of the HOT and the OTHER group.
+// A song is a song folder, only its best chart counts.
+func splitSkill(scores []score, part dtxdb.Instrument, hot hotList, count int) skillSplit {
+	best := map[string]songSkill{}
+	isHot := map[string]bool{}
+	for i := range scores {
+		s := &scores[i]
+		points := skillPoints(s, part)
+		if points <= 0 {
+			continue
+		}
+		folder := normalizePackPath(s.FileInformation.AbsoluteFolderPath)
+		if b, ok := best[folder]; ok && b.Points >= points {
+			continue
+		}
+		best[folder] = songSkill{
+			Title:       s.SongInformation.Title,
+			Artist:      s.SongInformation.Artist,
+			Chart:       chartFileName(s),
+			Level:       s.SongInformation.CombinedLevel(part),
+			Achievement: s.SongInformation.HighSkill.Get(part),
+			Points:      points,
+		}
+		isHot[folder] = hot.isHot(s)
+	}
+
+	split := skillSplit{Part: part.String(), Groups: []skillGroup{{Name: "HOT"}, {Name: "OTHER"}}}
+	for folder, song := range best {
+		g := &split.Groups[1]
+		if isHot[folder] {
+			g = &split.Groups[0]
+		}
+		g.Songs = append(g.Songs, song)
+	}
+	for i := range split.Groups {
+		g := &split.Groups[i]
+		sort.Slice(g.Songs, func(a, b int) bool {
+			if g.Songs[a].Points != g.Songs[b].Points {
+				return g.Songs[a].Points > g.Songs[b].Points
+			}
+			return g.Songs[a].Title < g.Songs[b].Title
+		})
+		if len(g.Songs) > count {
+			g.Songs = g.Songs[:count]
+		}
+		for _, song := range g.Songs {
+			g.Total += song.Points
+		}
+		split.Total += g.Total
+	}
+	return split
+}
+
+func runSkill(args []string) {
+	flags := newFlagSet("skill")
+	input := inputDBFlag(flags)
+	hotPath := flags.String("hot", "", "`file` listing the song folders or packs counting as HOT, one per line")
+	partName := flags.String("part", "drums", "`instrument`: drums, guitar or bass")
+	count := flags.Int("count", 25, "count the best `n` songs of each group")
+	asJSON := flags.Bool("json", false, "print the split as JSON")
+	packsPath := packFlag(flags)
+	readerFlags(flags)
+	flags.Parse(args)
+	if *hotPath == "" {
+		log.Fatalln("skill needs the list of HOT folders, see -hot")
+	}
+	hot, err := loadHotList(*hotPath)
+	logFatalIfError(err)
+	part, err := dtxdb.ParseInstrument(*partName)
+	logFatalIfError(err)
+	packs := loadPackFlag(*packsPath)
+
+	_, scores := readAllScores(*input)
+	for i := range scores {
+		scores[i].Pack = packs.packOf(&scores[i])
+	}
+	split := splitSkill(scores, part, hot, *count)
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		logFatalIfError(enc.Encode(split))
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	for _, g := range split.Groups {
+		fmt.Fprintf(w, "%s\t%.2f\t%d songs\n", g.Name, g.Total, len(g.Songs))
+		for i, song := range g.Songs {
+			fmt.Fprintf(w, "  %d\t%s / %s\t%s\t%.2f\t%.2f%%\t%.2f\n", i+1, song.Title, song.Artist, song.Chart, song.Level, song.Achievement, song.Points)
+		}
+	}
+	fmt.Fprintf(w, "SKILL\t%.2f\n", split.Total)
+	logFatalIfError(w.Flush())
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/SirChronus/dtxmania-dbdump/dtxdb"
+)
+
+// transliterator turns text into a reading that sorts sensibly among latin
+// titles. Implementations are registered in transliterators.
+type transliterator interface {
+	Transliterate(s string) string
+}
+
+type transliteratorFunc func(s string) string
+
+func (f transliteratorFunc) Transliterate(s string) string {
+	return f(s)
+}
+
+var transliterators = map[string]transliterator{
+	"romaji": transliteratorFunc(romanize),
+	"none":   transliteratorFunc(foldWidth),
+}
+
+func lookupTransliterator(name string) (transliterator, error) {
+	t, ok := transliterators[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown transliterator %q", name)
+	}
+	return t, nil
+}
+
+// sortKey returns the key used to sort s by its title.
+func sortKey(t transliterator, s string) string {
+	return strings.ToLower(strings.TrimSpace(t.Transliterate(s)))
+}
+
+// foldWidth replaces full-width latin letters, digits and symbols with
+// their ASCII counterparts.
+func foldWidth(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= '！' && r <= '～':
+			return r - '！' + '!'
+		case r == '　':
+			return ' '
+		}
+		return r
+	}, s)
+}
+
+// kanaRomaji maps hiragana to their Hepburn romanization. Katakana are
+// shifted into the hiragana block before the lookup.
+var kanaRomaji = map[rune]string{
+	'あ': "a", 'い': "i", 'う': "u", 'え': "e", 'お': "o",
+	'か': "ka", 'き': "ki", 'く': "ku", 'け': "ke", 'こ': "ko",
+	'が': "ga", 'ぎ': "gi", 'ぐ': "gu", 'げ': "ge", 'ご': "go",
+	'さ': "sa", 'し': "shi", 'す': "su", 'せ': "se", 'そ': "so",
+	'ざ': "za", 'じ': "ji", 'ず': "zu", 'ぜ': "ze", 'ぞ': "zo",
+	'た': "ta", 'ち': "chi", 'つ': "tsu", 'て': "te", 'と': "to",
+	'だ': "da", 'ぢ': "ji", 'づ': "zu", 'で': "de", 'ど': "do",
+	'な': "na", 'に': "ni", 'ぬ': "nu", 'ね': "ne", 'の': "no",
+	'は': "ha", 'ひ': "hi", 'ふ': "fu", 'へ': "he", 'ほ': "ho",
+	'ば': "ba", 'び': "bi", 'ぶ': "bu", 'べ': "be", 'ぼ': "bo",
+	'ぱ': "pa", 'ぴ': "pi", 'ぷ': "pu", 'ぺ': "pe", 'ぽ': "po",
+	'ま': "ma", 'み': "mi", 'む': "mu", 'め': "me", 'も': "mo",
+	'や': "ya", 'ゆ': "yu", 'よ': "yo",
+	'ら': "ra", 'り': "ri", 'る': "ru", 'れ': "re", 'ろ': "ro",
+	'わ': "wa", 'ゐ': "i", 'ゑ': "e", 'を': "o", 'ん': "n", 'ゔ': "vu",
+	'ぁ': "a", 'ぃ': "i", 'ぅ': "u", 'ぇ': "e", 'ぉ': "o",
+	'ゃ': "ya", 'ゅ': "yu", 'ょ': "yo", 'ゎ': "wa",
+}
+
+// romanize converts hiragana and katakana in s to romaji. Kanji are kept
+// as they are since reading them requires a dictionary.
+func romanize(s string) string {
+	var b strings.Builder
+	runes := []rune(foldWidth(s))
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		if r >= 'ァ' && r <= 'ヶ' {
+			r -= 'ァ' - 'ぁ'
+		}
+
+		switch {
+		case r == 'っ':
+			// A small tsu doubles the consonant that follows.
+			if i+1 < len(runes) {
+				if next := romanize(string(runes[i+1])); next != "" && !strings.ContainsRune("aiueo", rune(next[0])) {
+					b.WriteByte(next[0])
+				}
+			}
+		case r == 'ー':
+			// The long vowel mark repeats the previous vowel.
+			out := b.String()
+			if n := len(out); n > 0 && strings.ContainsRune("aiueo", rune(out[n-1])) {
+				b.WriteByte(out[n-1])
+			}
+		case (r == 'ゃ' || r == 'ゅ' || r == 'ょ') && b.Len() > 0:
+			// Small ya, yu and yo combine with the preceding i syllable,
+			// e.g. "kyo" or "sho".
+			out := b.String()
+			if strings.HasSuffix(out, "i") {
+				stem := out[:len(out)-1]
+				if strings.HasSuffix(stem, "sh") || strings.HasSuffix(stem, "ch") || strings.HasSuffix(stem, "j") {
+					b.Reset()
+					b.WriteString(stem)
+					b.WriteString(kanaRomaji[r][1:])
+					continue
+				}
+				b.Reset()
+				b.WriteString(stem)
+			}
+			b.WriteString(kanaRomaji[r])
+		default:
+			if romaji, ok := kanaRomaji[r]; ok {
+				b.WriteString(romaji)
+			} else if r == '・' {
+				b.WriteByte(' ')
+			} else {
+				b.WriteRune(unicode.ToLower(r))
+			}
+		}
+	}
+
+	return b.String()
+}
+
+// scoreSorters orders records for the -sort flag of dump and for sort.
+var scoreSorters = map[string]func(t transliterator, a, b *score) bool{
+	"title": func(t transliterator, a, b *score) bool {
+		return a.SortKey < b.SortKey
+	},
+	"artist": func(t transliterator, a, b *score) bool {
+		ka, kb := sortKey(t, a.SongInformation.Artist), sortKey(t, b.SongInformation.Artist)
+		if ka != kb {
+			return ka < kb
+		}
+		return a.SortKey < b.SortKey
+	},
+	// level sorts by the highest level of the parts of the chart.
+	"level": func(t transliterator, a, b *score) bool {
+		la, lb := highestLevel(&a.SongInformation), highestLevel(&b.SongInformation)
+		if la != lb {
+			return la < lb
+		}
+		return a.SortKey < b.SortKey
+	},
+	"folder": func(t transliterator, a, b *score) bool {
+		return strings.ToLower(a.FileInformation.AbsoluteFilePath) < strings.ToLower(b.FileInformation.AbsoluteFilePath)
+	},
+}
+
+func highestLevel(s *dtxdb.SongInformation) float64 {
+	highest := 0.0
+	for _, i := range dtxdb.Instruments {
+		if l := s.CombinedLevel(i); l > highest {
+			highest = l
+		}
+	}
+	return highest
+}
+
+func sortScores(t transliterator, scores []score, by string) error {
+	less, ok := scoreSorters[by]
+	if !ok {
+		return fmt.Errorf("cannot sort by %q", by)
+	}
+
+	sort.SliceStable(scores, func(i, j int) bool {
+		return less(t, &scores[i], &scores[j])
+	})
+	return nil
+}
+
+// runSort reorders the records of songs.db itself, for the DTXMania builds
+// listing songs in database order.
+func runSort(args []string) {
+	flags := newFlagSet("sort")
+	input := inputDBFlag(flags)
+	sortBy := flags.String("by", "title", "sort songs by `key`: "+strings.Join(sortedKeys(scoreSorters), ", "))
+	translitName := flags.String("transliterator", "romaji", "`name` of the transliterator generating sort keys: romaji or none")
+	output := outputDBFlag(flags)
+	readerFlags(flags)
+	flags.Parse(args)
+
+	if _, ok := scoreSorters[*sortBy]; !ok {
+		log.Fatalf("cannot sort by %q, use one of %s\n", *sortBy, strings.Join(sortedKeys(scoreSorters), ", "))
+	}
+	translit, err := lookupTransliterator(*translitName)
+	logFatalIfError(err)
+
+	versionString, scores := readAllScores(*input)
+	for i := range scores {
+		scores[i].SortKey = sortKey(translit, scores[i].SongInformation.Title)
+	}
+	logFatalIfError(sortScores(translit, scores, *sortBy))
+
+	writeSongsDB(*output, versionString, scores)
+	infof("%d records sorted by %s into %s\n", len(scores), *sortBy, *output)
+}
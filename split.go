@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// byteSize is a number of bytes given with an optional unit, like 50MB.
+// KB, MB and GB are powers of 1000, KiB, MiB and GiB powers of 1024.
+type byteSize int64
+
+var byteUnits = []struct {
+	suffix string
+	size   int64
+}{
+	{"KiB", 1 << 10}, {"MiB", 1 << 20}, {"GiB", 1 << 30},
+	{"KB", 1e3}, {"MB", 1e6}, {"GB", 1e9}, {"B", 1},
+}
+
+func (b *byteSize) String() string {
+	return strconv.FormatInt(int64(*b), 10)
+}
+
+func (b *byteSize) Set(v string) error {
+	number, unit := strings.TrimSpace(v), int64(1)
+	for _, u := range byteUnits {
+		if strings.HasSuffix(strings.ToUpper(number), strings.ToUpper(u.suffix)) {
+			number, unit = strings.TrimSpace(number[:len(number)-len(u.suffix)]), u.size
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(number, 64)
+	if err != nil || n < 0 {
+		return fmt.Errorf("%q is not a size like 50MB", v)
+	}
+	*b = byteSize(n * float64(unit))
+	return nil
+}
+
+// countingWriter counts the bytes written through it.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// splitReserve is kept free at the end of every part for what closing a
+// writer adds after the last record.
+const splitReserve = 64
+
+// splitWriter writes a dump as numbered parts below a size limit, each a
+// complete file of the format: dump.1.xml, dump.2.xml, ... A new part is
+// started once the next record might not fit, taking twice the largest
+// record written so far as the size of the next one.
+type splitWriter struct {
+	format  dumpFormat
+	limit   int64
+	path    string
+	parts   []string
+	file    *os.File
+	buf     *bufio.Writer
+	count   *countingWriter
+	w       sizedWriter
+	records int
+	largest int64
+}
+
+func newSplitWriter(format dumpFormat, path string, limit int64) (*splitWriter, error) {
+	if format.newPart == nil {
+		return nil, fmt.Errorf("%s dumps cannot be split, their size is only known once written", format.ext)
+	}
+	return &splitWriter{format: format, limit: limit, path: path}, nil
+}
+
+func (s *splitWriter) partPath(n int) string {
+	ext := filepath.Ext(s.path)
+	return fmt.Sprintf("%s.%d%s", strings.TrimSuffix(s.path, ext), n, ext)
+}
+
+func (s *splitWriter) size() int64 {
+	return s.count.n + int64(s.w.buffered())
+}
+
+func (s *splitWriter) startPart() error {
+	path := s.partPath(len(s.parts) + 1)
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	s.file, s.parts, s.records = f, append(s.parts, path), 0
+	// The global is closed when a later error exits.
+	outFile = f
+	s.buf = bufio.NewWriter(f)
+	s.count = &countingWriter{w: s.buf}
+
+	newWriter := s.format.newWriter
+	if len(s.parts) > 1 {
+		newWriter = s.format.newPart
+	}
+	w, err := newWriter(s.count)
+	if err != nil {
+		return err
+	}
+	s.w = w.(sizedWriter)
+	return nil
+}
+
+func (s *splitWriter) endPart() error {
+	if err := s.w.close(); err != nil {
+		return err
+	}
+	if s.count.n > s.limit {
+		log.Printf("warning: %s is %d bytes, more than -max-output-size\n", s.file.Name(), s.count.n)
+	}
+	if err := s.buf.Flush(); err != nil {
+		return err
+	}
+	return s.file.Close()
+}
+
+func (s *splitWriter) write(sc *score) error {
+	if s.w == nil {
+		if err := s.startPart(); err != nil {
+			return err
+		}
+	} else if s.records > 0 && s.size()+2*s.largest+splitReserve > s.limit {
+		if err := s.endPart(); err != nil {
+			return err
+		}
+		if err := s.startPart(); err != nil {
+			return err
+		}
+	}
+
+	before := s.size()
+	if err := s.w.write(sc); err != nil {
+		return err
+	}
+	s.records++
+	if n := s.size() - before; n > s.largest {
+		s.largest = n
+	}
+	return nil
+}
+
+func (s *splitWriter) close() error {
+	if s.w == nil {
+		// An empty dump is still a part.
+		if err := s.startPart(); err != nil {
+			return err
+		}
+	}
+	if err := s.endPart(); err != nil {
+		return err
+	}
+	infof("dump split into %d parts: %s\n", len(s.parts), strings.Join(s.parts, ", "))
+	return nil
+}
@@ -0,0 +1,361 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/SirChronus/dtxmania-dbdump/dtxdb"
+)
+
+// SQLite databases are written directly in the SQLite file format, version
+// 3: every table is a b-tree of rows ordered by rowid, built bottom up from
+// full leaf pages since the rows are added in rowid order. Rows too large
+// for a page spill into overflow pages.
+
+const (
+	sqlitePageSize = 4096
+	// sqliteMaxLocal is the largest payload kept in a leaf cell.
+	sqliteMaxLocal = sqlitePageSize - 35
+	// sqliteVersion is the SQLite version the file claims to be written by.
+	sqliteVersion = 3040000
+)
+
+// sqliteTables are the tables of the database, songs.id being the rowid
+// the other tables refer to.
+var sqliteTables = []struct{ name, sql string }{
+	{"songs", `CREATE TABLE songs (
+	id INTEGER PRIMARY KEY,
+	title TEXT, artist TEXT, comment TEXT, genre TEXT,
+	pre_image TEXT, pre_movie TEXT, pre_sound TEXT, background TEXT,
+	performance_history_first TEXT, performance_history_second TEXT, performance_history_third TEXT,
+	performance_history_fourth TEXT, performance_history_fifth TEXT,
+	hidden_level INTEGER, song_type TEXT, bpm REAL, duration INTEGER,
+	pack TEXT, sort_key TEXT, source TEXT
+)`},
+	{"file_info", `CREATE TABLE file_info (
+	song_id INTEGER PRIMARY KEY REFERENCES songs(id),
+	path TEXT, folder TEXT, last_modified TEXT, file_size INTEGER,
+	ini_last_modified TEXT, ini_file_size INTEGER
+)`},
+	{"scores", `CREATE TABLE scores (
+	id INTEGER PRIMARY KEY,
+	song_id INTEGER REFERENCES songs(id),
+	instrument TEXT, level INTEGER, level_dec INTEGER, best_rank INTEGER,
+	high_skill REAL, full_combo INTEGER, classic INTEGER, score_exists INTEGER, plays INTEGER
+)`},
+}
+
+// sqliteVarint encodes v the way SQLite does: big endian groups of 7 bits,
+// the ninth byte holding 8.
+func sqliteVarint(v uint64) []byte {
+	if v > 1<<56-1 {
+		b := make([]byte, 9)
+		b[8] = byte(v)
+		v >>= 8
+		for i := 7; i >= 0; i-- {
+			b[i] = byte(v&0x7f) | 0x80
+			v >>= 7
+		}
+		return b
+	}
+	var groups []byte
+	for {
+		groups = append(groups, byte(v&0x7f))
+		v >>= 7
+		if v == 0 {
+			break
+		}
+	}
+	b := make([]byte, len(groups))
+	for i, g := range groups {
+		b[len(groups)-1-i] = g
+		if i > 0 {
+			b[len(groups)-1-i] |= 0x80
+		}
+	}
+	return b
+}
+
+// sqliteRecord encodes a row: a header with the serial type of every
+// column followed by the values. Values are nil, int64, float64, bool or
+// string.
+func sqliteRecord(values ...interface{}) []byte {
+	var types, body []byte
+	for _, v := range values {
+		switch v := v.(type) {
+		case nil:
+			types = append(types, 0)
+		case bool:
+			if v {
+				types = append(types, 9)
+			} else {
+				types = append(types, 8)
+			}
+		case int64:
+			t, n := sqliteIntType(v)
+			types = append(types, sqliteVarint(t)...)
+			var b [8]byte
+			binary.BigEndian.PutUint64(b[:], uint64(v))
+			body = append(body, b[8-n:]...)
+		case float64:
+			types = append(types, 7)
+			var b [8]byte
+			binary.BigEndian.PutUint64(b[:], math.Float64bits(v))
+			body = append(body, b[:]...)
+		case string:
+			types = append(types, sqliteVarint(uint64(len(v))*2+13)...)
+			body = append(body, v...)
+		default:
+			panic(fmt.Sprintf("sqlite: unsupported value %T", v))
+		}
+	}
+
+	// The header size counts itself.
+	size := len(types) + 1
+	for len(types)+len(sqliteVarint(uint64(size))) != size {
+		size = len(types) + len(sqliteVarint(uint64(size)))
+	}
+	record := append(sqliteVarint(uint64(size)), types...)
+	return append(record, body...)
+}
+
+// sqliteIntType returns the serial type and the size of the smallest
+// integer encoding holding v. 0 and 1 take no bytes at all.
+func sqliteIntType(v int64) (uint64, int) {
+	switch {
+	case v == 0:
+		return 8, 0
+	case v == 1:
+		return 9, 0
+	case v >= math.MinInt8 && v <= math.MaxInt8:
+		return 1, 1
+	case v >= math.MinInt16 && v <= math.MaxInt16:
+		return 2, 2
+	case v >= -1<<23 && v < 1<<23:
+		return 3, 3
+	case v >= math.MinInt32 && v <= math.MaxInt32:
+		return 4, 4
+	case v >= -1<<47 && v < 1<<47:
+		return 5, 6
+	}
+	return 6, 8
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	return append(b, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+// sqliteChild is a page of a b-tree with the largest rowid below it.
+type sqliteChild struct {
+	page   uint32
+	maxKey int64
+}
+
+// sqliteTable collects the leaf pages of a table.
+type sqliteTable struct {
+	leaves  []sqliteChild
+	cells   [][]byte
+	size    int
+	lastKey int64
+}
+
+// sqliteFile holds the pages of the database, page 1 being written last
+// since it lists the root pages of the tables.
+type sqliteFile struct {
+	pages [][]byte
+}
+
+func (f *sqliteFile) allocate() (uint32, []byte) {
+	p := make([]byte, sqlitePageSize)
+	f.pages = append(f.pages, p)
+	return uint32(len(f.pages)), p
+}
+
+// cell builds the leaf cell of a row, moving what does not fit into
+// overflow pages.
+func (f *sqliteFile) cell(rowid int64, record []byte) []byte {
+	cell := append(sqliteVarint(uint64(len(record))), sqliteVarint(uint64(rowid))...)
+	if len(record) <= sqliteMaxLocal {
+		return append(cell, record...)
+	}
+
+	const usable = sqlitePageSize
+	minLocal := (usable-12)*32/255 - 23
+	local := minLocal + (len(record)-minLocal)%(usable-4)
+	if local > sqliteMaxLocal {
+		local = minLocal
+	}
+	cell = append(cell, record[:local]...)
+
+	var prev []byte
+	for rest := record[local:]; len(rest) > 0; {
+		n, p := f.allocate()
+		if prev == nil {
+			cell = appendUint32(cell, n)
+		} else {
+			binary.BigEndian.PutUint32(prev, n)
+		}
+		chunk := copy(p[4:], rest)
+		rest, prev = rest[chunk:], p
+	}
+	return cell
+}
+
+// fits tells whether a leaf page starting its header at offset hdr holds
+// one more cell of size n.
+func (t *sqliteTable) fits(hdr, n int) bool {
+	return hdr+8+2*(len(t.cells)+1)+t.size+n <= sqlitePageSize
+}
+
+func (f *sqliteFile) insert(t *sqliteTable, rowid int64, record []byte) {
+	cell := f.cell(rowid, record)
+	if !t.fits(0, len(cell)) {
+		f.flushLeaf(t)
+	}
+	t.cells = append(t.cells, cell)
+	t.size += len(cell)
+	t.lastKey = rowid
+}
+
+// layoutPage writes the cells of a b-tree page of the given type below the
+// header at hdr, contents growing from the end of the page.
+func layoutPage(p []byte, hdr int, pageType byte, cells [][]byte, rightmost uint32) {
+	p[hdr] = pageType
+	binary.BigEndian.PutUint16(p[hdr+3:], uint16(len(cells)))
+	pointers := hdr + 8
+	if pageType == 0x05 {
+		binary.BigEndian.PutUint32(p[hdr+8:], rightmost)
+		pointers += 4
+	}
+	end := sqlitePageSize
+	for i, c := range cells {
+		end -= len(c)
+		copy(p[end:], c)
+		binary.BigEndian.PutUint16(p[pointers+2*i:], uint16(end))
+	}
+	binary.BigEndian.PutUint16(p[hdr+5:], uint16(end))
+}
+
+func (f *sqliteFile) flushLeaf(t *sqliteTable) {
+	n, p := f.allocate()
+	layoutPage(p, 0, 0x0d, t.cells, 0)
+	t.leaves = append(t.leaves, sqliteChild{n, t.lastKey})
+	t.cells, t.size = nil, 0
+}
+
+// root finishes the table and builds the interior pages above its leaves,
+// returning the root page.
+func (f *sqliteFile) root(t *sqliteTable) uint32 {
+	if len(t.cells) > 0 || len(t.leaves) == 0 {
+		f.flushLeaf(t)
+	}
+
+	// An interior cell is a page number and a varint rowid of at most 9
+	// bytes, plus its pointer.
+	const perPage = (sqlitePageSize-12)/(4+9+2) + 1
+	level := t.leaves
+	for len(level) > 1 {
+		var next []sqliteChild
+		for start := 0; start < len(level); start += perPage {
+			end := start + perPage
+			if end > len(level) {
+				end = len(level)
+			}
+			children := level[start:end]
+			var cells [][]byte
+			for _, c := range children[:len(children)-1] {
+				cell := appendUint32(nil, c.page)
+				cells = append(cells, append(cell, sqliteVarint(uint64(c.maxKey))...))
+			}
+			last := children[len(children)-1]
+			n, p := f.allocate()
+			layoutPage(p, 0, 0x05, cells, last.page)
+			next = append(next, sqliteChild{n, last.maxKey})
+		}
+		level = next
+	}
+	return level[0].page
+}
+
+// sqliteWriter keeps the pages of the database until close, the file
+// header and the schema come first but depend on everything else.
+type sqliteWriter struct {
+	w      io.Writer
+	file   sqliteFile
+	tables []*sqliteTable
+	songs  int64
+}
+
+func newSQLiteWriter(w io.Writer) (recordWriter, error) {
+	s := &sqliteWriter{w: w}
+	// Page 1 is filled in by close.
+	s.file.allocate()
+	for range sqliteTables {
+		s.tables = append(s.tables, &sqliteTable{})
+	}
+	return s, nil
+}
+
+func (s *sqliteWriter) write(sc *score) error {
+	s.songs++
+	id := s.songs
+	info := &sc.SongInformation
+	history := &info.PerformanceHistory
+	// The INTEGER PRIMARY KEY columns are the rowid, rows store NULL.
+	s.file.insert(s.tables[0], id, sqliteRecord(nil,
+		info.Title, info.Artist, info.Comment, info.Genre,
+		info.PreImage, info.PreMovie, info.PreSound, info.Background,
+		history.First, history.Second, history.Third, history.Fourth, history.Fifth,
+		info.HiddenLevel, info.SongType.String(), info.Bpm, int64(info.Duration),
+		sc.Pack, sc.SortKey, sc.Source))
+
+	fi, ini := &sc.FileInformation, &sc.SongIniInformation
+	s.file.insert(s.tables[1], id, sqliteRecord(nil,
+		fi.AbsoluteFilePath, fi.AbsoluteFolderPath, fi.LastModified.String(), fi.FileSize,
+		ini.LastModified.String(), ini.FileSize))
+
+	for n, part := range dtxdb.Instruments {
+		s.file.insert(s.tables[2], (id-1)*int64(len(dtxdb.Instruments))+int64(n)+1, sqliteRecord(nil,
+			id, part.String(), int64(info.Level.Get(part)), int64(info.LevelDec.Get(part)),
+			int64(info.BestRank.Get(part)), info.HighSkill.Get(part), info.FullCombo.Get(part),
+			info.Classic.Get(part), info.ScoreExists.Get(part), int64(info.NbPerformance.Get(part))))
+	}
+	return nil
+}
+
+func (s *sqliteWriter) close() error {
+	schema := &sqliteTable{}
+	for i, t := range sqliteTables {
+		root := s.file.root(s.tables[i])
+		record := sqliteRecord("table", t.name, t.name, int64(root), t.sql)
+		cell := s.file.cell(int64(i+1), record)
+		if !schema.fits(100, len(cell)) {
+			return fmt.Errorf("sqlite: the schema does not fit the first page")
+		}
+		schema.cells = append(schema.cells, cell)
+		schema.size += len(cell)
+	}
+
+	p := s.file.pages[0]
+	layoutPage(p, 100, 0x0d, schema.cells, 0)
+	copy(p, "SQLite format 3\x00")
+	binary.BigEndian.PutUint16(p[16:], sqlitePageSize)
+	p[18], p[19] = 1, 1 // legacy journal, no WAL
+	p[21], p[22], p[23] = 64, 32, 32
+	binary.BigEndian.PutUint32(p[24:], 1) // change counter
+	binary.BigEndian.PutUint32(p[28:], uint32(len(s.file.pages)))
+	binary.BigEndian.PutUint32(p[40:], 1) // schema cookie
+	binary.BigEndian.PutUint32(p[44:], 4) // schema format
+	binary.BigEndian.PutUint32(p[56:], 1) // UTF-8
+	binary.BigEndian.PutUint32(p[92:], 1) // page count valid for change 1
+	binary.BigEndian.PutUint32(p[96:], sqliteVersion)
+
+	for _, page := range s.file.pages {
+		if _, err := s.w.Write(page); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/SirChronus/dtxmania-dbdump/dtxdb"
+)
+
+type groupStats struct {
+	key      string
+	songs    int
+	charted  int
+	levelSum float64
+	minLevel float64
+	maxLevel float64
+}
+
+func (g *groupStats) add(level float64) {
+	g.songs++
+	if level <= 0 {
+		return
+	}
+
+	if g.charted == 0 || level < g.minLevel {
+		g.minLevel = level
+	}
+	if level > g.maxLevel {
+		g.maxLevel = level
+	}
+	g.charted++
+	g.levelSum += level
+}
+
+func (g *groupStats) averageLevel() float64 {
+	if g.charted == 0 {
+		return 0
+	}
+
+	return g.levelSum / float64(g.charted)
+}
+
+// statsGroups collects the statistics of songs grouped by key.
+type statsGroups map[string]*groupStats
+
+func (gs statsGroups) add(key string, level float64) {
+	g, ok := gs[key]
+	if !ok {
+		g = &groupStats{key: key}
+		gs[key] = g
+	}
+	g.add(level)
+}
+
+// sorted returns the groups with the most songs first.
+func (gs statsGroups) sorted() []*groupStats {
+	sorted := make([]*groupStats, 0, len(gs))
+	for _, g := range gs {
+		sorted = append(sorted, g)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].songs != sorted[j].songs {
+			return sorted[i].songs > sorted[j].songs
+		}
+		return sorted[i].key < sorted[j].key
+	})
+	return sorted
+}
+
+// statsGroupKeys returns the function used to group songs for the given
+// -by value. An empty key excludes the song from the report.
+var statsGroupKeys = map[string]func(s *score) string{
+	"artist": func(s *score) string {
+		return strings.TrimSpace(s.SongInformation.Artist)
+	},
+	"charter": func(s *score) string {
+		// DTXMania has no dedicated charter field, charters are usually
+		// credited in the #COMMENT of the chart.
+		return strings.TrimSpace(s.SongInformation.Comment)
+	},
+	"year": func(s *score) string {
+		// The chart files are not parsed, so the year the chart file was
+		// last modified is the best indication of when it was added.
+		date := s.FileInformation.LastModified.String()
+		if len(date) < 4 {
+			return ""
+		}
+		return date[:4]
+	},
+	"pack": func(s *score) string {
+		if s.Pack == "" {
+			return "(no pack)"
+		}
+		return s.Pack
+	},
+}
+
+func runStats(args []string) {
+	flags := newFlagSet("stats")
+	input := inputDBFlag(flags)
+	by := flags.String("by", "artist", "group songs by `key`: artist, charter, year or pack")
+	partName := flags.String("part", "drums", "`instrument` used for level statistics: drums, guitar or bass")
+	packsPath := packFlag(flags)
+	mmapFlag(flags)
+	readerFlags(flags)
+	overridesFlag(flags)
+	flags.Parse(args)
+	packs := loadPackFlag(*packsPath)
+
+	groupKey, ok := statsGroupKeys[*by]
+	if !ok {
+		log.Fatalf("unknown grouping %q\n", *by)
+	}
+	part, err := dtxdb.ParseInstrument(*partName)
+	logFatalIfError(err)
+
+	openSongsDB(*input)
+	defer file.Close()
+
+	groups := make(statsGroups)
+	for {
+		var s score
+		if !readNextScore(&s) {
+			break
+		}
+		s.Pack = packs.packOf(&s)
+
+		if key := groupKey(&s); key != "" {
+			groups.add(key, s.SongInformation.CombinedLevel(part))
+		}
+	}
+
+	sorted := groups.sorted()
+	if *by == "year" {
+		printGrowth(sorted)
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "%s\tSONGS\tAVG %s\tMIN\tMAX\n", strings.ToUpper(*by), strings.ToUpper(part.String()))
+	for _, g := range sorted {
+		fmt.Fprintf(w, "%s\t%d\t%.2f\t%.2f\t%.2f\n", g.key, g.songs, g.averageLevel(), g.minLevel, g.maxLevel)
+	}
+	logFatalIfError(w.Flush())
+}
+
+// printGrowth prints the number of songs per year along with the size of the
+// library at the end of that year.
+func printGrowth(years []*groupStats) {
+	sort.Slice(years, func(i, j int) bool {
+		return years[i].key < years[j].key
+	})
+
+	most := 0
+	for _, y := range years {
+		if y.songs > most {
+			most = y.songs
+		}
+	}
+
+	const barWidth = 40
+	total := 0
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "YEAR\tADDED\tTOTAL\t")
+	for _, y := range years {
+		total += y.songs
+		bar := strings.Repeat("#", (y.songs*barWidth+most-1)/most)
+		fmt.Fprintf(w, "%s\t%d\t%d\t%s\n", y.key, y.songs, total, bar)
+	}
+	logFatalIfError(w.Flush())
+}
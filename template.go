@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/SirChronus/dtxmania-dbdump/dtxdb"
+)
+
+// Templates given with dump -template are executed for every record, with
+// the score as data. Templates named header and footer defined in the
+// file are executed before the first and after the last record.
+
+var templateFuncs = template.FuncMap{
+	"field": func(s *score, name string) (string, error) {
+		f, ok := lookupField(s, name)
+		if !ok {
+			return "", fmt.Errorf("unknown field %q", name)
+		}
+		return f.String(), nil
+	},
+	"level": func(s *score, instrument string) (float64, error) {
+		i, err := dtxdb.ParseInstrument(instrument)
+		if err != nil {
+			return 0, err
+		}
+		return s.SongInformation.CombinedLevel(i), nil
+	},
+	"rank": func(s *score, instrument string) (string, error) {
+		i, err := dtxdb.ParseInstrument(instrument)
+		if err != nil {
+			return "", err
+		}
+		if r := s.SongInformation.BestRank.Get(i); r != dtxdb.NoRank {
+			return dtxdb.RankName(r), nil
+		}
+		return "", nil
+	},
+}
+
+// templateFormat returns the format rendering records through the template
+// in file name. Its extension is the one before .tmpl, e.g. bbcode for
+// list.bbcode.tmpl, or else txt.
+func templateFormat(name string) (dumpFormat, error) {
+	t, err := template.New(filepath.Base(name)).Funcs(templateFuncs).ParseFiles(name)
+	if err != nil {
+		return dumpFormat{}, err
+	}
+	ext := strings.TrimPrefix(filepath.Ext(strings.TrimSuffix(filepath.Base(name), ".tmpl")), ".")
+	if ext == "" {
+		ext = "txt"
+	}
+	newWriter := func(w io.Writer) (recordWriter, error) {
+		return newTemplateWriter(w, t)
+	}
+	return dumpFormat{ext: ext, newWriter: newWriter, newPart: newWriter}, nil
+}
+
+type templateWriter struct {
+	w io.Writer
+	t *template.Template
+}
+
+func newTemplateWriter(w io.Writer, t *template.Template) (recordWriter, error) {
+	if header := t.Lookup("header"); header != nil {
+		if err := header.Execute(w, nil); err != nil {
+			return nil, err
+		}
+	}
+	return &templateWriter{w, t}, nil
+}
+
+func (t *templateWriter) write(s *score) error {
+	return t.t.Execute(t.w, s)
+}
+
+// buffered is always 0, every record is rendered straight to w.
+func (t *templateWriter) buffered() int {
+	return 0
+}
+
+func (t *templateWriter) close() error {
+	if footer := t.t.Lookup("footer"); footer != nil {
+		return footer.Execute(t.w, nil)
+	}
+	return nil
+}
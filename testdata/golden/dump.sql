@@ -0,0 +1,57 @@
+DROP TABLE IF EXISTS `dtx_songs`;
+CREATE TABLE `dtx_songs` (
+  `id` INT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+  `file_info_absolute_file_path` TEXT NOT NULL,
+  `file_info_absolute_folder_path` TEXT NOT NULL,
+  `file_info_last_modified` DATETIME(6) NULL,
+  `file_info_file_size` BIGINT NOT NULL,
+  `song_ini_info_last_modified` DATETIME(6) NULL,
+  `song_ini_info_file_size` BIGINT NOT NULL,
+  `title` TEXT NOT NULL,
+  `artist` TEXT NOT NULL,
+  `comment` TEXT NOT NULL,
+  `genre` TEXT NOT NULL,
+  `pre_image` TEXT NOT NULL,
+  `pre_movie` TEXT NOT NULL,
+  `pre_sound` TEXT NOT NULL,
+  `background` TEXT NOT NULL,
+  `level_drums` INT NOT NULL,
+  `level_guitar` INT NOT NULL,
+  `level_bass` INT NOT NULL,
+  `level_dec_drums` INT NOT NULL,
+  `level_dec_guitar` INT NOT NULL,
+  `level_dec_bass` INT NOT NULL,
+  `best_rank_drums` INT NOT NULL,
+  `best_rank_guitar` INT NOT NULL,
+  `best_rank_bass` INT NOT NULL,
+  `high_skill_drums` DOUBLE NULL,
+  `high_skill_guitar` DOUBLE NULL,
+  `high_skill_bass` DOUBLE NULL,
+  `full_combo_drums` TINYINT(1) NOT NULL,
+  `full_combo_guitar` TINYINT(1) NOT NULL,
+  `full_combo_bass` TINYINT(1) NOT NULL,
+  `nb_performance_drums` INT NOT NULL,
+  `nb_performance_guitar` INT NOT NULL,
+  `nb_performance_bass` INT NOT NULL,
+  `performance_history_first` TEXT NOT NULL,
+  `performance_history_second` TEXT NOT NULL,
+  `performance_history_third` TEXT NOT NULL,
+  `performance_history_fourth` TEXT NOT NULL,
+  `performance_history_fifth` TEXT NOT NULL,
+  `hidden_level` TINYINT(1) NOT NULL,
+  `classic_drums` TINYINT(1) NOT NULL,
+  `classic_guitar` TINYINT(1) NOT NULL,
+  `classic_bass` TINYINT(1) NOT NULL,
+  `score_exists_drums` TINYINT(1) NOT NULL,
+  `score_exists_guitar` TINYINT(1) NOT NULL,
+  `score_exists_bass` TINYINT(1) NOT NULL,
+  `song_type` VARCHAR(16) NOT NULL,
+  `bpm` DOUBLE NULL,
+  `duration` INT NOT NULL,
+  `pack` TEXT NOT NULL,
+  `sort_key` TEXT NOT NULL,
+  `source` TEXT NOT NULL
+) DEFAULT CHARSET=utf8mb4;
+INSERT INTO `dtx_songs` (`file_info_absolute_file_path`, `file_info_absolute_folder_path`, `file_info_last_modified`, `file_info_file_size`, `song_ini_info_last_modified`, `song_ini_info_file_size`, `title`, `artist`, `comment`, `genre`, `pre_image`, `pre_movie`, `pre_sound`, `background`, `level_drums`, `level_guitar`, `level_bass`, `level_dec_drums`, `level_dec_guitar`, `level_dec_bass`, `best_rank_drums`, `best_rank_guitar`, `best_rank_bass`, `high_skill_drums`, `high_skill_guitar`, `high_skill_bass`, `full_combo_drums`, `full_combo_guitar`, `full_combo_bass`, `nb_performance_drums`, `nb_performance_guitar`, `nb_performance_bass`, `performance_history_first`, `performance_history_second`, `performance_history_third`, `performance_history_fourth`, `performance_history_fifth`, `hidden_level`, `classic_drums`, `classic_guitar`, `classic_bass`, `score_exists_drums`, `score_exists_guitar`, `score_exists_bass`, `song_type`, `bpm`, `duration`, `pack`, `sort_key`, `source`) VALUES
+('C:\\DTXFiles\\One\\ext.dtx', 'C:\\DTXFiles\\One\\', '2022-02-09 10:40:00.000000', 12345, '2022-02-09 10:40:01.000000', 678, 'One', 'Artist', 'コメント', 'Anime', 'pre.png', 'pre.avi', 'pre.ogg', 'bg.png', 85, 70, 60, 5, 0, 3, 1, 99, 99, 142.5, 0, 0, 1, 0, 0, 12, 0, 1, '22/01/02 Drums:Cleared', '', '', '', '', 1, 0, 1, 0, 1, 0, 1, 'DTX', 180.5, 123456, '', '', ''),
+('C:\\DTXFiles\\Two <&> "|,\'\n\\ext.dtx', 'C:\\DTXFiles\\Two <&> "|,\'\n\\', '2022-02-09 10:40:00.000000', 12345, '2022-02-09 10:40:01.000000', 678, 'Two <&> "|,\'\n', 'Artist', 'コメント', 'Anime', 'pre.png', 'pre.avi', 'pre.ogg', 'bg.png', 85, 70, 60, 5, 0, 3, 1, 99, 99, 142.5, 0, 0, 1, 0, 0, 12, 0, 1, '22/01/02 Drums:Cleared', '', '', '', '', 1, 0, 1, 0, 1, 0, 1, 'DTX', 180.5, 123456, '', '', '');
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/SirChronus/dtxmania-dbdump/dtxdb"
+)
+
+// tomlWriter writes every record as a [[song]] table, the elements of the
+// XML dump becoming keys and sub-tables of the same names.
+type tomlWriter struct {
+	w *bufio.Writer
+}
+
+func newTOMLWriter(w io.Writer) (recordWriter, error) {
+	return &tomlWriter{bufio.NewWriter(w)}, nil
+}
+
+func (t *tomlWriter) write(s *score) error {
+	fmt.Fprint(t.w, "[[song]]\n")
+	writeTOMLTable(t.w, "song", reflect.ValueOf(s).Elem())
+	t.w.WriteByte('\n')
+	return t.w.Flush()
+}
+
+// buffered is always 0, every record is flushed.
+func (t *tomlWriter) buffered() int {
+	return 0
+}
+
+func (t *tomlWriter) close() error {
+	return nil
+}
+
+// elementFields lists the fields of v under their XML names, flattening the
+// embedded dtxdb.Score.
+func elementFields(v reflect.Value) (names []string, values []reflect.Value) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Anonymous {
+			n, vs := elementFields(v.Field(i))
+			names, values = append(names, n...), append(values, vs...)
+			continue
+		}
+		tag := strings.Split(f.Tag.Get("xml"), ",")
+		if f.Name == "XMLName" || tag[0] == "" || tag[0] == "-" {
+			continue
+		}
+		if len(tag) > 1 && tag[1] == "omitempty" && v.Field(i).IsZero() {
+			continue
+		}
+		names, values = append(names, tag[0]), append(values, v.Field(i))
+	}
+	return names, values
+}
+
+// writeTOMLTable writes the values of the table named path, then its
+// sub-tables since TOML takes every key after a table header as part of
+// that table.
+func writeTOMLTable(w *bufio.Writer, path string, v reflect.Value) {
+	names, values := elementFields(v)
+	for i, f := range values {
+		if !nestedType(f.Type()) {
+			fmt.Fprintf(w, "%s = %s\n", names[i], tomlValue(f))
+		}
+	}
+	for i, f := range values {
+		if nestedType(f.Type()) {
+			sub := path + "." + names[i]
+			fmt.Fprintf(w, "[%s]\n", sub)
+			writeTOMLTable(w, sub, f)
+		}
+	}
+}
+
+func tomlValue(v reflect.Value) string {
+	switch x := v.Interface().(type) {
+	case dtxdb.SongType:
+		return tomlString(x.String())
+	case dtxdb.Date:
+		// Dates are TOML offset date-times, unless they are out of range.
+		if x.Year() >= 0 && x.Year() <= 9999 {
+			return x.String()
+		}
+		return tomlString(x.String())
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		return tomlString(v.String())
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool())
+	case reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10)
+	case reflect.Float64:
+		f := v.Float()
+		switch {
+		case math.IsNaN(f):
+			return "nan"
+		case math.IsInf(f, 1):
+			return "inf"
+		case math.IsInf(f, -1):
+			return "-inf"
+		}
+		s := strconv.FormatFloat(f, 'g', -1, 64)
+		if !strings.ContainsAny(s, ".e") {
+			s += ".0"
+		}
+		return s
+	}
+	panic(fmt.Sprintf("toml: unsupported field type %s", v.Type()))
+}
+
+// tomlString quotes s as a TOML basic string.
+func tomlString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		default:
+			if r < 0x20 || r == 0x7f {
+				fmt.Fprintf(&b, `\u%04X`, r)
+			} else {
+				b.WriteRune(r)
+			}
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
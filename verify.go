@@ -0,0 +1,181 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/SirChronus/dtxmania-dbdump/dtxdb"
+)
+
+// verify decodes songs.db and encodes every record again with the writer
+// of the commands writing databases, reporting the fields whose bytes come
+// out different. With -via the records also go through a dump format and
+// back first, as with dump and encode.
+
+// encodeVersion returns the bytes the version header v is stored as.
+func encodeVersion(v string) []byte {
+	var buf bytes.Buffer
+	logFatalIfError(dtxdb.NewLayoutWriter(&buf, v, dbReader.Layout()).Flush())
+	return buf.Bytes()
+}
+
+// dbFields lists the fields of s that are stored in songs.db, in the order
+// of the database.
+func dbFields(s *score) []scoreField {
+	var fields []scoreField
+	appendFields(&fields, "", reflect.ValueOf(&s.Score).Elem())
+	return fields
+}
+
+// splitDBRecord splits the bytes of a record into those of its fields. The
+// fields missing at the end of a short record are nil.
+func splitDBRecord(b []byte, fields []scoreField) [][]byte {
+	parts := make([][]byte, len(fields))
+	for i, f := range fields {
+		size := 0
+		switch {
+		case f.value.Type() == dateType, f.value.Kind() == reflect.Int64, f.value.Kind() == reflect.Float64:
+			size = 8
+		case f.value.Kind() == reflect.String:
+			length, n := binary.Uvarint(b)
+			if n <= 0 || length > uint64(len(b)-n) {
+				return parts
+			}
+			size = n + int(length)
+		case f.value.Kind() == reflect.Int32:
+			size = 4
+		case f.value.Kind() == reflect.Bool:
+			size = 1
+		}
+		if size > len(b) {
+			return parts
+		}
+		parts[i], b = b[:size], b[size:]
+	}
+	return parts
+}
+
+// shortHex formats b in hex, cut after 16 bytes.
+func shortHex(b []byte) string {
+	if b == nil {
+		return "nothing"
+	}
+	if len(b) > 16 {
+		return fmt.Sprintf("% x …", b[:16])
+	}
+	return fmt.Sprintf("% x", b)
+}
+
+// throughDumpFormat writes scores in the dump format named via and reads
+// them back like encode does.
+func throughDumpFormat(scores []score, via string) []score {
+	read := dumpFormats[via].read
+	if read == nil {
+		log.Fatalf("cannot read %s dumps back, use one of %s\n", via, strings.Join(readableFormats(), ", "))
+	}
+	format, err := lookupDumpFormat(via)
+	logFatalIfError(err)
+
+	var buf bytes.Buffer
+	out, err := format.newWriter(&buf)
+	logFatalIfError(err)
+	for i := range scores {
+		logFatalIfError(out.write(&scores[i]))
+	}
+	logFatalIfError(out.close())
+
+	back, err := read(buf.Bytes(), func(path, problem string) {
+		fmt.Printf("%s dump: %s: %s\n", via, path, problem)
+	})
+	if err != nil {
+		log.Fatalf("%s dump: %v\n", via, err)
+	}
+	if len(back) != len(scores) {
+		log.Fatalf("%s dump: %d songs written, %d read back\n", via, len(scores), len(back))
+	}
+	return back
+}
+
+func runVerify(args []string) {
+	flags := newFlagSet("verify")
+	input := inputDBFlag(flags)
+	via := flags.String("via", "", "also write the records as a dump in `format` and read them back, one of "+strings.Join(readableFormats(), ", "))
+	readerFlags(flags)
+	flags.Parse(args)
+
+	// Read whole, as the bytes of every record are compared.
+	f := openDBFile(*input)
+	data, err := io.ReadAll(f)
+	f.Close()
+	logFatalIfError(err)
+	dbReader, err = dtxdb.NewReader(bytes.NewReader(data), readerOptions()...)
+	logFatalIfError(err)
+	warnFallbackLayout(dbReader)
+	versionString := dbReader.Version()
+
+	bad := 0
+	if header := data[:dbReader.Offset()]; !bytes.Equal(encodeVersion(versionString), header) {
+		fmt.Printf("%s: the version %q does not round-trip\n", *input, versionString)
+		bad++
+	}
+
+	var scores []score
+	var starts []int64
+	end := dbReader.Offset()
+	for {
+		var s score
+		if !readNextScore(&s) {
+			break
+		}
+		scores = append(scores, s)
+		starts = append(starts, end)
+		end = dbReader.Offset()
+	}
+	if end < int64(len(data)) {
+		fmt.Printf("%s: %d bytes after the last record are left out\n", *input, int64(len(data))-end)
+		bad++
+	}
+
+	encoded := scores
+	if *via != "" {
+		encoded = throughDumpFormat(scores, *via)
+	}
+
+	for i := range scores {
+		recordEnd := end
+		if i+1 < len(starts) {
+			recordEnd = starts[i+1]
+		}
+		want := data[starts[i]:recordEnd]
+		got, err := encoded[i].MarshalBinary()
+		logFatalIfError(err)
+		if bytes.Equal(got, want) {
+			continue
+		}
+		bad++
+
+		fields, encodedFields := dbFields(&scores[i]), dbFields(&encoded[i])
+		wantParts, gotParts := splitDBRecord(want, fields), splitDBRecord(got, fields)
+		for j, f := range fields {
+			if bytes.Equal(wantParts[j], gotParts[j]) {
+				continue
+			}
+			if was, is := f.String(), encodedFields[j].String(); was != is {
+				fmt.Printf("%s: record %d (%s): %s: %q came back as %q\n", *input, i+1, scores[i].FileInformation.AbsoluteFilePath, f.name, was, is)
+			} else {
+				fmt.Printf("%s: record %d (%s): %s: %s re-encoded as %s\n", *input, i+1, scores[i].FileInformation.AbsoluteFilePath, f.name, shortHex(wantParts[j]), shortHex(gotParts[j]))
+			}
+		}
+	}
+
+	log.Printf("%d records, %d problems\n", len(scores), bad)
+	if bad > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/SirChronus/dtxmania-dbdump/dtxdb"
+)
+
+// fileDate converts the modification time of a file to the date DTXMania
+// records for it. DTXMania stores the local wall clock time as ticks,
+// which dtxdb.DateFromTicks reads as if it was UTC.
+func fileDate(t time.Time) dtxdb.Date {
+	wall := time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+	return dtxdb.DateFromTime(wall)
+}
+
+// writeSongsDB writes a complete database with the given version string and
+// records to path. A version without a layout is written with the layout
+// of -fallback-layout, the one its records were read with.
+func writeSongsDB(path string, versionString string, scores []score) {
+	l, ok := dtxdb.LookupLayout(versionString)
+	if !ok && fallbackLayout != "" {
+		l, ok = dtxdb.LookupLayout(fallbackLayout)
+	}
+	if !ok {
+		logFatalIfError(&dtxdb.ErrUnsupportedVersion{Version: versionString})
+	}
+
+	f, err := os.Create(path)
+	logFatalIfError(err)
+	defer f.Close()
+
+	w := dtxdb.NewLayoutWriter(f, versionString, l)
+	for i := range scores {
+		logFatalIfError(w.Write(&scores[i].Score))
+	}
+
+	logFatalIfError(w.Flush())
+}
+
+// writesDB is set by outputDBFlag, a database ending within a record then
+// stops the command unless dropTruncated is set, as the new database would
+// silently miss the record.
+var writesDB, dropTruncated bool
+
+// outputDBFlag registers the -o flag of the commands writing a new songs.db,
+// also spelled -output, and -drop-truncated. The original database is
+// never overwritten by default so it can be kept as a backup.
+func outputDBFlag(flags *flag.FlagSet) *string {
+	output := flags.String("o", "songs.new.db", "write the resulting database to `file`")
+	aliasFlag(flags, "output", "o")
+	flags.BoolVar(&dropTruncated, "drop-truncated", false, "write the database without the incomplete last record of a truncated input")
+	writesDB = true
+	return output
+}
+
+// versionFlag registers the -version flag of the commands writing a
+// database from scratch, checked with checkVersion.
+func versionFlag(flags *flag.FlagSet) *string {
+	return flags.String("version", dtxdb.SupportedVersions[0], "write the database as version `string`, one of "+strings.Join(dtxdb.SupportedVersions, ", "))
+}
+
+// checkVersion fails for versions without a layout, which could not be
+// written.
+func checkVersion(version string) error {
+	if _, ok := dtxdb.LookupLayout(version); !ok {
+		return fmt.Errorf("unknown version %q, use one of %s", version, strings.Join(dtxdb.SupportedVersions, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,321 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/SirChronus/dtxmania-dbdump/dtxdb"
+)
+
+// Excel workbooks are written as the minimal set of SpreadsheetML parts
+// Excel and LibreOffice open: a workbook, one worksheet per sheet with
+// inline strings and a stylesheet for bold header rows.
+
+type xlsxSheet struct {
+	name string
+	rows [][]interface{} // cells are string, float64 or bool
+}
+
+func (sh *xlsxSheet) add(cells ...interface{}) {
+	sh.rows = append(sh.rows, cells)
+}
+
+// xlsxWriter keeps every record until close since the Stats and Lamps
+// sheets summarise all of them.
+type xlsxWriter struct {
+	w      io.Writer
+	scores []score
+}
+
+func newXLSXWriter(w io.Writer) (recordWriter, error) {
+	return &xlsxWriter{w: w}, nil
+}
+
+func (x *xlsxWriter) write(s *score) error {
+	x.scores = append(x.scores, *s)
+	return nil
+}
+
+func (x *xlsxWriter) close() error {
+	sheets := []*xlsxSheet{songsSheet(x.scores)}
+	for _, i := range dtxdb.Instruments {
+		sheets = append(sheets, instrumentSheet(x.scores, i))
+	}
+	sheets = append(sheets, statsSheet(x.scores), lampsSheet(x.scores))
+	return writeWorkbook(x.w, sheets)
+}
+
+// songsSheet has a column for every field of the XML dump.
+func songsSheet(scores []score) *xlsxSheet {
+	sh := &xlsxSheet{name: "Songs"}
+	var header []interface{}
+	for _, name := range fieldNames() {
+		header = append(header, name)
+	}
+	sh.add(header...)
+
+	for i := range scores {
+		var row []interface{}
+		for _, f := range scoreFields(&scores[i]) {
+			row = append(row, xlsxValue(f))
+		}
+		sh.add(row...)
+	}
+	return sh
+}
+
+func xlsxValue(f scoreField) interface{} {
+	if f.value.Type().Implements(stringerType) {
+		return f.String()
+	}
+	switch f.value.Kind() {
+	case reflect.Int32, reflect.Int64:
+		return float64(f.value.Int())
+	case reflect.Float64:
+		return f.value.Float()
+	case reflect.Bool:
+		return f.value.Bool()
+	}
+	return f.String()
+}
+
+// statsSheet is the stats command grouped by artist, for every instrument.
+func statsSheet(scores []score) *xlsxSheet {
+	sh := &xlsxSheet{name: "Stats"}
+	header := []interface{}{"artist", "songs"}
+	for _, i := range dtxdb.Instruments {
+		header = append(header, "avg "+i.String(), "min "+i.String(), "max "+i.String())
+	}
+	sh.add(header...)
+
+	byInstrument := make([]statsGroups, len(dtxdb.Instruments))
+	for n := range byInstrument {
+		byInstrument[n] = make(statsGroups)
+	}
+	for i := range scores {
+		key := statsGroupKeys["artist"](&scores[i])
+		if key == "" {
+			continue
+		}
+		for n, part := range dtxdb.Instruments {
+			byInstrument[n].add(key, scores[i].SongInformation.CombinedLevel(part))
+		}
+	}
+
+	for _, g := range byInstrument[0].sorted() {
+		row := []interface{}{g.key, float64(g.songs)}
+		for _, groups := range byInstrument {
+			p := groups[g.key]
+			row = append(row, p.averageLevel(), p.minLevel, p.maxLevel)
+		}
+		sh.add(row...)
+	}
+	return sh
+}
+
+// lamp is the clear lamp DTXMania shows for the part of a chart.
+func lamp(s *dtxdb.SongInformation, i dtxdb.Instrument) string {
+	switch {
+	case s.Level.Get(i) == 0:
+		return ""
+	case s.FullCombo.Get(i):
+		return "FULL COMBO"
+	case s.BestRank.Get(i) != dtxdb.NoRank:
+		return "CLEAR " + s.BestRankLetter(i)
+	case s.NbPerformance.Get(i) > 0:
+		return "FAILED"
+	}
+	return "NO PLAY"
+}
+
+func lampsSheet(scores []score) *xlsxSheet {
+	sh := &xlsxSheet{name: "Lamps"}
+	header := []interface{}{"title", "artist"}
+	for _, i := range dtxdb.Instruments {
+		header = append(header, i.String())
+	}
+	sh.add(header...)
+
+	for n := range scores {
+		info := &scores[n].SongInformation
+		row := []interface{}{info.Title, info.Artist}
+		for _, i := range dtxdb.Instruments {
+			row = append(row, lamp(info, i))
+		}
+		sh.add(row...)
+	}
+	return sh
+}
+
+// instrumentSheet lists the charts having a part for instrument i with
+// their level and play data, for browsing the scores of one instrument.
+func instrumentSheet(scores []score, i dtxdb.Instrument) *xlsxSheet {
+	name := i.String()
+	sh := &xlsxSheet{name: strings.ToUpper(name[:1]) + name[1:]}
+	sh.add("title", "artist", "chart", "level", "best rank", "high skill", "skill points", "full combo", "plays", "lamp")
+
+	for n := range scores {
+		s := &scores[n]
+		info := &s.SongInformation
+		if info.Level.Get(i) == 0 {
+			continue
+		}
+		rank := ""
+		if info.BestRank.Get(i) != dtxdb.NoRank {
+			rank = info.BestRankLetter(i)
+		}
+		sh.add(info.Title, info.Artist, chartFileName(s), info.CombinedLevel(i), rank, info.HighSkill.Get(i),
+			skillPoints(s, i), info.FullCombo.Get(i), float64(info.NbPerformance.Get(i)), lamp(info, i))
+	}
+	return sh
+}
+
+// xlsxColumn returns the name of the zero based column n: A, B, ... AA.
+func xlsxColumn(n int) string {
+	name := ""
+	for n++; n > 0; n = (n - 1) / 26 {
+		name = string(rune('A'+(n-1)%26)) + name
+	}
+	return name
+}
+
+func xlsxText(buf *bytes.Buffer, s string) {
+	xml.EscapeText(buf, []byte(s))
+}
+
+func writeSheetXML(buf *bytes.Buffer, sh *xlsxSheet) {
+	columns := 0
+	for _, row := range sh.rows {
+		if len(row) > columns {
+			columns = len(row)
+		}
+	}
+
+	buf.WriteString(xml.Header)
+	buf.WriteString(`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">`)
+	buf.WriteString(`<sheetViews><sheetView workbookViewId="0">`)
+	buf.WriteString(`<pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" state="frozen"/>`)
+	buf.WriteString(`</sheetView></sheetViews><sheetData>`)
+	for r, row := range sh.rows {
+		fmt.Fprintf(buf, `<row r="%d">`, r+1)
+		for c, v := range row {
+			ref := xlsxColumn(c) + strconv.Itoa(r+1)
+			style := ""
+			if r == 0 {
+				style = ` s="1"`
+			}
+			switch v := v.(type) {
+			case float64:
+				if !math.IsNaN(v) && !math.IsInf(v, 0) {
+					fmt.Fprintf(buf, `<c r="%s"%s><v>%s</v></c>`, ref, style, strconv.FormatFloat(v, 'g', -1, 64))
+					continue
+				}
+				fmt.Fprintf(buf, `<c r="%s"%s t="inlineStr"><is><t>%v</t></is></c>`, ref, style, v)
+			case bool:
+				b := 0
+				if v {
+					b = 1
+				}
+				fmt.Fprintf(buf, `<c r="%s"%s t="b"><v>%d</v></c>`, ref, style, b)
+			default:
+				fmt.Fprintf(buf, `<c r="%s"%s t="inlineStr"><is><t xml:space="preserve">`, ref, style)
+				xlsxText(buf, fmt.Sprint(v))
+				buf.WriteString(`</t></is></c>`)
+			}
+		}
+		buf.WriteString(`</row>`)
+	}
+	buf.WriteString(`</sheetData>`)
+	if columns > 0 {
+		fmt.Fprintf(buf, `<autoFilter ref="A1:%s%d"/>`, xlsxColumn(columns-1), len(sh.rows))
+	}
+	buf.WriteString(`</worksheet>`)
+}
+
+const xlsxStyles = xml.Header + `<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+	`<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>` +
+	`<fills count="3"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill>` +
+	`<fill><patternFill patternType="solid"><fgColor rgb="FFD9E1F2"/><bgColor indexed="64"/></patternFill></fill></fills>` +
+	`<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>` +
+	`<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>` +
+	`<cellXfs count="2"><xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/>` +
+	`<xf numFmtId="0" fontId="1" fillId="2" borderId="0" xfId="0" applyFont="1" applyFill="1"/></cellXfs>` +
+	`</styleSheet>`
+
+type xlsxPart struct {
+	name string
+	data []byte
+}
+
+func writeWorkbook(w io.Writer, sheets []*xlsxSheet) error {
+	var contentTypes, workbook, workbookRels bytes.Buffer
+
+	contentTypes.WriteString(xml.Header)
+	contentTypes.WriteString(`<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">`)
+	contentTypes.WriteString(`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>`)
+	contentTypes.WriteString(`<Default Extension="xml" ContentType="application/xml"/>`)
+	contentTypes.WriteString(`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>`)
+	contentTypes.WriteString(`<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>`)
+
+	workbook.WriteString(xml.Header)
+	workbook.WriteString(`<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets>`)
+
+	workbookRels.WriteString(xml.Header)
+	workbookRels.WriteString(`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">`)
+
+	var definedNames bytes.Buffer
+	for n, sh := range sheets {
+		id := n + 1
+		fmt.Fprintf(&contentTypes, `<Override PartName="/xl/worksheets/sheet%d.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>`, id)
+		fmt.Fprintf(&workbook, `<sheet name="%s" sheetId="%d" r:id="rId%d"/>`, sh.name, id, id)
+		fmt.Fprintf(&workbookRels, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet%d.xml"/>`, id, id)
+		if len(sh.rows) > 0 {
+			fmt.Fprintf(&definedNames, `<definedName name="_xlnm._FilterDatabase" localSheetId="%d" hidden="1">%s!$A$1:$%s$%d</definedName>`,
+				n, sh.name, xlsxColumn(len(sh.rows[0])-1), len(sh.rows))
+		}
+	}
+	contentTypes.WriteString(`</Types>`)
+	workbook.WriteString(`</sheets>`)
+	if definedNames.Len() > 0 {
+		workbook.WriteString(`<definedNames>`)
+		workbook.Write(definedNames.Bytes())
+		workbook.WriteString(`</definedNames>`)
+	}
+	workbook.WriteString(`</workbook>`)
+	fmt.Fprintf(&workbookRels, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>`, len(sheets)+1)
+	workbookRels.WriteString(`</Relationships>`)
+
+	parts := []xlsxPart{
+		{"[Content_Types].xml", contentTypes.Bytes()},
+		{"_rels/.rels", []byte(xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+			`</Relationships>`)},
+		{"xl/workbook.xml", workbook.Bytes()},
+		{"xl/_rels/workbook.xml.rels", workbookRels.Bytes()},
+		{"xl/styles.xml", []byte(xlsxStyles)},
+	}
+	for n, sh := range sheets {
+		var buf bytes.Buffer
+		writeSheetXML(&buf, sh)
+		parts = append(parts, xlsxPart{fmt.Sprintf("xl/worksheets/sheet%d.xml", n+1), buf.Bytes()})
+	}
+
+	zw := zip.NewWriter(w)
+	for _, p := range parts {
+		f, err := createZipFile(zw, p.name)
+		if err != nil {
+			return err
+		}
+		if _, err := f.Write(p.data); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
@@ -0,0 +1,318 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/SirChronus/dtxmania-dbdump/dtxdb"
+)
+
+// The XML Schema of dump.xml is derived from the XML tags of score like
+// the other schemas: a complex type per struct whose elements come in dump
+// order. validate checks dumps against the same description, so the two
+// cannot disagree.
+
+// songTypePattern matches the song types of corrupt databases the way
+// SongType.String writes them.
+const songTypePattern = `SongType\(-?[0-9]+\)`
+
+func xsdType(t reflect.Type) string {
+	switch t {
+	case songTypeType:
+		return "SongType"
+	case dateType:
+		return "xs:dateTime"
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return "xs:string"
+	case reflect.Bool:
+		return "xs:boolean"
+	case reflect.Int32:
+		return "xs:int"
+	case reflect.Int64:
+		return "xs:long"
+	case reflect.Float64:
+		return "xs:double"
+	case reflect.Struct:
+		return schemaTypeName(t)
+	}
+	panic("xsd: unsupported type " + t.String())
+}
+
+// optionalElement tells whether the element of f is left out of dumps when
+// it is empty.
+func optionalElement(f reflect.StructField) bool {
+	return strings.Contains(f.Tag.Get("xml"), ",omitempty")
+}
+
+// writeXSDTypes writes the complex type of t and then those of the structs
+// it uses that are not defined yet.
+func writeXSDTypes(b *strings.Builder, t reflect.Type, defined map[reflect.Type]bool) {
+	defined[t] = true
+	fields := schemaFields(t)
+	fmt.Fprintf(b, "  <xs:complexType name=\"%s\">\n    <xs:sequence>\n", schemaTypeName(t))
+	for _, f := range fields {
+		occurs := ""
+		if optionalElement(f) {
+			occurs = ` minOccurs="0"`
+		}
+		fmt.Fprintf(b, "      <xs:element name=\"%s\" type=\"%s\"%s/>\n", xmlFieldName(f), xsdType(f.Type), occurs)
+	}
+	b.WriteString("    </xs:sequence>\n  </xs:complexType>\n")
+	for _, f := range fields {
+		if nestedType(f.Type) && !defined[f.Type] {
+			writeXSDTypes(b, f.Type, defined)
+		}
+	}
+}
+
+// xsdSchema returns the XML Schema of dump.xml.
+func xsdSchema() ([]byte, error) {
+	var b strings.Builder
+	b.WriteString(xml.Header)
+	b.WriteString("<xs:schema xmlns:xs=\"http://www.w3.org/2001/XMLSchema\">\n")
+	b.WriteString("  <xs:element name=\"songs\">\n    <xs:complexType>\n      <xs:sequence>\n")
+	b.WriteString("        <xs:element name=\"song\" type=\"Song\" minOccurs=\"0\" maxOccurs=\"unbounded\"/>\n")
+	b.WriteString("      </xs:sequence>\n    </xs:complexType>\n  </xs:element>\n")
+	writeXSDTypes(&b, reflect.TypeOf(score{}), map[reflect.Type]bool{})
+
+	b.WriteString("  <xs:simpleType name=\"SongType\">\n    <xs:union>\n")
+	b.WriteString("      <xs:simpleType>\n        <xs:restriction base=\"xs:string\">\n")
+	for t := dtxdb.SongType(0); !strings.Contains(t.String(), "("); t++ {
+		fmt.Fprintf(&b, "          <xs:enumeration value=\"%s\"/>\n", t)
+	}
+	b.WriteString("        </xs:restriction>\n      </xs:simpleType>\n")
+	b.WriteString("      <xs:simpleType>\n        <xs:restriction base=\"xs:string\">\n")
+	fmt.Fprintf(&b, "          <xs:pattern value=\"%s\"/>\n", songTypePattern)
+	b.WriteString("        </xs:restriction>\n      </xs:simpleType>\n")
+	b.WriteString("    </xs:union>\n  </xs:simpleType>\n")
+	b.WriteString("</xs:schema>\n")
+	return []byte(b.String()), nil
+}
+
+// dumpValidator checks the elements of a dump.xml against the types of
+// the schema and reports every problem it finds.
+type dumpValidator struct {
+	dec    *xml.Decoder
+	report func(path, problem string)
+}
+
+// next returns the next start or end element. Text between elements must
+// be white space.
+func (v *dumpValidator) next(path string) (xml.Token, error) {
+	for {
+		tok, err := v.dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement, xml.EndElement:
+			return t, nil
+		case xml.CharData:
+			if len(bytes.TrimSpace(t)) > 0 {
+				v.report(path, fmt.Sprintf("unexpected text %q", bytes.TrimSpace(t)))
+			}
+		}
+	}
+}
+
+// complex checks the children of an element of the struct type t.
+func (v *dumpValidator) complex(path string, t reflect.Type) error {
+	fields := schemaFields(t)
+	i := 0
+	for {
+		tok, err := v.next(path)
+		if err != nil {
+			return err
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok {
+			break
+		}
+
+		name := start.Name.Local
+		j := i
+		for j < len(fields) && xmlFieldName(fields[j]) != name {
+			j++
+		}
+		if j == len(fields) {
+			v.report(path, fmt.Sprintf("unexpected element %s", name))
+			if err := v.dec.Skip(); err != nil {
+				return err
+			}
+			continue
+		}
+		for ; i < j; i++ {
+			if !optionalElement(fields[i]) {
+				v.report(path, fmt.Sprintf("missing element %s before %s", xmlFieldName(fields[i]), name))
+			}
+		}
+		i++
+
+		child := path + "/" + name
+		if nestedType(fields[j].Type) {
+			err = v.complex(child, fields[j].Type)
+		} else {
+			err = v.simple(child, fields[j].Type)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	for ; i < len(fields); i++ {
+		if !optionalElement(fields[i]) {
+			v.report(path, fmt.Sprintf("missing element %s", xmlFieldName(fields[i])))
+		}
+	}
+	return nil
+}
+
+// simple checks the text of an element holding a value of type t.
+func (v *dumpValidator) simple(path string, t reflect.Type) error {
+	var text []byte
+	for {
+		tok, err := v.dec.Token()
+		if err != nil {
+			return err
+		}
+		switch tok := tok.(type) {
+		case xml.CharData:
+			text = append(text, tok...)
+		case xml.StartElement:
+			v.report(path, fmt.Sprintf("unexpected element %s", tok.Name.Local))
+			if err := v.dec.Skip(); err != nil {
+				return err
+			}
+		case xml.EndElement:
+			if problem := checkSimpleValue(string(text), t); problem != "" {
+				v.report(path, problem)
+			}
+			return nil
+		}
+	}
+}
+
+// checkSimpleValue returns what is wrong with value as the text of an
+// element of type t, or "".
+func checkSimpleValue(value string, t reflect.Type) string {
+	trimmed := strings.TrimSpace(value)
+	switch t {
+	case songTypeType:
+		if st, err := dtxdb.ParseSongType(trimmed); err != nil || st.String() != trimmed {
+			return fmt.Sprintf("%q is not a song type", value)
+		}
+		return ""
+	case dateType:
+		if _, err := time.Parse(time.RFC3339Nano, trimmed); err != nil {
+			return fmt.Sprintf("%q is not a date", value)
+		}
+		return ""
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		switch trimmed {
+		case "true", "false", "1", "0":
+			return ""
+		}
+		return fmt.Sprintf("%q is not true or false", value)
+	case reflect.Int32, reflect.Int64:
+		if _, err := strconv.ParseInt(trimmed, 10, t.Bits()); err != nil {
+			return fmt.Sprintf("%q is not a %d bit integer", value, t.Bits())
+		}
+	case reflect.Float64:
+		if _, err := strconv.ParseFloat(trimmed, 64); err != nil {
+			return fmt.Sprintf("%q is not a number", value)
+		}
+	}
+	return ""
+}
+
+// validateDump checks the dump.xml read from r, calling report for every
+// problem. It returns the number of songs, and an error if r is no well
+// formed XML.
+func validateDump(r io.Reader, report func(path, problem string)) (int, error) {
+	v := &dumpValidator{newDumpDecoder(r), report}
+	tok, err := v.next("")
+	if err == io.EOF {
+		return 0, errors.New("no songs element")
+	}
+	if err != nil {
+		return 0, err
+	}
+	if start := tok.(xml.StartElement); start.Name.Local != "songs" {
+		return 0, fmt.Errorf("the root element is %s, not songs", start.Name.Local)
+	}
+
+	songs := 0
+	for {
+		tok, err := v.next("songs")
+		if err != nil {
+			return songs, err
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok {
+			break
+		}
+		if start.Name.Local != "song" {
+			report("songs", fmt.Sprintf("unexpected element %s", start.Name.Local))
+			if err := v.dec.Skip(); err != nil {
+				return songs, err
+			}
+			continue
+		}
+		songs++
+		if err := v.complex(fmt.Sprintf("song %d", songs), reflect.TypeOf(score{})); err != nil {
+			return songs, err
+		}
+	}
+
+	if _, err := v.next(""); err != io.EOF {
+		return songs, errors.New("content after the songs element")
+	}
+	return songs, nil
+}
+
+func runValidate(args []string) {
+	flags := newFlagSet("validate")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: %s validate [dump.xml]\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() > 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	name := "dump.xml"
+	if flags.NArg() == 1 {
+		name = flags.Arg(0)
+	}
+
+	f, err := os.Open(name)
+	logFatalIfError(err)
+	defer f.Close()
+
+	bad := 0
+	songs, err := validateDump(f, func(path, problem string) {
+		fmt.Printf("%s: %s: %s\n", name, path, problem)
+		bad++
+	})
+	if err != nil {
+		log.Fatalf("%s: %v\n", name, err)
+	}
+	log.Printf("%d songs, %d problems\n", songs, bad)
+	if bad > 0 {
+		os.Exit(1)
+	}
+}
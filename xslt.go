@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/xml"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// xmlStylesheet is the href of the stylesheet the XML dump refers to, set
+// with dump -xml-stylesheet. Browsers opening the dump render it through
+// the stylesheet.
+var xmlStylesheet string
+
+// dumpXSLT is the stylesheet written by dump -bundled-stylesheet: a table
+// of the songs with the level, best rank and skill of every instrument,
+// like the HTML report without its search.
+const dumpXSLT = `<?xml version="1.0" encoding="UTF-8"?>
+<xsl:stylesheet version="1.0" xmlns:xsl="http://www.w3.org/1999/XSL/Transform">
+<xsl:output method="html" encoding="UTF-8"/>
+
+<xsl:template match="/songs">
+<html>
+<head>
+<meta charset="utf-8"/>
+<title>DTXMania library</title>
+<style>
+body { font-family: sans-serif; margin: 1em; }
+table { border-collapse: collapse; }
+th, td { padding: .2em .6em; border-bottom: 1px solid #ddd; text-align: left; }
+th { background: #d9e1f2; position: sticky; top: 0; }
+td.n { text-align: right; }
+</style>
+</head>
+<body>
+<p><xsl:value-of select="count(song)"/> songs</p>
+<table>
+<thead><tr>
+<th>Title</th><th>Artist</th><th>Genre</th>
+<th>Drums level</th><th>Drums rank</th><th>Drums skill</th>
+<th>Guitar level</th><th>Guitar rank</th><th>Guitar skill</th>
+<th>Bass level</th><th>Bass rank</th><th>Bass skill</th>
+</tr></thead>
+<tbody>
+<xsl:for-each select="song/song-info">
+<tr>
+<td><xsl:value-of select="title"/></td>
+<td><xsl:value-of select="artist"/></td>
+<td><xsl:value-of select="genre"/></td>
+<xsl:call-template name="chart"><xsl:with-param name="instrument" select="'drums'"/></xsl:call-template>
+<xsl:call-template name="chart"><xsl:with-param name="instrument" select="'guitar'"/></xsl:call-template>
+<xsl:call-template name="chart"><xsl:with-param name="instrument" select="'bass'"/></xsl:call-template>
+</tr>
+</xsl:for-each>
+</tbody>
+</table>
+</body>
+</html>
+</xsl:template>
+
+<!-- The cells of an instrument, empty for songs without its chart. -->
+<xsl:template name="chart">
+<xsl:param name="instrument"/>
+<xsl:variable name="level" select="level/*[local-name() = $instrument]"/>
+<xsl:variable name="rank" select="best-rank/*[local-name() = $instrument]"/>
+<xsl:choose>
+<xsl:when test="$level = 0"><td/><td/><td/></xsl:when>
+<xsl:otherwise>
+<td class="n"><xsl:value-of select="format-number($level div 10 + level-dec/*[local-name() = $instrument] div 100, '0.00')"/></td>
+<td><xsl:choose>
+<xsl:when test="$rank = 0">SS</xsl:when>
+<xsl:when test="$rank = 1">S</xsl:when>
+<xsl:when test="$rank = 2">A</xsl:when>
+<xsl:when test="$rank = 3">B</xsl:when>
+<xsl:when test="$rank = 4">C</xsl:when>
+<xsl:when test="$rank = 5">D</xsl:when>
+<xsl:when test="$rank = 6">E</xsl:when>
+</xsl:choose></td>
+<td class="n"><xsl:value-of select="format-number(high-skill/*[local-name() = $instrument], '0.00')"/></td>
+</xsl:otherwise>
+</xsl:choose>
+</xsl:template>
+</xsl:stylesheet>
+`
+
+// writeXMLStylesheetPI writes the processing instruction referring to
+// xmlStylesheet, if there is one.
+func writeXMLStylesheetPI(w io.Writer) error {
+	if xmlStylesheet == "" {
+		return nil
+	}
+	if _, err := io.WriteString(w, `<?xml-stylesheet type="text/xsl" href="`); err != nil {
+		return err
+	}
+	if err := xml.EscapeText(w, []byte(xmlStylesheet)); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\"?>"+xmlNewline())
+	return err
+}
+
+// writeBundledStylesheet writes dumpXSLT where the dump written to output
+// finds it.
+func writeBundledStylesheet(output string) error {
+	return os.WriteFile(filepath.Join(filepath.Dir(output), filepath.FromSlash(xmlStylesheet)), []byte(dumpXSLT), 0666)
+}